@@ -0,0 +1,546 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/atomicfile"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+)
+
+//DefaultTier is the tier of every customer who has not been assigned one
+const DefaultTier = "default"
+
+//Tier is a group of customers who are held to the same policy
+type Tier struct {
+	Name   string `json:"name"`
+	Policy string `json:"policy"`
+}
+
+//Override temporarily replaces the caps of one of a customer's limits, until it expires by processing time.
+//Only the caps it sets are replaced, a nil cap leaves the limit's own in force.
+type Override struct {
+	ID         string    `json:"id"`
+	CustomerID string    `json:"customer_id"`
+	Limit      string    `json:"limit"`
+	MaxAmount  *float64  `json:"max_amount,omitempty"`
+	MaxLoads   *int      `json:"max_loads,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+//Config is one version of the named policies, the tiers that use them, the customers in each tier,
+//the overrides in force and the standing of customers. A Config is never changed once it is published,
+//every change makes a new version.
+type Config struct {
+	Version   int                `json:"version"`
+	Policies  map[string]*Policy `json:"policies"`
+	Tiers     map[string]Tier    `json:"tiers"`
+	Customers map[string]string  `json:"customers"`
+	Overrides []Override         `json:"overrides"`
+	//Statuses holds the status of every customer whose account is not active
+	Statuses map[string]AccountStatus `json:"statuses"`
+	//Blocklist holds the customers whose loads are all declined
+	Blocklist map[string]bool `json:"blocklist"`
+}
+
+//Change is the audit record of one change to the config
+type Change struct {
+	//Version is the config version the change made
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Reason  string    `json:"reason"`
+	//Action is what was done, like "create_policy", and Target what it was done to
+	Action string `json:"action"`
+	Target string `json:"target"`
+	//Before and After are the target before and after the change, Before is omitted when it is created
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after"`
+}
+
+//PolicySource tells the policy in force for a customer at a point in processing time
+type PolicySource interface {
+	Policy(customerID string, now time.Time) *Policy
+}
+
+//ConfigStore holds the current config. Decisions read it without locking and see either all of a change or none of it.
+type ConfigStore struct {
+	current atomic.Value
+	//mu serializes changes
+	mu      sync.Mutex
+	changes []Change
+	audit   io.Writer
+	clock   clock.Clock
+	//path is the file every config is saved to, if any
+	path string
+}
+
+//NewConfigStore will create a ConfigStore whose default tier is held to the policy, named after the tier
+func NewConfigStore(policy *Policy) *ConfigStore {
+	s := &ConfigStore{clock: clock.New()}
+	s.current.Store(&Config{
+		Policies:  map[string]*Policy{DefaultTier: policy},
+		Tiers:     map[string]Tier{DefaultTier: {Name: DefaultTier, Policy: DefaultTier}},
+		Customers: map[string]string{},
+		Overrides: []Override{},
+		Statuses:  map[string]AccountStatus{},
+		Blocklist: map[string]bool{},
+	})
+	return s
+}
+
+//Restore replaces the config with one saved earlier, like the config section of a snapshot. It is for setting up
+//a store before it is used, so it is not audited, and the config version carries on from the one restored.
+func (s *ConfigStore) Restore(config *Config) error {
+	if err := config.verify(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	restored := config.clone()
+	restored.Version = config.Version
+	if s.path != "" {
+		if err := saveConfig(s.path, restored); err != nil {
+			return err
+		}
+	}
+	s.current.Store(restored)
+	return nil
+}
+
+//SetClock will make the store time changes and expire overrides with c instead of the system clock
+func (s *ConfigStore) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+//AuditTo will also write every change to w as a line of json, as it is made
+func (s *ConfigStore) AuditTo(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = w
+}
+
+//SaveTo will save the config to the file at path now and after every change, so the tiers, overrides and
+//standing of customers survive a restart. A change is only made once it is saved.
+func (s *ConfigStore) SaveTo(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := saveConfig(path, s.Current()); err != nil {
+		return err
+	}
+	s.path = path
+	return nil
+}
+
+//LoadConfig reads a config saved by a ConfigStore and verifies it, see ConfigStore.Restore
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err = json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("config %s: %s", path, err)
+	}
+	if err = config.verify(); err != nil {
+		return nil, fmt.Errorf("config %s: %s", path, err)
+	}
+	return config, nil
+}
+
+//saveConfig writes the config to a file through a rename, so a failed save leaves the earlier one intact
+func saveConfig(path string, config *Config) error {
+	jsonByte, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteBytes(path, jsonByte)
+}
+
+//Current returns the config in force
+func (s *ConfigStore) Current() *Config {
+	return s.current.Load().(*Config)
+}
+
+//Policy returns the policy in force for the customer in the current config
+func (s *ConfigStore) Policy(customerID string, now time.Time) *Policy {
+	return s.Current().Policy(customerID, now)
+}
+
+//policies returns every policy in the current config, which overrides only change the caps of
+func (s *ConfigStore) policies() []*Policy {
+	config := s.Current()
+	policies := make([]*Policy, 0, len(config.Policies))
+	for _, policy := range config.Policies {
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+//Changes returns the audit trail of every change made, oldest first
+func (s *ConfigStore) Changes() []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Change{}, s.changes...)
+}
+
+//Policy returns the policy customers in the tier are held to, with any of the customer's overrides in force at now
+//applied. The version of an overridden policy names the overrides, like "gold@3+override-2".
+func (c *Config) Policy(customerID string, now time.Time) *Policy {
+	tier := c.Tier(customerID)
+	base := *c.Policies[tier.Policy]
+	base.Tier = tier.Name
+	base.Status = c.Status(customerID)
+	base.Blocked = c.Blocklist[customerID]
+	var overrides []Override
+	for _, override := range c.Overrides {
+		if override.CustomerID == customerID && now.Before(override.ExpiresAt) {
+			overrides = append(overrides, override)
+		}
+	}
+	if len(overrides) == 0 {
+		return &base
+	}
+	policy := &base
+	policy.Limits = append([]Limit{}, base.Limits...)
+	for _, override := range overrides {
+		for i, limit := range policy.Limits {
+			if limit.Name == override.Limit {
+				if override.MaxAmount != nil {
+					policy.Limits[i].MaxAmount = *override.MaxAmount
+				}
+				if override.MaxLoads != nil {
+					policy.Limits[i].MaxLoads = *override.MaxLoads
+				}
+				policy.Version += "+override-" + override.ID
+			}
+		}
+	}
+	return policy
+}
+
+//Tier returns the tier the customer is in
+func (c *Config) Tier(customerID string) Tier {
+	if name, ok := c.Customers[customerID]; ok {
+		return c.Tiers[name]
+	}
+	return c.Tiers[DefaultTier]
+}
+
+func (c *Config) basePolicy(customerID string) *Policy {
+	return c.Policies[c.Tier(customerID).Policy]
+}
+
+//verify checks the config has a default tier, and that every policy is valid and every tier assignment and override
+//refers to what is there
+func (c *Config) verify() error {
+	if _, ok := c.Tiers[DefaultTier]; !ok {
+		return errors.New("config has no default tier")
+	}
+	for name, policy := range c.Policies {
+		if policy == nil {
+			return fmt.Errorf("config policy %s is empty", name)
+		}
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("config policy %s: %s", name, err)
+		}
+	}
+	for name, tier := range c.Tiers {
+		if _, ok := c.Policies[tier.Policy]; !ok {
+			return fmt.Errorf("config tier %s: policy %s does not exist", name, tier.Policy)
+		}
+	}
+	for customerID, tier := range c.Customers {
+		if _, ok := c.Tiers[tier]; !ok {
+			return fmt.Errorf("config customer %s: tier %s does not exist", customerID, tier)
+		}
+	}
+	for _, override := range c.Overrides {
+		if err := c.validateOverride(override, time.Time{}); err != nil {
+			return fmt.Errorf("config override %s: %s", override.ID, err)
+		}
+	}
+	for customerID, status := range c.Statuses {
+		if err := status.Validate(); err != nil {
+			return fmt.Errorf("config customer %s: %s", customerID, err)
+		}
+	}
+	return nil
+}
+
+//clone copies the config one level deep, which is enough as changes replace policies rather than edit them
+func (c *Config) clone() *Config {
+	next := &Config{
+		Version:   c.Version + 1,
+		Policies:  make(map[string]*Policy, len(c.Policies)),
+		Tiers:     make(map[string]Tier, len(c.Tiers)),
+		Customers: make(map[string]string, len(c.Customers)),
+		Overrides: append([]Override{}, c.Overrides...),
+		Statuses:  make(map[string]AccountStatus, len(c.Statuses)),
+		Blocklist: make(map[string]bool, len(c.Blocklist)),
+	}
+	for name, policy := range c.Policies {
+		next.Policies[name] = policy
+	}
+	for name, tier := range c.Tiers {
+		next.Tiers[name] = tier
+	}
+	for customerID, tier := range c.Customers {
+		next.Customers[customerID] = tier
+	}
+	for customerID, status := range c.Statuses {
+		next.Statuses[customerID] = status
+	}
+	for customerID := range c.Blocklist {
+		next.Blocklist[customerID] = true
+	}
+	return next
+}
+
+//update applies a change to a copy of the current config and publishes it, auditing the change.
+//change returns what it changed before and after, or an error to leave the config as it is.
+func (s *ConfigStore) update(actor, reason, action, target string, change func(*Config) (before, after interface{}, err error)) error {
+	if actor == "" {
+		return errors.New("actor is required")
+	}
+	if strings.TrimSpace(reason) == "" {
+		return errors.New("reason is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := s.Current().clone()
+	before, after, err := change(next)
+	if err != nil {
+		return err
+	}
+	now := s.clock.Now()
+	next.pruneOverrides(now)
+	record := Change{
+		Version: next.Version,
+		Time:    now,
+		Actor:   actor,
+		Reason:  reason,
+		Action:  action,
+		Target:  target,
+		Before:  before,
+		After:   after,
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if s.path != "" {
+		if err = saveConfig(s.path, next); err != nil {
+			return err
+		}
+	}
+	//the change is only made once it is saved and on the audit log, so a change that cannot be audited is
+	//taken back out of the file
+	if s.audit != nil {
+		if _, err = s.audit.Write(append(line, '\n')); err != nil {
+			if s.path != "" {
+				saveConfig(s.path, s.Current())
+			}
+			return err
+		}
+	}
+	s.changes = append(s.changes, record)
+	s.current.Store(next)
+	return nil
+}
+
+//pruneOverrides drops the overrides that have expired by now, so the config does not keep every override ever made
+func (c *Config) pruneOverrides(now time.Time) {
+	kept := c.Overrides[:0]
+	for _, override := range c.Overrides {
+		if now.Before(override.ExpiresAt) {
+			kept = append(kept, override)
+		}
+	}
+	c.Overrides = kept
+}
+
+//CreatePolicy adds a named policy. Its version is set to the name and the config version that created it.
+func (s *ConfigStore) CreatePolicy(actor, reason, name string, policy Policy) (*Policy, error) {
+	return s.putPolicy(actor, reason, "create_policy", name, policy, false)
+}
+
+//UpdatePolicy replaces a named policy, taking effect for every tier held to it. Its version is set like CreatePolicy.
+func (s *ConfigStore) UpdatePolicy(actor, reason, name string, policy Policy) (*Policy, error) {
+	return s.putPolicy(actor, reason, "update_policy", name, policy, true)
+}
+
+//ReloadPolicy replaces a named policy like UpdatePolicy with one reloaded from a file. It is versioned like
+//UpdatePolicy too, as a file may change without its version changing.
+func (s *ConfigStore) ReloadPolicy(actor, reason, name string, policy Policy) (*Policy, error) {
+	return s.putPolicy(actor, reason, "reload_policy", name, policy, true)
+}
+
+//putPolicy versions the policy with its name and the config version that puts it
+func (s *ConfigStore) putPolicy(actor, reason, action, name string, policy Policy, exists bool) (*Policy, error) {
+	if name == "" {
+		return nil, errors.New("policy name is required")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("policy %s: %s", name, err)
+	}
+	var created *Policy
+	err := s.update(actor, reason, action, name, func(c *Config) (interface{}, interface{}, error) {
+		before, found := c.Policies[name]
+		if found != exists {
+			return nil, nil, existsError("policy", name, found)
+		}
+		created = &policy
+		created.Version = fmt.Sprintf("%s@%d", name, c.Version)
+		created.Limits = append([]Limit{}, policy.Limits...)
+		c.Policies[name] = created
+		if before == nil {
+			return nil, created, nil
+		}
+		return before, created, nil
+	})
+	return created, err
+}
+
+//CreateTier adds a tier held to an existing policy
+func (s *ConfigStore) CreateTier(actor, reason string, tier Tier) error {
+	return s.putTier(actor, reason, "create_tier", tier, false)
+}
+
+//UpdateTier changes the policy a tier is held to
+func (s *ConfigStore) UpdateTier(actor, reason string, tier Tier) error {
+	return s.putTier(actor, reason, "update_tier", tier, true)
+}
+
+func (s *ConfigStore) putTier(actor, reason, action string, tier Tier, exists bool) error {
+	if tier.Name == "" {
+		return errors.New("tier name is required")
+	}
+	return s.update(actor, reason, action, tier.Name, func(c *Config) (interface{}, interface{}, error) {
+		before, found := c.Tiers[tier.Name]
+		if found != exists {
+			return nil, nil, existsError("tier", tier.Name, found)
+		}
+		if _, ok := c.Policies[tier.Policy]; !ok {
+			return nil, nil, fmt.Errorf("tier %s: policy %s does not exist", tier.Name, tier.Policy)
+		}
+		c.Tiers[tier.Name] = tier
+		if !found {
+			return nil, tier, nil
+		}
+		return before, tier, nil
+	})
+}
+
+//AssignTier moves a customer to a tier, or back to the default tier
+func (s *ConfigStore) AssignTier(actor, reason, customerID, tier string) error {
+	if customerID == "" {
+		return errors.New("customer_id is required")
+	}
+	return s.update(actor, reason, "assign_tier", customerID, func(c *Config) (interface{}, interface{}, error) {
+		if _, ok := c.Tiers[tier]; !ok {
+			return nil, nil, existsError("tier", tier, false)
+		}
+		before := c.Tier(customerID).Name
+		if tier == DefaultTier {
+			delete(c.Customers, customerID)
+		} else {
+			c.Customers[customerID] = tier
+		}
+		return before, tier, nil
+	})
+}
+
+//CreateOverride adds an override for one of the limits of the customer's policy, and returns it with its ID
+func (s *ConfigStore) CreateOverride(actor, reason string, override Override) (Override, error) {
+	var created Override
+	err := s.update(actor, reason, "create_override", override.CustomerID, func(c *Config) (interface{}, interface{}, error) {
+		override.ID = fmt.Sprint(c.Version)
+		if err := c.validateOverride(override, s.clock.Now()); err != nil {
+			return nil, nil, err
+		}
+		c.Overrides = append(c.Overrides, override)
+		created = override
+		return nil, override, nil
+	})
+	return created, err
+}
+
+//UpdateOverride replaces the override with the same ID, changing its caps or when it expires
+func (s *ConfigStore) UpdateOverride(actor, reason string, override Override) error {
+	return s.update(actor, reason, "update_override", override.ID, func(c *Config) (interface{}, interface{}, error) {
+		for i, before := range c.Overrides {
+			if before.ID != override.ID {
+				continue
+			}
+			if override.CustomerID != before.CustomerID {
+				return nil, nil, fmt.Errorf("override %s is for customer %s", override.ID, before.CustomerID)
+			}
+			//unlike a new override, an update may set an expiry that has passed to end it early
+			if err := c.validateOverride(override, time.Time{}); err != nil {
+				return nil, nil, err
+			}
+			c.Overrides[i] = override
+			return before, override, nil
+		}
+		return nil, nil, existsError("override", override.ID, false)
+	})
+}
+
+//validateOverride checks the override sets a cap of a limit of the customer's policy and, when now is given, has not expired
+func (c *Config) validateOverride(override Override, now time.Time) error {
+	if override.CustomerID == "" {
+		return errors.New("override customer_id is required")
+	}
+	if override.MaxAmount == nil && override.MaxLoads == nil {
+		return fmt.Errorf("override of limit %s sets neither max_amount nor max_loads", override.Limit)
+	}
+	if (override.MaxAmount != nil && *override.MaxAmount < 0) || (override.MaxLoads != nil && *override.MaxLoads < 0) {
+		return fmt.Errorf("override of limit %s must not be negative", override.Limit)
+	}
+	if override.ExpiresAt.IsZero() {
+		return errors.New("override expires_at is required")
+	}
+	if !now.IsZero() && !override.ExpiresAt.After(now) {
+		return fmt.Errorf("override expires_at %s has passed", override.ExpiresAt.Format(time.RFC3339))
+	}
+	policy := c.basePolicy(override.CustomerID)
+	for _, limit := range policy.Limits {
+		if limit.Name == override.Limit {
+			return nil
+		}
+	}
+	return fmt.Errorf("limit %s is not in policy %s of customer %s", override.Limit, policy.Version, override.CustomerID)
+}
+
+//NotFoundError is returned for a change to something that does not exist
+type NotFoundError struct {
+	Kind, Name string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s does not exist", e.Kind, e.Name)
+}
+
+//ConflictError is returned for creating something that already exists
+type ConflictError struct {
+	Kind, Name string
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("%s %s already exists", e.Kind, e.Name)
+}
+
+func existsError(kind, name string, found bool) error {
+	if found {
+		return ConflictError{Kind: kind, Name: name}
+	}
+	return NotFoundError{Kind: kind, Name: name}
+}
@@ -0,0 +1,285 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigStoreTestSuite struct {
+	suite.Suite
+	clock   *clock.Fake
+	store   *ConfigStore
+	service CustomerAccount
+	cache   *cache.Cache
+	day     time.Time
+}
+
+func TestConfigStore(t *testing.T) {
+	suite.Run(t, new(ConfigStoreTestSuite))
+}
+
+func (s *ConfigStoreTestSuite) SetupTest() {
+	s.clock = clock.NewFake(time.Date(2020, 11, 16, 9, 0, 0, 0, time.UTC))
+	s.store = NewConfigStore(DefaultPolicy())
+	s.store.SetClock(s.clock)
+	s.service = CustomerAccount{Policies: s.store, Clock: s.clock}
+	s.cache = cache.New(cache.NoExpiration, 0)
+	s.day = time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+}
+
+func (s *ConfigStoreTestSuite) decide(id, customerID string, amount float64) Decision {
+	return s.service.Decide(Fund{ID: id, CustomerID: customerID, LoadAmount: amount, Time: s.day}, s.cache)
+}
+
+func (s *ConfigStoreTestSuite) gold() {
+	gold := *DefaultPolicy()
+	gold.Limits[0].MaxAmount = 10000.00
+	_, err := s.store.CreatePolicy("alice", "gold customers load more", "gold", gold)
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.CreateTier("alice", "gold customers load more", Tier{Name: "gold", Policy: "gold"}))
+	s.Require().NoError(s.store.AssignTier("bob", "upgraded", "18", "gold"))
+}
+
+func (s *ConfigStoreTestSuite) TestDefaultTier() {
+	decision := s.decide("1", "18", 5000.01)
+	s.False(decision.Accepted())
+	s.Equal("default", decision.PolicyVersion)
+}
+
+func (s *ConfigStoreTestSuite) TestTier() {
+	s.gold()
+	decision := s.decide("1", "18", 8000.00)
+	s.True(decision.Accepted())
+	s.Equal("gold@1", decision.PolicyVersion)
+	//other customers stay in the default tier
+	s.False(s.decide("2", "19", 8000.00).Accepted())
+
+	s.Require().NoError(s.store.AssignTier("bob", "downgraded", "18", DefaultTier))
+	s.Equal("default", s.decide("3", "18", 1.00).PolicyVersion)
+	s.Empty(s.store.Current().Customers)
+}
+
+func (s *ConfigStoreTestSuite) TestUpdatePolicy() {
+	s.gold()
+	policy := *DefaultPolicy()
+	policy.Limits[0].MaxAmount = 2000.00
+	updated, err := s.store.UpdatePolicy("alice", "gold is too generous", "gold", policy)
+	s.Require().NoError(err)
+	s.Equal("gold@4", updated.Version)
+	decision := s.decide("1", "18", 3000.00)
+	s.False(decision.Accepted())
+	s.Equal("gold@4", decision.PolicyVersion)
+}
+
+func (s *ConfigStoreTestSuite) TestUpdateTier() {
+	s.gold()
+	s.Require().NoError(s.store.UpdateTier("alice", "gold is paused", Tier{Name: "gold", Policy: DefaultTier}))
+	s.Equal("default", s.decide("1", "18", 1.00).PolicyVersion)
+}
+
+func (s *ConfigStoreTestSuite) TestOverride() {
+	override, err := s.store.CreateOverride("carol", "one off house purchase", Override{
+		CustomerID: "18",
+		Limit:      "daily",
+		MaxAmount:  amount(9000.00),
+		MaxLoads:   loads(3),
+		ExpiresAt:  s.clock.Now().Add(time.Hour),
+	})
+	s.Require().NoError(err)
+	s.Equal("1", override.ID)
+	decision := s.decide("1", "18", 8000.00)
+	s.True(decision.Accepted())
+	s.Equal("default+override-1", decision.PolicyVersion)
+	//the override replaces the daily cap rather than lifting it
+	s.False(s.decide("2", "18", 1000.01).Accepted())
+
+	s.clock.Advance(time.Hour)
+	decision = s.decide("3", "19", 8000.00)
+	s.False(decision.Accepted())
+	s.Equal("default", decision.PolicyVersion)
+}
+
+func (s *ConfigStoreTestSuite) TestOverrideOfOneCap() {
+	_, err := s.store.CreateOverride("carol", "one off house purchase", Override{
+		CustomerID: "18", Limit: "daily", MaxAmount: amount(9000.00), ExpiresAt: s.clock.Now().Add(time.Hour),
+	})
+	s.Require().NoError(err)
+	policy := s.store.Policy("18", s.clock.Now())
+	s.Equal(9000.00, policy.Limits[0].MaxAmount)
+	s.Equal(DailyNumberOfLoadsLimit, policy.Limits[0].MaxLoads, "the daily load count is still capped")
+	for i := 1; i <= DailyNumberOfLoadsLimit; i++ {
+		s.True(s.decide(fmt.Sprint(i), "18", 100.00).Accepted())
+	}
+	s.Equal(ReasonWindowLimit, s.decide("4", "18", 100.00).Reason())
+}
+
+func amount(f float64) *float64 {
+	return &f
+}
+
+func loads(n int) *int {
+	return &n
+}
+
+func (s *ConfigStoreTestSuite) TestUpdateOverride() {
+	override, err := s.store.CreateOverride("carol", "one off house purchase", Override{
+		CustomerID: "18", Limit: "daily", MaxAmount: amount(9000.00), ExpiresAt: s.clock.Now().Add(time.Hour),
+	})
+	s.Require().NoError(err)
+	wrong := override
+	wrong.CustomerID = "19"
+	s.EqualError(s.store.UpdateOverride("carol", "wrong customer", wrong), "override 1 is for customer 18")
+	wrong.ID = "7"
+	s.Equal(NotFoundError{Kind: "override", Name: "7"}, s.store.UpdateOverride("carol", "missing", wrong))
+
+	override.ExpiresAt = s.clock.Now()
+	s.Require().NoError(s.store.UpdateOverride("carol", "purchase cancelled", override))
+	s.Equal("default", s.decide("1", "18", 1.00).PolicyVersion)
+	s.Empty(s.store.Current().Overrides, "an override ended early is dropped")
+}
+
+func (s *ConfigStoreTestSuite) TestExpiredOverridesAreDropped() {
+	_, err := s.store.CreateOverride("carol", "one off house purchase", Override{
+		CustomerID: "18", Limit: "daily", MaxAmount: amount(9000.00), ExpiresAt: s.clock.Now().Add(time.Hour),
+	})
+	s.Require().NoError(err)
+	_, err = s.store.CreateOverride("carol", "holiday", Override{
+		CustomerID: "19", Limit: "daily", MaxLoads: loads(5), ExpiresAt: s.clock.Now().Add(2 * time.Hour),
+	})
+	s.Require().NoError(err)
+	s.clock.Advance(time.Hour)
+	s.gold()
+	overrides := s.store.Current().Overrides
+	s.Require().Len(overrides, 1, "the next change drops the overrides that have expired")
+	s.Equal("19", overrides[0].CustomerID)
+}
+
+func (s *ConfigStoreTestSuite) TestAuditOnlyOnceSaved() {
+	dir, err := ioutil.TempDir("", "config")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	var log bytes.Buffer
+	s.store.AuditTo(&log)
+	s.Require().NoError(s.store.SaveTo(filepath.Join(dir, "config.json")))
+	s.Require().NoError(os.RemoveAll(dir))
+	s.Error(s.store.Block("bob", "fraud ring", "18"))
+	s.Empty(log.String(), "a change that is not saved is not audited")
+	s.Empty(s.store.Changes())
+}
+
+func (s *ConfigStoreTestSuite) TestRestore() {
+	s.gold()
+	restored := NewConfigStore(DefaultPolicy())
+	s.Require().NoError(restored.Restore(s.store.Current()))
+	s.Equal("gold@1", restored.Policy("18", s.day).Version)
+	s.Equal(s.store.Current().Version, restored.Current().Version)
+	s.Empty(restored.Changes())
+	s.Require().NoError(restored.AssignTier("bob", "downgraded", "18", DefaultTier))
+	s.Equal("gold", s.store.Current().Tier("18").Name, "the restored config is not shared")
+
+	config := s.store.Current().clone()
+	config.Customers["19"] = "platinum"
+	s.EqualError(restored.Restore(config), "config customer 19: tier platinum does not exist")
+}
+
+func (s *ConfigStoreTestSuite) TestInvalidChangesAreNotMade() {
+	s.gold()
+	version := s.store.Current().Version
+	_, err := s.store.CreatePolicy("alice", "again", "gold", *DefaultPolicy())
+	s.Equal(ConflictError{Kind: "policy", Name: "gold"}, err)
+	_, err = s.store.UpdatePolicy("alice", "typo", "silver", *DefaultPolicy())
+	s.Equal(NotFoundError{Kind: "policy", Name: "silver"}, err)
+	_, err = s.store.CreatePolicy("alice", "broken", "broken", Policy{Limits: []Limit{{Name: "daily", Period: Period{Unit: "fortnight"}}}})
+	s.EqualError(err, `policy broken: limit daily: unknown period unit "fortnight"`)
+	_, err = s.store.CreatePolicy("alice", " ", "silver", *DefaultPolicy())
+	s.EqualError(err, "reason is required")
+	_, err = s.store.CreatePolicy("", "anonymous", "silver", *DefaultPolicy())
+	s.EqualError(err, "actor is required")
+	s.EqualError(s.store.CreateTier("alice", "no policy", Tier{Name: "silver", Policy: "silver"}), "tier silver: policy silver does not exist")
+	s.Equal(NotFoundError{Kind: "tier", Name: "silver"}, s.store.AssignTier("bob", "no tier", "18", "silver"))
+	_, err = s.store.CreateOverride("carol", "no limit", Override{CustomerID: "18", Limit: "monthly", MaxLoads: loads(3), ExpiresAt: s.clock.Now().Add(time.Hour)})
+	s.EqualError(err, "limit monthly is not in policy gold@1 of customer 18")
+	_, err = s.store.CreateOverride("carol", "expired", Override{CustomerID: "18", Limit: "daily", MaxLoads: loads(3), ExpiresAt: s.clock.Now()})
+	s.EqualError(err, "override expires_at 2020-11-16T09:00:00Z has passed")
+	_, err = s.store.CreateOverride("carol", "no caps", Override{CustomerID: "18", Limit: "daily", ExpiresAt: s.clock.Now().Add(time.Hour)})
+	s.EqualError(err, "override of limit daily sets neither max_amount nor max_loads")
+	_, err = s.store.CreateOverride("carol", "negative", Override{CustomerID: "18", Limit: "daily", MaxLoads: loads(-1), ExpiresAt: s.clock.Now().Add(time.Hour)})
+	s.EqualError(err, "override of limit daily must not be negative")
+	s.Equal(version, s.store.Current().Version)
+	s.Len(s.store.Changes(), 3)
+}
+
+func (s *ConfigStoreTestSuite) TestAudit() {
+	var log bytes.Buffer
+	s.store.AuditTo(&log)
+	s.gold()
+	changes := s.store.Changes()
+	s.Require().Len(changes, 3)
+	s.Equal(Change{Version: 3, Time: s.clock.Now(), Actor: "bob", Reason: "upgraded", Action: "assign_tier", Target: "18", Before: DefaultTier, After: "gold"}, changes[2])
+	s.Nil(changes[0].Before)
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	s.Require().Len(lines, 3)
+	var change Change
+	s.Require().NoError(json.Unmarshal([]byte(lines[0]), &change))
+	s.Equal("create_policy", change.Action)
+	s.Equal("gold", change.Target)
+	s.Equal("alice", change.Actor)
+}
+
+//TestAtomic reads the config while it changes, and checks every policy read is whole. Each version n of the policy
+//caps the daily amount at n, so a policy with a version that does not match its caps was read part way through a change.
+func (s *ConfigStoreTestSuite) TestAtomic() {
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	torn := make(chan *Policy, 1)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				policy := s.store.Policy("18", s.clock.Now())
+				if policy.Version == "default" {
+					continue
+				}
+				if policy.Version != fmt.Sprintf("p@%.0f", policy.Limits[0].MaxAmount) {
+					select {
+					case torn <- policy:
+					default:
+					}
+				}
+			}
+		}()
+	}
+	_, err := s.store.CreatePolicy("alice", "start", "p", Policy{Limits: []Limit{{Name: "daily", Period: Period{Unit: Day}, MaxAmount: 1}}})
+	s.Require().NoError(err)
+	s.Require().NoError(s.store.UpdateTier("alice", "start", Tier{Name: DefaultTier, Policy: "p"}))
+	for n := 3; n < 200; n++ {
+		_, err := s.store.UpdatePolicy("alice", "step", "p", Policy{Limits: []Limit{{Name: "daily", Period: Period{Unit: Day}, MaxAmount: float64(n)}}})
+		s.Require().NoError(err)
+	}
+	close(stop)
+	wg.Wait()
+	select {
+	case policy := <-torn:
+		s.Failf("torn read", "policy %s caps %v", policy.Version, policy.Limits[0].MaxAmount)
+	default:
+	}
+}
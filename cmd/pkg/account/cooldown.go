@@ -0,0 +1,63 @@
+package account
+
+import (
+	"errors"
+	"time"
+)
+
+//Cooldown makes a customer wait between loads. A load is declined when more than Burst of the customer's
+//accepted loads are within Minutes of it, by load time, so with no burst no two loads are that close together.
+type Cooldown struct {
+	Minutes int `json:"minutes"`
+	//Burst is how many other loads may be within the cooldown of a load
+	Burst int `json:"burst,omitempty"`
+}
+
+//Validate checks the cooldown has a length, and its burst is not negative
+func (c Cooldown) Validate() error {
+	if c.Minutes <= 0 {
+		return errors.New("cooldown needs a positive number of minutes")
+	}
+	if c.Burst < 0 {
+		return errors.New("cooldown burst must not be negative")
+	}
+	return nil
+}
+
+func (c Cooldown) interval() time.Duration {
+	return time.Duration(c.Minutes) * time.Minute
+}
+
+//checkCooldown declines a load with too many of the customer's accepted loads within the policy's cooldown of it.
+//Loads either side count, so a late load is held to the loads decided after it too.
+func (a CustomerAccount) checkCooldown(fund *Fund, policy *Policy) error {
+	if policy.Cooldown == nil {
+		return nil
+	}
+	interval := policy.Cooldown.interval()
+	near := 0
+	for _, load := range a.loadsNear(fund.Time, interval) {
+		if load.ID != fund.ID {
+			near++
+		}
+	}
+	if near > policy.Cooldown.Burst {
+		return decline(ReasonCooldown, "accountID: %s %d loads within the %d minute cooldown when process loadID: %s",
+			a.ID, near, policy.Cooldown.Minutes, fund.ID)
+	}
+	return nil
+}
+
+//loadsNear returns the accepted loads less than d from t
+func (a CustomerAccount) loadsNear(t time.Time, d time.Duration) []Fund {
+	var loads []Fund
+	//loads are grouped by the date in their own offset, which may be a day either side of the date in t's
+	for date := startOfDay(t.Add(-d)).AddDate(0, 0, -1); !date.After(t.Add(d).AddDate(0, 0, 1)); date = date.AddDate(0, 0, 1) {
+		for _, load := range a.Transactions[date.Format(dateLayout)] {
+			if diff := load.Time.Sub(t); diff > -d && diff < d {
+				loads = append(loads, load)
+			}
+		}
+	}
+	return loads
+}
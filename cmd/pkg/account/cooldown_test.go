@@ -0,0 +1,78 @@
+package account
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CooldownTestSuite struct {
+	suite.Suite
+	fixture
+}
+
+func TestCooldown(t *testing.T) {
+	suite.Run(t, new(CooldownTestSuite))
+}
+
+func (s *CooldownTestSuite) SetupTest() {
+	s.setup()
+}
+
+func (s *CooldownTestSuite) service(minutes, burst int) CustomerAccount {
+	//no window limits, so only the cooldown declines loads
+	return CustomerAccount{Policy: &Policy{Cooldown: &Cooldown{Minutes: minutes, Burst: burst}}}
+}
+
+func (s *CooldownTestSuite) TestCooldown() {
+	service := s.service(10, 0)
+	s.True(service.Decide(s.fundAfter("1", "18", 100.00, 0), s.cache).Accepted())
+	decision := service.Decide(s.fundAfter("2", "18", 100.00, 9*time.Minute+59*time.Second), s.cache)
+	s.Equal(ReasonCooldown, decision.Reason())
+	s.EqualError(decision.Err, "accountID: 18 1 loads within the 10 minute cooldown when process loadID: 2")
+	//declined loads do not restart the cooldown
+	s.True(service.Decide(s.fundAfter("3", "18", 100.00, 10*time.Minute), s.cache).Accepted())
+}
+
+func (s *CooldownTestSuite) TestBurst() {
+	service := s.service(10, 2)
+	for i, id := range []string{"1", "2", "3"} {
+		s.True(service.Decide(s.fundAfter(id, "18", 100.00, time.Duration(i)*time.Minute), s.cache).Accepted(), id)
+	}
+	s.Equal(ReasonCooldown, service.Decide(s.fundAfter("4", "18", 100.00, 3*time.Minute), s.cache).Reason())
+	//once the first load is 10 minutes old, only two are within the cooldown
+	s.True(service.Decide(s.fundAfter("5", "18", 100.00, 10*time.Minute), s.cache).Accepted())
+}
+
+func (s *CooldownTestSuite) TestLateLoadCountsLoadsAfterIt() {
+	service := s.service(10, 0)
+	s.True(service.Decide(s.fundAfter("1", "18", 100.00, 0), s.cache).Accepted())
+	s.True(service.Decide(s.fundAfter("2", "18", 100.00, 20*time.Minute), s.cache).Accepted())
+	s.Equal(ReasonCooldown, service.Decide(s.fundAfter("3", "18", 100.00, 15*time.Minute), s.cache).Reason())
+	s.True(service.Decide(s.fundAfter("4", "18", 100.00, 10*time.Minute), s.cache).Accepted(), "exactly 10 minutes from both")
+}
+
+func (s *CooldownTestSuite) TestAcrossMidnightAndOffsets() {
+	service := s.service(30, 0)
+	first := Fund{ID: "1", CustomerID: "18", LoadAmount: 100.00, Time: time.Date(2020, 11, 16, 23, 50, 0, 0, time.UTC)}
+	s.True(service.Decide(first, s.cache).Accepted())
+	//00:10 in UTC, but still the 16th five hours behind
+	second := Fund{ID: "2", CustomerID: "18", LoadAmount: 100.00, Time: time.Date(2020, 11, 16, 19, 10, 0, 0, time.FixedZone("EST", -5*60*60))}
+	s.Equal(ReasonCooldown, service.Decide(second, s.cache).Reason())
+}
+
+func (s *CooldownTestSuite) TestEvaluate() {
+	service := s.service(10, 0)
+	s.True(service.Decide(s.fundAfter("1", "18", 100.00, 0), s.cache).Accepted())
+	evaluation := service.Evaluate(s.fundAfter("2", "18", 100.00, time.Minute), s.cache)
+	s.False(evaluation.Accepted)
+	s.Equal(ReasonCooldown, evaluation.ReasonCode)
+}
+
+func (s *CooldownTestSuite) TestValidate() {
+	s.NoError(Cooldown{Minutes: 1}.Validate())
+	s.EqualError(Cooldown{}.Validate(), "cooldown needs a positive number of minutes")
+	s.EqualError(Cooldown{Minutes: 1, Burst: -1}.Validate(), "cooldown burst must not be negative")
+	s.EqualError((&Policy{Cooldown: &Cooldown{}}).Validate(), "cooldown needs a positive number of minutes")
+}
@@ -0,0 +1,306 @@
+package account
+
+import (
+	"fmt"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+//Dimension is what a limit counts loads by
+type Dimension string
+
+const (
+	//ByCustomer counts the loads of the customer making the load, and is the dimension of limits without a key
+	ByCustomer Dimension = "customer"
+	//ByFundingSource, ByDevice and ByIP count every customer's loads made with the same funding source,
+	//device or IP address as the load. A load without one is not checked against limits keyed on it.
+	ByFundingSource Dimension = "funding_source"
+	ByDevice        Dimension = "device"
+	ByIP            Dimension = "ip"
+	//ByTier counts the loads of every customer in the same tier as the customer making the load, and ByProgram
+	//the loads of every customer, for caps on the whole portfolio
+	ByTier    Dimension = "tier"
+	ByProgram Dimension = "program"
+)
+
+//programValue is the value every load has in the program dimension
+const programValue = "all"
+
+//entityDimensions are the dimensions other than the customer, in the order loads are recorded against them
+var entityDimensions = []Dimension{ByFundingSource, ByDevice, ByIP, ByTier, ByProgram}
+
+//Validate checks the dimension is a known one
+func (d Dimension) Validate() error {
+	switch d {
+	case "", ByCustomer, ByFundingSource, ByDevice, ByIP, ByTier, ByProgram:
+		return nil
+	}
+	return fmt.Errorf("unknown limit key %q, expected customer, funding_source, device, ip, tier or program", d)
+}
+
+//value returns what the load was made with in the dimension, empty when it is not known
+func (f Fund) value(d Dimension) string {
+	switch d {
+	case ByFundingSource:
+		return f.FundingSourceID
+	case ByDevice:
+		return f.DeviceID
+	case ByIP:
+		return f.IP
+	case ByTier:
+		if f.Tier == "" {
+			return DefaultTier
+		}
+		return f.Tier
+	case ByProgram:
+		return programValue
+	}
+	return f.CustomerID
+}
+
+//EntityHistory is the accepted loads made with one funding source, device or IP address, or of one tier or
+//the whole program, by any customer. It is held in the same cache as the accounts, under a key no customer ID can take.
+type EntityHistory struct {
+	Dimension    Dimension
+	Value        string
+	Transactions map[string][]Fund
+	//ExpiresAt is when the history is forgotten, like an account's
+	ExpiresAt time.Time
+	//NextRelease is when the first of the holds in the history expires, zero when it has none. The loads
+	//are only searched for expired holds once it has passed.
+	NextRelease time.Time
+}
+
+//entityKey is the cache key of the history of a dimension's value. Customer IDs cannot hold a NUL byte,
+//see validateRequest, so no account is held under one of these keys.
+func entityKey(d Dimension, value string) string {
+	return "\x00" + string(d) + "\x00" + value
+}
+
+//keys returns the dimensions other than the customer that the policy's limits are keyed on. Loads are only
+//recorded against those, so a program or tier history is not kept for policies without a limit on it.
+func (p *Policy) keys() []Dimension {
+	var keys []Dimension
+	for _, d := range entityDimensions {
+		for _, limit := range p.Limits {
+			if limit.Key == d {
+				keys = append(keys, d)
+				break
+			}
+		}
+	}
+	return keys
+}
+
+//horizon returns the start of the earliest window the policy's limits keyed on the dimension have at t. None of
+//them count loads before it. It is zero when no limit is keyed on the dimension.
+func (p *Policy) horizon(d Dimension, t time.Time) time.Time {
+	var horizon time.Time
+	for _, limit := range p.Limits {
+		if limit.Key != d {
+			continue
+		}
+		if start, _ := limit.Period.Bounds(t); horizon.IsZero() || start.Before(horizon) {
+			horizon = start
+		}
+	}
+	return horizon
+}
+
+//policySet is a PolicySource that can tell every policy it may give a customer
+type policySet interface {
+	policies() []*Policy
+}
+
+//policies returns every policy the account's customers may be held to, nil when its source cannot tell them
+func (a CustomerAccount) policies() []*Policy {
+	if a.Policies == nil {
+		return []*Policy{a.policy("")}
+	}
+	if set, ok := a.Policies.(policySet); ok {
+		return set.policies()
+	}
+	return nil
+}
+
+//pruneEntities drops the dates no limit of any of the policies counts from the histories read for a decision at t,
+//so a history busy enough never to expire, like the program's, holds no more than its longest window. The histories
+//are shared by customers held to different policies, so nothing is dropped when the policies are not known.
+func pruneEntities(entities map[string]*EntityHistory, policies []*Policy, t time.Time) {
+	for _, history := range entities {
+		var horizon time.Time
+		for _, policy := range policies {
+			if start := policy.horizon(history.Dimension, t); !start.IsZero() && (horizon.IsZero() || start.Before(horizon)) {
+				horizon = start
+			}
+		}
+		if horizon.IsZero() {
+			continue
+		}
+		cutoff := horizon.Format(dateLayout)
+		for date := range history.Transactions {
+			if date < cutoff {
+				delete(history.Transactions, date)
+			}
+		}
+	}
+}
+
+//loadEntities reads the histories in the dimensions of every entity the funds were made with into the account,
+//for the decision being made at now. Histories already read are kept.
+func (a *CustomerAccount) loadEntities(c *cache.Cache, now time.Time, dimensions []Dimension, funds ...Fund) {
+	for _, fund := range funds {
+		for _, d := range dimensions {
+			value := fund.value(d)
+			if value == "" {
+				continue
+			}
+			key := entityKey(d, value)
+			if _, found := a.entities[key]; found {
+				continue
+			}
+			if a.entities == nil {
+				a.entities = make(map[string]*EntityHistory)
+			}
+			history := &EntityHistory{Dimension: d, Value: value}
+			if x, found := c.Get(key); found {
+				if cached, ok := x.(EntityHistory); ok && !cached.expired(now) {
+					*history = cached
+					//copy the dates, so nothing changes in the cache until the decision is committed. The loads
+					//of a date are shared, as they are only appended to past what the cache holds or replaced
+					//whole, and decisions are made one at a time.
+					history.Transactions = make(map[string][]Fund, len(cached.Transactions))
+					for date, loads := range cached.Transactions {
+						history.Transactions[date] = loads
+					}
+					if !history.NextRelease.IsZero() && !now.Before(history.NextRelease) {
+						history.Transactions, _, history.NextRelease = releaseExpired(history.Transactions, now)
+					}
+				}
+			}
+			a.entities[key] = history
+		}
+	}
+}
+
+//commitEntities writes the entity histories read for a decision back to the cache, deleting those left empty
+func commitEntities(c *cache.Cache, entities map[string]*EntityHistory) {
+	for key, history := range entities {
+		if len(history.Transactions) == 0 {
+			c.Delete(key)
+			continue
+		}
+		c.Set(key, *history, cache.DefaultExpiration)
+	}
+}
+
+//restoreEntities adds the accepted loads of restored accounts to the histories of what they were made with.
+//Snapshots only hold accounts, so the histories are rebuilt from them.
+func restoreEntities(c *cache.Cache, accounts []CustomerAccount) {
+	restored := CustomerAccount{}
+	for _, account := range accounts {
+		for _, loads := range account.Transactions {
+			restored.loadEntities(c, restored.now(), entityDimensions, loads...)
+			for _, load := range loads {
+				for _, d := range entityDimensions {
+					history := restored.entity(d, load)
+					if history == nil || history.has(load) {
+						continue
+					}
+					//the history is kept as long as the longest kept account with a load in it, zero being forever
+					if history.Transactions == nil {
+						history.Transactions = make(map[string][]Fund)
+						history.ExpiresAt = account.ExpiresAt
+					} else if !history.ExpiresAt.IsZero() && (account.ExpiresAt.IsZero() || account.ExpiresAt.After(history.ExpiresAt)) {
+						history.ExpiresAt = account.ExpiresAt
+					}
+					date := load.Time.Format(dateLayout)
+					history.Transactions[date] = append(history.Transactions[date], load)
+					history.hold(load)
+				}
+			}
+		}
+	}
+	commitEntities(c, restored.entities)
+}
+
+//hold notes when a load added to the history is released, if it is held
+func (h *EntityHistory) hold(fund Fund) {
+	if fund.HoldExpiresAt != nil && (h.NextRelease.IsZero() || fund.HoldExpiresAt.Before(h.NextRelease)) {
+		h.NextRelease = *fund.HoldExpiresAt
+	}
+}
+
+func (h EntityHistory) has(fund Fund) bool {
+	for _, load := range h.Transactions[fund.Time.Format(dateLayout)] {
+		if load.ID == fund.ID && load.CustomerID == fund.CustomerID {
+			return true
+		}
+	}
+	return false
+}
+
+//recordEntities adds an accepted load to the history of every entity it was made with
+func (a CustomerAccount) recordEntities(fund Fund) {
+	date := fund.Time.Format(dateLayout)
+	for _, d := range entityDimensions {
+		history := a.entity(d, fund)
+		if history == nil {
+			continue
+		}
+		if history.Transactions == nil {
+			history.Transactions = make(map[string][]Fund)
+		}
+		history.Transactions[date] = append(history.Transactions[date], fund)
+		history.ExpiresAt = a.ExpiresAt
+		history.hold(fund)
+	}
+}
+
+//forgetEntities removes an accepted load from the history of every entity it was made with, before it is decided again
+func (a CustomerAccount) forgetEntities(fund Fund) {
+	date := fund.Time.Format(dateLayout)
+	for _, d := range entityDimensions {
+		history := a.entity(d, fund)
+		if history == nil {
+			continue
+		}
+		kept := history.Transactions[date][:0:0]
+		for _, load := range history.Transactions[date] {
+			if load.ID != fund.ID || load.CustomerID != fund.CustomerID {
+				kept = append(kept, load)
+			}
+		}
+		if len(kept) == 0 {
+			delete(history.Transactions, date)
+		} else {
+			history.Transactions[date] = kept
+		}
+	}
+}
+
+//entity returns the history read for what the fund was made with in the dimension, nil if it has none
+func (a CustomerAccount) entity(d Dimension, fund Fund) *EntityHistory {
+	value := fund.value(d)
+	if value == "" {
+		return nil
+	}
+	return a.entities[entityKey(d, value)]
+}
+
+//transactions returns the accepted loads a limit counts for the fund, by the limit's key. It is nil when
+//the fund was not made with anything in the limit's dimension.
+func (a CustomerAccount) transactions(limit Limit, fund Fund) map[string][]Fund {
+	if limit.Key == "" || limit.Key == ByCustomer {
+		return a.Transactions
+	}
+	if history := a.entity(limit.Key, fund); history != nil {
+		return history.Transactions
+	}
+	return nil
+}
+
+func (h EntityHistory) expired(now time.Time) bool {
+	return !h.ExpiresAt.IsZero() && !now.Before(h.ExpiresAt)
+}
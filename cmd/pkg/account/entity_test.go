@@ -0,0 +1,235 @@
+package account
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/stretchr/testify/suite"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type EntityTestSuite struct {
+	suite.Suite
+	fixture
+	service CustomerAccount
+}
+
+func TestEntity(t *testing.T) {
+	suite.Run(t, new(EntityTestSuite))
+}
+
+func (s *EntityTestSuite) SetupTest() {
+	s.setup()
+	s.service = CustomerAccount{Policy: &Policy{
+		Version: "entities",
+		Limits: []Limit{
+			{Name: "customer_daily", Period: Period{Unit: Day}, MaxAmount: 5000},
+			{Name: "device_daily", Period: Period{Unit: Day}, MaxLoads: 2, Key: ByDevice},
+			{Name: "ip_daily", Period: Period{Unit: Day}, MaxAmount: 1000, Key: ByIP},
+		},
+	}}
+}
+
+func (s *EntityTestSuite) TestDeviceAcrossCustomers() {
+	s.True(s.service.Decide(s.fundWith("1", "18", 100, "phone", ""), s.cache).Accepted())
+	s.True(s.service.Decide(s.fundWith("2", "19", 100, "phone", ""), s.cache).Accepted())
+	decision := s.service.Decide(s.fundWith("3", "20", 100, "phone", ""), s.cache)
+	s.Equal(ReasonWindowLimit, decision.Reason())
+	s.Contains(decision.Err.Error(), "device_daily")
+	//another device, or none, is not held to the phone's loads
+	s.True(s.service.Decide(s.fundWith("4", "20", 100, "tablet", ""), s.cache).Accepted())
+	s.True(s.service.Decide(s.fundWith("5", "20", 100, "", ""), s.cache).Accepted())
+}
+
+func (s *EntityTestSuite) TestDeclinedLoadIsNotRecordedAgainstAnyEntity() {
+	s.True(s.service.Decide(s.fundWith("1", "18", 900, "phone", "10.0.0.1"), s.cache).Accepted())
+	//within the device limit, but over the IP's amount
+	s.Equal(ReasonWindowLimit, s.service.Decide(s.fundWith("2", "19", 200, "phone", "10.0.0.1"), s.cache).Reason())
+	s.True(s.service.Decide(s.fundWith("3", "20", 100, "phone", "10.0.0.2"), s.cache).Accepted(),
+		"the declined load must not count against the device")
+	x, found := s.cache.Get(entityKey(ByDevice, "phone"))
+	s.Require().True(found)
+	s.Len(x.(EntityHistory).Transactions["2020-11-16"], 2)
+}
+
+func (s *EntityTestSuite) TestEvaluate() {
+	s.True(s.service.Decide(s.fundWith("1", "18", 900, "", "10.0.0.1"), s.cache).Accepted())
+	evaluation := s.service.Evaluate(s.fundWith("2", "19", 200, "", "10.0.0.1"), s.cache)
+	s.False(evaluation.Accepted)
+	s.Equal(ReasonWindowLimit, evaluation.ReasonCode)
+	s.True(s.service.Evaluate(s.fundWith("2", "19", 200, "", "10.0.0.2"), s.cache).Accepted)
+}
+
+func (s *EntityTestSuite) TestReevaluateForgetsLaterLoads() {
+	s.service.OutOfOrder = Reevaluate
+	later := s.fundWith("1", "18", 100, "phone", "")
+	later.Time = s.start.Add(time.Hour)
+	s.True(s.service.Decide(later, s.cache).Accepted())
+	s.True(s.service.Decide(s.fundWith("2", "19", 100, "phone", ""), s.cache).Accepted())
+	//customer 18's late load takes the device's last load of the day, so its later one is now declined
+	decision := s.service.Decide(s.fundWith("3", "18", 100, "phone", ""), s.cache)
+	s.True(decision.Accepted())
+	s.Require().Len(decision.Corrections, 1)
+	s.Equal("1", decision.Corrections[0].Fund.ID)
+	s.False(decision.Corrections[0].Accepted)
+	x, _ := s.cache.Get(entityKey(ByDevice, "phone"))
+	s.Len(x.(EntityHistory).Transactions["2020-11-16"], 2)
+}
+
+func (s *EntityTestSuite) TestSweep() {
+	fake := clock.NewFake(s.start)
+	s.service.Clock = fake
+	s.service.Retention = time.Hour
+	s.True(s.service.Decide(s.fundWith("1", "18", 100, "phone", "10.0.0.1"), s.cache).Accepted())
+	s.Equal(3, s.cache.ItemCount())
+	fake.Advance(time.Hour)
+	s.Equal(1, s.service.Sweep(s.cache), "only accounts are counted")
+	s.Equal(0, s.cache.ItemCount())
+}
+
+func (s *EntityTestSuite) TestValidate() {
+	s.NoError((&Policy{Limits: []Limit{{Name: "a", Period: Period{Unit: Day}, Key: ByFundingSource}}}).Validate())
+	s.EqualError((&Policy{Limits: []Limit{{Name: "a", Period: Period{Unit: Day}, Key: "card"}}}).Validate(),
+		`limit a: unknown limit key "card", expected customer, funding_source, device, ip, tier or program`)
+}
+
+func (s *EntityTestSuite) TestRequest() {
+	handler := NewHandler(s.service, validator.New(), s.cache)
+	fund, err := handler.Parse(`{"id":"1","customer_id":"18","load_amount":"$100.00","time":"2020-11-16T10:00:00Z",` +
+		`"funding_source_id":"card","device_id":"phone","ip":"10.0.0.1"}`)
+	s.Require().NoError(err)
+	s.Equal("card", fund.FundingSourceID)
+	s.Equal("phone", fund.DeviceID)
+	s.Equal("10.0.0.1", fund.IP)
+	_, err = handler.Parse(`{"id":"1","customer_id":"18","load_amount":"$100.00","time":"2020-11-16T10:00:00Z","ip":"phone"}`)
+	s.Error(err)
+	_, err = handler.Parse(`{"id":"1","customer_id":"\u0000ip","load_amount":"$100.00","time":"2020-11-16T10:00:00Z"}`)
+	s.Error(err)
+}
+
+func (s *EntityTestSuite) TestRestore() {
+	s.True(s.service.Decide(s.fundWith("1", "18", 100, "phone", ""), s.cache).Accepted())
+	s.True(s.service.Decide(s.fundWith("2", "19", 100, "phone", ""), s.cache).Accepted())
+	snapshot, err := NewSnapshot(s.cache, s.start)
+	s.Require().NoError(err)
+	restored := cache.New(cache.NoExpiration, 0)
+	s.Require().NoError(snapshot.Restore(restored))
+	s.Equal(ReasonWindowLimit, s.service.Decide(s.fundWith("3", "20", 100, "phone", ""), restored).Reason(),
+		"the device's history is rebuilt from the accounts")
+	//restoring again does not count the loads twice
+	RestoreAccounts(restored, snapshot.Accounts)
+	x, _ := restored.Get(entityKey(ByDevice, "phone"))
+	s.Len(x.(EntityHistory).Transactions["2020-11-16"], 2)
+}
+
+func (s *EntityTestSuite) programService(limits ...Limit) CustomerAccount {
+	return CustomerAccount{Policy: &Policy{Version: "program", Limits: limits}}
+}
+
+func (s *EntityTestSuite) TestProgram() {
+	service := s.programService(Limit{Name: "program_daily", Period: Period{Unit: Day}, MaxAmount: 1000, Key: ByProgram})
+	s.True(service.Decide(s.fundWith("1", "18", 600, "", ""), s.cache).Accepted())
+	decision := service.Decide(s.fundWith("2", "19", 500, "", ""), s.cache)
+	s.Equal(ReasonWindowLimit, decision.Reason())
+	s.EqualError(decision.Err, "accountID: 19 exceed program_daily fund limit when process loadID: 2")
+	s.True(service.Decide(s.fundWith("3", "19", 400, "", ""), s.cache).Accepted())
+	usage := service.Usage("20", s.start, s.cache)
+	s.Require().Len(usage.Limits, 1)
+	s.Equal(1000.00, usage.Limits[0].UsedAmount)
+}
+
+func (s *EntityTestSuite) TestProgramKeepsLongestWindow() {
+	service := s.programService(
+		Limit{Name: "program_daily", Period: Period{Unit: Day}, MaxAmount: 1000, Key: ByProgram},
+		Limit{Name: "program_weekly", Period: Period{Unit: Week}, MaxAmount: 5000, Key: ByProgram},
+	)
+	for i := 0; i < 10; i++ {
+		fund := s.fundWith(fmt.Sprint(i), "18", 100, "", "")
+		fund.Time = s.start.AddDate(0, 0, i)
+		s.True(service.Decide(fund, s.cache).Accepted())
+	}
+	//the last load is on Wednesday 2020-11-25, so only its week is kept
+	x, _ := s.cache.Get(entityKey(ByProgram, programValue))
+	s.Len(x.(EntityHistory).Transactions, 3)
+	s.Contains(x.(EntityHistory).Transactions, "2020-11-23")
+	usage := service.Usage("19", s.start.AddDate(0, 0, 9), s.cache)
+	s.Require().Len(usage.Limits, 2)
+	s.Equal(300.00, usage.Limits[1].UsedAmount)
+}
+
+func (s *EntityTestSuite) TestTiersWithDifferentWindows() {
+	store := NewConfigStore(&Policy{Version: "default", Limits: []Limit{
+		{Name: "program_daily", Period: Period{Unit: Day}, MaxAmount: 1000, Key: ByProgram},
+	}})
+	_, err := store.CreatePolicy("test", "setup", "gold", Policy{Limits: []Limit{
+		{Name: "program_monthly", Period: Period{Unit: Month}, MaxAmount: 1000, Key: ByProgram},
+	}})
+	s.Require().NoError(err)
+	s.Require().NoError(store.CreateTier("test", "setup", Tier{Name: "gold", Policy: "gold"}))
+	s.Require().NoError(store.AssignTier("test", "setup", "18", "gold"))
+	service := CustomerAccount{Policies: store}
+	for i, load := range []struct {
+		customerID string
+		amount     float64
+		accepted   bool
+	}{{"18", 900, true}, {"19", 1, true}, {"18", 900, false}} {
+		fund := s.fundWith(fmt.Sprint(i), load.customerID, load.amount, "", "")
+		fund.Time = s.start.AddDate(0, 0, i)
+		s.Equal(load.accepted, service.Decide(fund, s.cache).Accepted(), "load %d", i)
+	}
+	//the default tier's daily window does not drop the loads the gold tier's month counts
+	x, _ := s.cache.Get(entityKey(ByProgram, programValue))
+	s.Len(x.(EntityHistory).Transactions, 2)
+}
+
+func (s *EntityTestSuite) TestTier() {
+	store := NewConfigStore(&Policy{Version: "default"})
+	_, err := store.CreatePolicy("test", "setup", "premium", Policy{Limits: []Limit{
+		{Name: "tier_daily", Period: Period{Unit: Day}, MaxLoads: 1, Key: ByTier},
+	}})
+	s.Require().NoError(err)
+	s.Require().NoError(store.CreateTier("test", "setup", Tier{Name: "premium", Policy: "premium"}))
+	s.Require().NoError(store.AssignTier("test", "setup", "18", "premium"))
+	s.Require().NoError(store.AssignTier("test", "setup", "19", "premium"))
+	service := CustomerAccount{Policies: store}
+	s.True(service.Decide(s.fundWith("1", "18", 100, "", ""), s.cache).Accepted())
+	s.Equal(ReasonWindowLimit, service.Decide(s.fundWith("2", "19", 100, "", ""), s.cache).Reason())
+	//the default tier is held to its own policy, and its loads are not counted towards the premium tier
+	s.True(service.Decide(s.fundWith("3", "20", 100, "", ""), s.cache).Accepted())
+	x, found := s.cache.Get(entityKey(ByTier, "premium"))
+	s.Require().True(found)
+	s.Len(x.(EntityHistory).Transactions["2020-11-16"], 1)
+}
+
+func (s *EntityTestSuite) TestNoHistoryWithoutLimit() {
+	s.True(s.programService().Decide(s.fundWith("1", "18", 100, "phone", "10.0.0.1"), s.cache).Accepted())
+	s.Equal(1, s.cache.ItemCount(), "only the account is kept")
+}
+
+func (s *EntityTestSuite) TestProgramConcurrent() {
+	service := s.programService(Limit{Name: "program_daily", Period: Period{Unit: Day}, MaxLoads: 10, Key: ByProgram})
+	handler := NewHandler(service, validator.New(), s.cache)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			response := handler.Load(s.fundWith(fmt.Sprint(i), fmt.Sprint(i%5), 100, "", ""))
+			mu.Lock()
+			defer mu.Unlock()
+			if response.Accepted {
+				accepted++
+			}
+		}(i)
+	}
+	wg.Wait()
+	s.Equal(10, accepted)
+	x, _ := s.cache.Get(entityKey(ByProgram, programValue))
+	s.Len(x.(EntityHistory).Transactions["2020-11-16"], 10)
+}
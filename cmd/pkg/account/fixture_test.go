@@ -0,0 +1,41 @@
+package account
+
+import (
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+)
+
+//fixture is what the suites that decide loads share: an empty cache, a fixed start time and a fake clock set to it.
+//Suites embed it and call setup from SetupTest.
+type fixture struct {
+	cache *cache.Cache
+	start time.Time
+	clock *clock.Fake
+}
+
+func (f *fixture) setup() {
+	f.cache = cache.New(cache.NoExpiration, 0)
+	f.start = time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+	f.clock = clock.NewFake(f.start)
+}
+
+//fund returns a load of the amount by the customer at the start time
+func (f *fixture) fund(id, customerID string, amount float64) Fund {
+	return Fund{ID: id, CustomerID: customerID, LoadAmount: amount, Time: f.start}
+}
+
+//fundWith returns a load like fund, made with the device and IP address
+func (f *fixture) fundWith(id, customerID string, amount float64, device, ip string) Fund {
+	fund := f.fund(id, customerID, amount)
+	fund.DeviceID, fund.IP = device, ip
+	return fund
+}
+
+//fundAfter returns a load like fund, made d after the start time
+func (f *fixture) fundAfter(id, customerID string, amount float64, d time.Duration) Fund {
+	fund := f.fund(id, customerID, amount)
+	fund.Time = fund.Time.Add(d)
+	return fund
+}
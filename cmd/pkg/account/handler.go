@@ -1,27 +1,58 @@
 package account
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	validator "gopkg.in/go-playground/validator.v9"
 )
 
-type fundRequest struct {
+//FundRequest is a fund request as received, before it is validated
+type FundRequest struct {
 	ID         string `json:"id" validate:"required"`
 	CustomerID string `json:"customer_id" validate:"required"`
 	LoadAmount string `json:"load_amount" validate:"required"`
 	Time       string `json:"time" validate:"required"`
+	//FundingSourceID, DeviceID and IP optionally say what the load was made with, for limits keyed on them
+	FundingSourceID string `json:"funding_source_id,omitempty"`
+	DeviceID        string `json:"device_id,omitempty"`
+	IP              string `json:"ip,omitempty" validate:"omitempty,ip"`
 }
 
 type FundResponse struct {
 	ID         string `json:"id" validate:"required"`
 	CustomerID string `json:"customer_id" validate:"required"`
 	Accepted   bool   `json:"accepted" validate:"required"`
+	//Correction marks a response that replaces the one previously given for the load
+	Correction bool `json:"correction,omitempty"`
+}
+
+//FundDetail is the extended response for a load, with the parsed request, the customer's totals once
+//the load is decided, the policy version it was checked against, its outcome, why it was declined or
+//flagged, when an accepted reservation is released unless it is captured, and how long the decision took.
+//Only the server reserves loads, so the hold is left out of the responses written to files.
+type FundDetail struct {
+	FundResponse
+	LoadAmount float64 `json:"load_amount"`
+	//Time is the load time in UTC
+	Time time.Time `json:"time"`
+	Totals
+	PolicyVersion string        `json:"policy_version"`
+	Outcome       Outcome       `json:"outcome"`
+	Reason        Reason        `json:"reason,omitempty"`
+	Flags         []Flag        `json:"flags,omitempty"`
+	HoldExpiresAt *time.Time    `json:"-"`
+	Latency       time.Duration `json:"latency_ns"`
 }
 
 //FundHandler contains validator to validate fund request
@@ -29,6 +60,10 @@ type FundHandler struct {
 	validate *validator.Validate
 	service  Service
 	cache    *cache.Cache
+	clock    clock.Clock
+	review   *ReviewQueue
+	//mu serializes requests so concurrent loads for a customer see each other's history
+	mu sync.Mutex
 }
 
 //NewHandler will create a new FundHandler for requested fund transaction
@@ -36,50 +71,318 @@ func NewHandler(s Service, v *validator.Validate, c *cache.Cache) FundHandler {
 	return FundHandler{service: s, validate: v, cache: c}
 }
 
+//SetClock will make the handler tell processing time with c instead of the system clock
+func (h *FundHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+//SetReviewQueue will make the handler queue every load accepted but flagged by a soft limit on q
+func (h *FundHandler) SetReviewQueue(q *ReviewQueue) {
+	h.review = q
+}
+
+//ReviewQueue returns the queue flagged loads are added to, nil if there is none
+func (h *FundHandler) ReviewQueue() *ReviewQueue {
+	return h.review
+}
+
+//Now returns the current processing time
+func (h *FundHandler) Now() time.Time {
+	if h.clock == nil {
+		return time.Now()
+	}
+	return h.clock.Now()
+}
+
 //Run will take json string as request, validate, and process the request
 func (h *FundHandler) Run(req string) FundResponse {
-	var err error
-	input := fundRequest{}
-	if err = json.Unmarshal([]byte(req), &input); err != nil {
+	ctx, span := tracer().Start(context.Background(), "FundHandler.Run")
+	defer span.End()
+	fund, err := h.parse(ctx, req)
+	if err != nil {
 		log.Print(err)
+		traceError(span, err)
 		return FundResponse{}
 	}
+	response, _ := h.decideDetail(ctx, fund, 0)
+	return response.FundResponse
+}
+
+//Parse will take json string as request, validate it, and convert it to a Fund
+func (h *FundHandler) Parse(req string) (Fund, error) {
+	return h.parse(context.Background(), req)
+}
+
+func (h *FundHandler) parse(ctx context.Context, req string) (Fund, error) {
+	input, err := h.unmarshal(ctx, req)
+	if err != nil {
+		return Fund{}, err
+	}
+	return h.parseRequest(ctx, input)
+}
+
+func (h *FundHandler) unmarshal(ctx context.Context, req string) (FundRequest, error) {
+	_, span := tracer().Start(ctx, "parse")
+	defer span.End()
+	input := FundRequest{}
+	if err := json.Unmarshal([]byte(req), &input); err != nil {
+		traceError(span, err)
+		return FundRequest{}, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("load.id", input.ID), attribute.String("customer.id", input.CustomerID))
+	return input, nil
+}
+
+//ParseRequest will validate a decoded request and convert it to a Fund
+func (h *FundHandler) ParseRequest(input FundRequest) (Fund, error) {
+	return h.parseRequest(context.Background(), input)
+}
+
+func (h *FundHandler) parseRequest(ctx context.Context, input FundRequest) (Fund, error) {
+	_, span := tracer().Start(ctx, "validate")
+	defer span.End()
+	fund, err := h.validateRequest(input)
+	if err != nil {
+		traceError(span, err)
+	}
+	return fund, err
+}
+
+func (h *FundHandler) validateRequest(input FundRequest) (Fund, error) {
+	var err error
 	if err = h.validate.Struct(input); err != nil {
-		log.Print(err)
-		return FundResponse{}
+		return Fund{}, err
+	}
+	//NUL bytes separate the parts of the keys other histories are held under, see entityKey
+	if strings.ContainsRune(input.CustomerID, 0) {
+		return Fund{}, fmt.Errorf("invalid customer_id: %q", input.CustomerID)
 	}
 	amount, err := strconv.ParseFloat(strings.TrimPrefix(input.LoadAmount, "$"), 64)
 	if err != nil {
-		log.Print(err)
-		return FundResponse{}
+		return Fund{}, err
 	}
 	timestamp, err := time.Parse(time.RFC3339, input.Time)
 	if err != nil {
-		log.Print(err)
-		return FundResponse{}
+		return Fund{}, err
+	}
+	return Fund{
+		ID:              input.ID,
+		CustomerID:      input.CustomerID,
+		LoadAmount:      amount,
+		Time:            timestamp,
+		FundingSourceID: input.FundingSourceID,
+		DeviceID:        input.DeviceID,
+		IP:              input.IP,
+	}, nil
+}
+
+//Load will process a parsed fund request, returning an empty response if the loadID exists
+//Any corrections to earlier responses are dropped, use Decide when late loads are re-evaluated.
+func (h *FundHandler) Load(fund Fund) FundResponse {
+	response, _ := h.Decide(fund)
+	return response
+}
+
+//Decide will process a parsed fund request like Load, and also return corrections to earlier responses
+func (h *FundHandler) Decide(fund Fund) (FundResponse, []FundResponse) {
+	detail, correctionDetails := h.DecideDetail(fund)
+	var corrections []FundResponse
+	for _, correction := range correctionDetails {
+		corrections = append(corrections, correction.FundResponse)
+	}
+	return detail.FundResponse, corrections
+}
+
+//DecideDetail will process a parsed fund request like Decide, returning extended responses
+func (h *FundHandler) DecideDetail(fund Fund) (FundDetail, []FundDetail) {
+	return h.decideDetail(context.Background(), fund, 0)
+}
+
+//ReserveDetail will process a parsed fund request like DecideDetail, but an accepted load only holds its capacity
+//until it is captured, or released by Release or once ttl has passed
+func (h *FundHandler) ReserveDetail(fund Fund, ttl time.Duration) (FundDetail, []FundDetail) {
+	if ttl <= 0 {
+		ttl = DefaultHoldTTL
+	}
+	return h.decideDetail(context.Background(), fund, ttl)
+}
+
+//Capture will make a load held by ReserveDetail permanent
+func (h *FundHandler) Capture(customerID, loadID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.service.Capture(customerID, loadID, h.cache)
+}
+
+//Release will free the capacity held by a load reserved by ReserveDetail
+func (h *FundHandler) Release(customerID, loadID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.service.Release(customerID, loadID, h.cache)
+}
+
+//Snapshot takes a snapshot of every account along with the config, waiting for the requests being processed
+func (h *FundHandler) Snapshot(config *Config) (Snapshot, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return NewConfigSnapshot(h.cache, config, h.Now())
+}
+
+//Sweep deletes expired accounts like CustomerAccount.Sweep. It waits for the requests being processed, so an
+//account a load is renewing is never deleted from under it.
+func (h *FundHandler) Sweep() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.service.Sweep(h.cache)
+}
+
+//RunSweeper sweeps expired accounts every interval, as told by the handler's clock, until stop is closed
+func (h *FundHandler) RunSweeper(interval time.Duration, stop <-chan struct{}) {
+	clk := h.clock
+	if clk == nil {
+		clk = clock.New()
 	}
-	fund := Fund{
-		ID:         input.ID,
-		CustomerID: input.CustomerID,
-		LoadAmount: amount,
-		Time:       timestamp,
+	for {
+		select {
+		case <-stop:
+			return
+		case <-clk.After(interval):
+			h.Sweep()
+		}
+	}
+}
+
+//decideDetail decides the fund, or reserves it for hold when it is positive
+func (h *FundHandler) decideDetail(ctx context.Context, fund Fund, hold time.Duration) (FundDetail, []FundDetail) {
+	start := h.Now()
+	h.mu.Lock()
+	var decision Decision
+	if hold > 0 {
+		decision = h.service.ReserveContext(ctx, fund, hold, h.cache)
+	} else {
+		decision = h.service.DecideContext(ctx, fund, h.cache)
+	}
+	h.mu.Unlock()
+	latency := h.Now().Sub(start)
+	var corrections []FundDetail
+	for _, correction := range decision.Corrections {
+		outcome := OutcomeDeclined
+		if correction.Accepted {
+			outcome = OutcomeAccepted
+		}
+		corrections = append(corrections, FundDetail{
+			FundResponse: FundResponse{
+				ID:         correction.Fund.ID,
+				CustomerID: correction.Fund.CustomerID,
+				Accepted:   correction.Accepted,
+				Correction: true,
+			},
+			LoadAmount:    correction.Fund.LoadAmount,
+			Time:          correction.Fund.Time.UTC(),
+			Totals:        correction.Totals,
+			PolicyVersion: decision.PolicyVersion,
+			Outcome:       outcome,
+			Latency:       latency,
+		})
 	}
-	exists, err := h.service.LoadFund(fund, h.cache)
 	//return empty if loadID exists
-	if exists {
-		log.Print(err)
-		return FundResponse{}
+	if decision.Duplicate {
+		log.Print(decision.Err)
+		return FundDetail{}, nil
 	}
-	if err != nil {
-		return FundResponse{
+	detail := FundDetail{
+		FundResponse: FundResponse{
 			ID:         fund.ID,
 			CustomerID: fund.CustomerID,
-			Accepted:   false,
+			Accepted:   decision.Accepted(),
+		},
+		LoadAmount:    fund.LoadAmount,
+		Time:          fund.Time.UTC(),
+		Totals:        decision.Totals,
+		PolicyVersion: decision.PolicyVersion,
+		Outcome:       decision.Outcome(),
+		Reason:        decision.Reason(),
+		Flags:         decision.Flags,
+		HoldExpiresAt: decision.HoldExpiresAt,
+		Latency:       latency,
+	}
+	if h.review != nil && detail.Outcome == OutcomeAcceptedFlagged {
+		if err := h.review.Add(detail); err != nil {
+			log.Print(err)
 		}
 	}
-	return FundResponse{
-		ID:         fund.ID,
-		CustomerID: fund.CustomerID,
-		Accepted:   true,
+	return detail, corrections
+}
+
+//Process will take json string as request like Run, returning its response followed by any corrections,
+//or nothing if the request is ignored
+func (h *FundHandler) Process(req string) []FundResponse {
+	ctx, span := tracer().Start(context.Background(), "FundHandler.Process")
+	defer span.End()
+	input, err := h.unmarshal(ctx, req)
+	if err != nil {
+		log.Print(err)
+		traceError(span, err)
+		return nil
+	}
+	return responses(h.processRequest(ctx, input))
+}
+
+//ProcessRequest will validate and process a decoded request like Process
+func (h *FundHandler) ProcessRequest(input FundRequest) []FundResponse {
+	return responses(h.ProcessRequestDetail(input))
+}
+
+//ProcessRequestDetail will validate and process a decoded request like ProcessRequest, returning extended responses
+func (h *FundHandler) ProcessRequestDetail(input FundRequest) []FundDetail {
+	ctx, span := tracer().Start(context.Background(), "FundHandler.Process",
+		trace.WithAttributes(attribute.String("load.id", input.ID), attribute.String("customer.id", input.CustomerID)))
+	defer span.End()
+	return h.processRequest(ctx, input)
+}
+
+func (h *FundHandler) processRequest(ctx context.Context, input FundRequest) []FundDetail {
+	fund, err := h.parseRequest(ctx, input)
+	if err != nil {
+		log.Print(err)
+		traceError(trace.SpanFromContext(ctx), err)
+		return nil
+	}
+	detail, corrections := h.decideDetail(ctx, fund, 0)
+	if (detail.FundResponse == FundResponse{}) {
+		return nil
 	}
+	return append([]FundDetail{detail}, corrections...)
+}
+
+//responses drops the extended fields of each response
+func responses(details []FundDetail) []FundResponse {
+	var responses []FundResponse
+	for _, detail := range details {
+		responses = append(responses, detail.FundResponse)
+	}
+	return responses
+}
+
+//Evaluate will take json string as request and report whether it would be accepted, without loading it
+func (h *FundHandler) Evaluate(req string) (Evaluation, error) {
+	fund, err := h.Parse(req)
+	if err != nil {
+		return Evaluation{}, err
+	}
+	return h.EvaluateFund(fund), nil
+}
+
+//EvaluateFund will report whether a parsed fund request would be accepted, without loading it
+func (h *FundHandler) EvaluateFund(fund Fund) Evaluation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.service.Evaluate(fund, h.cache)
+}
+
+//Usage will report the customer's consumption of each limit as of the given time
+func (h *FundHandler) Usage(customerID string, at time.Time) Usage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.service.Usage(customerID, at, h.cache)
 }
@@ -1,12 +1,14 @@
 package account
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
 	"time"
 
 	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
 	validator "gopkg.in/go-playground/validator.v9"
 
 	"github.com/stretchr/testify/mock"
@@ -30,16 +32,42 @@ func (mock *MockCustomerAccount) LoadFund(fund Fund, c *cache.Cache) (bool, erro
 	args := mock.Called()
 	return args.Bool(0), args.Error(1)
 }
-func (mock *MockCustomerAccount) checkIfLoadExists(request string) error {
-	args := mock.Called(request)
+func (mock *MockCustomerAccount) Decide(fund Fund, c *cache.Cache) Decision {
+	exists, err := mock.LoadFund(fund, c)
+	return Decision{Duplicate: exists, Err: err}
+}
+func (mock *MockCustomerAccount) DecideContext(ctx context.Context, fund Fund, c *cache.Cache) Decision {
+	return mock.Decide(fund, c)
+}
+func (mock *MockCustomerAccount) ReserveContext(ctx context.Context, fund Fund, ttl time.Duration, c *cache.Cache) Decision {
+	return mock.Decide(fund, c)
+}
+func (mock *MockCustomerAccount) Capture(customerID, loadID string, c *cache.Cache) error {
+	args := mock.Called(customerID, loadID)
+	return args.Error(0)
+}
+func (mock *MockCustomerAccount) Release(customerID, loadID string, c *cache.Cache) error {
+	args := mock.Called(customerID, loadID)
 	return args.Error(0)
 }
-func (mock *MockCustomerAccount) checkDailyLimit(fund *Fund) error {
-	args := mock.Called(fund)
+func (mock *MockCustomerAccount) Evaluate(fund Fund, c *cache.Cache) Evaluation {
+	args := mock.Called()
+	return args.Get(0).(Evaluation)
+}
+func (mock *MockCustomerAccount) Usage(customerID string, at time.Time, c *cache.Cache) Usage {
+	args := mock.Called(customerID, at)
+	return args.Get(0).(Usage)
+}
+func (mock *MockCustomerAccount) Sweep(c *cache.Cache) int {
+	args := mock.Called()
+	return args.Int(0)
+}
+func (mock *MockCustomerAccount) checkIfLoadExists(request string) error {
+	args := mock.Called(request)
 	return args.Error(0)
 }
-func (mock *MockCustomerAccount) checkWeeklyLimit(fund *Fund) error {
-	args := mock.Called(fund)
+func (mock *MockCustomerAccount) checkLimit(fund *Fund, limit Limit) error {
+	args := mock.Called(fund, limit)
 	return args.Error(0)
 }
 
@@ -219,3 +247,110 @@ func (s *FundTestSuite) TestFailToLoadLoadIDExists() {
 		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
 	}
 }
+
+func (s *FundTestSuite) TestEvaluateInvalidRequest() {
+	s.Reset()
+	s.request = `{"id":"29360","customer_id":"18","load_amount":"$$5745.70","time":"2000-02-04T12:27:00Z"}`
+	mock := new(MockCustomerAccount)
+	c := cache.New(5*time.Minute, 10*time.Minute)
+	v := validator.New()
+	handler := FundHandler{
+		service:  mock,
+		validate: v,
+		cache:    c,
+	}
+	s.resp, s.err = handler.Evaluate(s.request)
+	s.expectedResp = Evaluation{}
+	if s.err == nil {
+		s.T().Error("error was expected, but no error return")
+	}
+	if !reflect.DeepEqual(s.resp, s.expectedResp) {
+		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
+	}
+	mock.AssertNotCalled(s.T(), "Evaluate")
+}
+
+func (s *FundTestSuite) TestEvaluate() {
+	s.Reset()
+	s.request = `{"id":"29360","customer_id":"18","load_amount":"$5745.70","time":"2000-02-04T12:27:00Z"}`
+	mock := new(MockCustomerAccount)
+	mock.On("Evaluate").Return(Evaluation{ID: "29360", CustomerID: "18", Accepted: true})
+	c := cache.New(5*time.Minute, 10*time.Minute)
+	v := validator.New()
+	handler := FundHandler{
+		service:  mock,
+		validate: v,
+		cache:    c,
+	}
+	s.resp, s.err = handler.Evaluate(s.request)
+	s.expectedResp = Evaluation{ID: "29360", CustomerID: "18", Accepted: true}
+	if s.err != nil {
+		s.T().Errorf("no error was expected, but error returned was %s.", s.err)
+	}
+	if !reflect.DeepEqual(s.resp, s.expectedResp) {
+		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
+	}
+	mock.AssertNotCalled(s.T(), "LoadFund")
+}
+
+func (s *FundTestSuite) TestNowUsesClock() {
+	s.Reset()
+	now := time.Date(2020, 11, 18, 23, 59, 59, 0, time.UTC)
+	handler := NewHandler(CustomerAccount{}, validator.New(), cache.New(5*time.Minute, 10*time.Minute))
+	handler.SetClock(clock.NewFake(now))
+	s.resp = handler.Now()
+	s.expectedResp = now
+	if !reflect.DeepEqual(s.resp, s.expectedResp) {
+		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
+	}
+}
+
+func (s *FundTestSuite) TestProcessRequestDetail() {
+	s.Reset()
+	handler := NewHandler(CustomerAccount{}, validator.New(), cache.New(5*time.Minute, 10*time.Minute))
+	handler.SetClock(clock.NewFake(time.Date(2020, 11, 18, 23, 59, 59, 0, time.UTC)))
+	handler.ProcessRequestDetail(FundRequest{ID: "1", CustomerID: "18", LoadAmount: "$3000.00", Time: "2000-02-03T12:00:00Z"})
+	s.resp = handler.ProcessRequestDetail(FundRequest{ID: "2", CustomerID: "18", LoadAmount: "$1500.50", Time: "2000-02-04T07:27:00-05:00"})
+	s.expectedResp = []FundDetail{{
+		FundResponse:  FundResponse{ID: "2", CustomerID: "18", Accepted: true},
+		LoadAmount:    1500.50,
+		Time:          time.Date(2000, 2, 4, 12, 27, 0, 0, time.UTC),
+		Totals:        Totals{Day: 1500.50, Week: 4500.50},
+		PolicyVersion: "default",
+		Outcome:       OutcomeAccepted,
+	}}
+	if !reflect.DeepEqual(s.resp, s.expectedResp) {
+		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
+	}
+}
+
+//FuzzFundHandlerRun checks that any request either parses and gets a response for the same load, or is ignored,
+//and that nothing accepted could break the daily limit on its own
+func FuzzFundHandlerRun(f *testing.F) {
+	f.Add(`{"id":"29360","customer_id":"18","load_amount":"$4745.70","time":"2000-02-04T12:27:00Z"}`)
+	f.Add(`{"id":"16710","customer_id":"783","load_amount":"$750.87","time":""}`)
+	f.Add(`{"id":"29360","customer_id":"18","load_amount":"$$5745.70","time":"2000-02-04T12:27:00Z"}`)
+	f.Add(`{"id":"29360","customer_id":"18","load_amount":"$5745.70","time":"20000-02-04T12:27:00Z"}`)
+	f.Add(`{:"324","load_amount":"$4810.91","time":"2000-02-05T17:05:16Z"}`)
+	f.Add(`{"id":"1","customer_id":"1","load_amount":"$-100.00","time":"2000-02-04T12:27:00Z"}`)
+	f.Add(`{"id":"1","customer_id":"1","load_amount":"NaN","time":"2000-02-04T12:27:00+05:00"}`)
+	f.Add(`{"id":"1","customer_id":"1","load_amount":"1e3","time":"2000-02-04T12:27:00.123Z","extra":true}`)
+	v := validator.New()
+	f.Fuzz(func(t *testing.T, req string) {
+		handler := NewHandler(CustomerAccount{}, v, cache.New(cache.NoExpiration, 0))
+		fund, parseErr := handler.Parse(req)
+		resp := handler.Run(req)
+		if parseErr != nil {
+			if (resp != FundResponse{}) {
+				t.Errorf("request %q failed to parse with %s, but got response %+v", req, parseErr, resp)
+			}
+			return
+		}
+		if resp.ID != fund.ID || resp.CustomerID != fund.CustomerID {
+			t.Errorf("request %q parsed as %+v, but got response %+v", req, fund, resp)
+		}
+		if resp.Accepted && fund.LoadAmount > DailyFundLimit {
+			t.Errorf("request %q was accepted with amount %v", req, fund.LoadAmount)
+		}
+	})
+}
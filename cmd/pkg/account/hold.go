@@ -0,0 +1,125 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+//DefaultHoldTTL is how long a reservation holds capacity when no time is given
+const DefaultHoldTTL = 15 * time.Minute
+
+//Reserve decides the fund like Decide, but an accepted load only holds its capacity until it is captured,
+//or released by Release or once ttl has passed by the clock. DefaultHoldTTL is used when ttl is not positive.
+func (a CustomerAccount) Reserve(fund Fund, ttl time.Duration, c *cache.Cache) Decision {
+	return a.ReserveContext(context.Background(), fund, ttl, c)
+}
+
+//ReserveContext reserves the fund like Reserve, tracing each step as a child of the span in ctx
+func (a CustomerAccount) ReserveContext(ctx context.Context, fund Fund, ttl time.Duration, c *cache.Cache) Decision {
+	if ttl <= 0 {
+		ttl = DefaultHoldTTL
+	}
+	return a.decide(ctx, fund, ttl, c)
+}
+
+//Capture makes a held load permanent. It fails with a NotFoundError when the customer has no hold on the load,
+//because it was never reserved, was declined, or has already been captured, released or expired.
+func (a CustomerAccount) Capture(customerID, loadID string, c *cache.Cache) error {
+	return a.settle(customerID, loadID, c, func(load *Fund) bool {
+		load.HoldExpiresAt = nil
+		return true
+	})
+}
+
+//Release frees the capacity held by a load, as if it had never been accepted. Its ID stays taken.
+//It fails like Capture when the customer has no hold on the load.
+func (a CustomerAccount) Release(customerID, loadID string, c *cache.Cache) error {
+	return a.settle(customerID, loadID, c, func(*Fund) bool {
+		return false
+	})
+}
+
+//settle updates a held load in the customer's history and the history of everything it was made with together
+func (a CustomerAccount) settle(customerID, loadID string, c *cache.Cache, update func(*Fund) bool) error {
+	now := a.now()
+	a = a.loadAccount(customerID, c)
+	held, found := a.held(loadID)
+	if !found {
+		return NotFoundError{Kind: "hold on load", Name: loadID}
+	}
+	//every history the load may have been recorded in, whatever the policy keys on now
+	a.loadEntities(c, now, entityDimensions, held)
+	updateLoad(a.Transactions, held, update)
+	for _, d := range entityDimensions {
+		if history := a.entity(d, held); history != nil {
+			updateLoad(history.Transactions, held, update)
+		}
+	}
+	entities := a.entities
+	a.entities = nil
+	c.Set(a.ID, a, cache.DefaultExpiration)
+	commitEntities(c, entities)
+	return nil
+}
+
+//held returns the accepted load with the ID if it is still held
+func (a CustomerAccount) held(loadID string) (Fund, bool) {
+	for _, loads := range a.Transactions {
+		for _, load := range loads {
+			if load.ID == loadID && load.HoldExpiresAt != nil {
+				return load, true
+			}
+		}
+	}
+	return Fund{}, false
+}
+
+//updateLoad replaces the load in history that is the fund, by ID and customer, with what update makes of it,
+//or drops it when update returns false. The loads of its date are copied, not changed in place.
+func updateLoad(history map[string][]Fund, fund Fund, update func(*Fund) bool) {
+	date := fund.Time.Format(dateLayout)
+	kept := history[date][:0:0]
+	for _, load := range history[date] {
+		if load.ID == fund.ID && load.CustomerID == fund.CustomerID && !update(&load) {
+			continue
+		}
+		kept = append(kept, load)
+	}
+	if len(kept) == 0 {
+		delete(history, date)
+	} else {
+		history[date] = kept
+	}
+}
+
+//releaseExpired returns the history without the holds that have expired by now, how many there were, and
+//when the next of the holds left expires, zero if there are none. The history is only copied when a hold expired.
+func releaseExpired(history map[string][]Fund, now time.Time) (map[string][]Fund, int, time.Time) {
+	released := 0
+	var next time.Time
+	for _, loads := range history {
+		for _, load := range loads {
+			switch {
+			case load.HoldExpiresAt == nil:
+			case !now.Before(*load.HoldExpiresAt):
+				released++
+			case next.IsZero() || load.HoldExpiresAt.Before(next):
+				next = *load.HoldExpiresAt
+			}
+		}
+	}
+	if released == 0 {
+		return history, 0, next
+	}
+	kept := make(map[string][]Fund, len(history))
+	for date, loads := range history {
+		for _, load := range loads {
+			if load.HoldExpiresAt == nil || now.Before(*load.HoldExpiresAt) {
+				kept[date] = append(kept[date], load)
+			}
+		}
+	}
+	return kept, released, next
+}
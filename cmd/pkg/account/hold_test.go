@@ -0,0 +1,112 @@
+package account
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type HoldTestSuite struct {
+	suite.Suite
+	fixture
+	service CustomerAccount
+}
+
+func TestHold(t *testing.T) {
+	suite.Run(t, new(HoldTestSuite))
+}
+
+func (s *HoldTestSuite) SetupTest() {
+	s.setup()
+	s.service = CustomerAccount{Clock: s.clock, Policy: &Policy{
+		Version: "holds",
+		Limits: []Limit{
+			{Name: "daily", Period: Period{Unit: Day}, MaxAmount: 5000},
+			{Name: "device_daily", Period: Period{Unit: Day}, MaxAmount: 5000, Key: ByDevice},
+		},
+	}}
+}
+
+func (s *HoldTestSuite) TestReserveHoldsCapacity() {
+	decision := s.service.Reserve(s.fundWith("1", "18", 4000, "phone", ""), 10*time.Minute, s.cache)
+	s.True(decision.Accepted())
+	s.Require().NotNil(decision.HoldExpiresAt)
+	s.Equal(s.start.Add(10*time.Minute), *decision.HoldExpiresAt)
+	s.Equal(ReasonWindowLimit, s.service.Decide(s.fundWith("2", "18", 2000, "phone", ""), s.cache).Reason())
+	s.Equal(ReasonWindowLimit, s.service.Decide(s.fundWith("3", "19", 2000, "phone", ""), s.cache).Reason(), "the device's capacity is held too")
+	usage := s.service.Usage("18", s.start, s.cache)
+	s.Equal(4000.00, usage.Limits[0].UsedAmount)
+	s.Equal(4000.00, usage.Limits[0].HeldAmount)
+	s.Equal(1, usage.Limits[0].HeldLoads)
+	evaluation := s.service.Evaluate(s.fundWith("4", "18", 1000, "phone", ""), s.cache)
+	s.True(evaluation.Accepted)
+	s.Equal(4000.00, evaluation.Limits[0].HeldAmount)
+}
+
+func (s *HoldTestSuite) TestCapture() {
+	s.True(s.service.Reserve(s.fundWith("1", "18", 4000, "phone", ""), 10*time.Minute, s.cache).Accepted())
+	s.NoError(s.service.Capture("18", "1", s.cache))
+	s.clock.Advance(time.Hour)
+	s.Equal(ReasonWindowLimit, s.service.Decide(s.fundWith("2", "19", 2000, "phone", ""), s.cache).Reason())
+	usage := s.service.Usage("18", s.start, s.cache)
+	s.Equal(4000.00, usage.Limits[0].UsedAmount)
+	s.Zero(usage.Limits[0].HeldAmount)
+	s.Equal(NotFoundError{Kind: "hold on load", Name: "1"}, s.service.Capture("18", "1", s.cache))
+}
+
+func (s *HoldTestSuite) TestRelease() {
+	s.True(s.service.Reserve(s.fundWith("1", "18", 4000, "phone", ""), 10*time.Minute, s.cache).Accepted())
+	s.NoError(s.service.Release("18", "1", s.cache))
+	s.Equal(1, s.cache.ItemCount(), "the device's history is left empty, and no other is kept")
+	s.True(s.service.Decide(s.fundWith("2", "19", 5000, "phone", ""), s.cache).Accepted())
+	s.EqualError(s.service.Release("18", "1", s.cache), "hold on load 1 does not exist")
+	s.True(s.service.Reserve(s.fundWith("1", "18", 100, "phone", ""), time.Minute, s.cache).Duplicate, "the ID stays taken")
+}
+
+func (s *HoldTestSuite) TestExpiredHoldIsReleased() {
+	s.True(s.service.Reserve(s.fundWith("1", "18", 4000, "phone", ""), 10*time.Minute, s.cache).Accepted())
+	s.clock.Advance(10 * time.Minute)
+	s.True(s.service.Decide(s.fundWith("2", "18", 5000, "phone", ""), s.cache).Accepted())
+	s.Error(s.service.Capture("18", "1", s.cache))
+	x, _ := s.cache.Get(entityKey(ByDevice, "phone"))
+	s.Len(x.(EntityHistory).Transactions["2020-11-16"], 1)
+}
+
+func (s *HoldTestSuite) TestDeclinedReservationHoldsNothing() {
+	decision := s.service.Reserve(s.fundWith("1", "18", 6000, "phone", ""), time.Minute, s.cache)
+	s.False(decision.Accepted())
+	s.Nil(decision.HoldExpiresAt)
+	s.Error(s.service.Capture("18", "1", s.cache))
+}
+
+func (s *HoldTestSuite) TestSweep() {
+	s.True(s.service.Reserve(s.fundWith("1", "18", 4000, "phone", ""), 10*time.Minute, s.cache).Accepted())
+	s.True(s.service.Decide(s.fundWith("2", "19", 500, "phone", ""), s.cache).Accepted())
+	s.clock.Advance(10 * time.Minute)
+	s.Equal(0, s.service.Sweep(s.cache))
+	//the sweep leaves the expired hold to the next load, which releases it from the account and the device
+	x, _ := s.cache.Get("18")
+	s.Len(x.(CustomerAccount).Transactions["2020-11-16"], 1)
+	s.True(s.service.Decide(s.fundWith("3", "18", 100, "phone", ""), s.cache).Accepted())
+	x, _ = s.cache.Get("18")
+	s.Len(x.(CustomerAccount).Transactions["2020-11-16"], 1)
+	x, _ = s.cache.Get(entityKey(ByDevice, "phone"))
+	history := x.(EntityHistory)
+	s.Len(history.Transactions["2020-11-16"], 2)
+	s.True(history.NextRelease.IsZero())
+}
+
+func (s *HoldTestSuite) TestHandler() {
+	handler := NewHandler(s.service, validator.New(), s.cache)
+	handler.SetClock(s.clock)
+	detail, _ := handler.ReserveDetail(s.fundWith("1", "18", 100, "phone", ""), 0)
+	s.True(detail.Accepted)
+	s.Require().NotNil(detail.HoldExpiresAt)
+	s.Equal(s.start.Add(DefaultHoldTTL), *detail.HoldExpiresAt)
+	s.NoError(handler.Capture("18", "1"))
+	s.Error(handler.Release("18", "1"))
+	detail, _ = handler.DecideDetail(s.fundWith("2", "18", 100, "phone", ""))
+	s.Nil(detail.HoldExpiresAt)
+}
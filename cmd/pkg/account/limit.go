@@ -0,0 +1,260 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+//Unit is the calendar unit a limit window is measured in
+type Unit string
+
+const (
+	Day     Unit = "day"
+	Week    Unit = "week"
+	Month   Unit = "month"
+	Quarter Unit = "quarter"
+	Year    Unit = "year"
+	//Days is a custom window of Period.Days days, counted from Period.Anchor
+	Days Unit = "days"
+)
+
+//Period describes the window a limit is measured over
+type Period struct {
+	Unit   Unit      `json:"unit"`
+	Days   int       `json:"days,omitempty"`
+	Anchor time.Time `json:"anchor,omitempty"`
+}
+
+//Limit caps the total amount and/or number of loads within a period. A zero MaxAmount or MaxLoads means no cap.
+type Limit struct {
+	Name      string  `json:"name"`
+	Period    Period  `json:"period"`
+	MaxAmount float64 `json:"max_amount,omitempty"`
+	MaxLoads  int     `json:"max_loads,omitempty"`
+	//When is a rule a load must meet for the limit to be checked, every load is checked when it is empty.
+	//See rule.go for the rule language.
+	When string `json:"when,omitempty"`
+	//Weights scale how much each load in the window counts towards the limit, by the first weight whose rule it meets
+	Weights []Weight `json:"weights,omitempty"`
+	//Soft limits flag the loads that exceed them for review instead of declining them
+	Soft bool `json:"soft,omitempty"`
+	//Key is what the limit counts loads by, the customer when it is empty
+	Key Dimension `json:"key,omitempty"`
+}
+
+//Weight scales the amount and number of loads that meet a rule, when counting them towards a limit.
+//An omitted Amount or Count leaves it as is, and a weight of zero leaves the loads out.
+type Weight struct {
+	When   string   `json:"when"`
+	Amount *float64 `json:"amount,omitempty"`
+	Count  *int     `json:"count,omitempty"`
+}
+
+//Policy is the set of limits every load is checked against
+type Policy struct {
+	Version string  `json:"version"`
+	Limits  []Limit `json:"limits"`
+	//MinLoadAmount and MaxLoadAmount bound the amount of a single load, checked before any limit.
+	//Zero means no bound.
+	MinLoadAmount float64 `json:"min_load_amount,omitempty"`
+	MaxLoadAmount float64 `json:"max_load_amount,omitempty"`
+	//Cooldown makes customers wait between loads, checked after the load amount bounds. Nil means no wait.
+	Cooldown *Cooldown `json:"cooldown,omitempty"`
+	//Tier is the tier of the customer a ConfigStore gave the policy for, for rules on customer.tier
+	Tier string `json:"-"`
+	//Status and Blocked are the standing of the customer a ConfigStore gave the policy for, checked before any limit
+	Status  AccountStatus `json:"-"`
+	Blocked bool          `json:"-"`
+}
+
+//DefaultPolicy returns the daily and weekly limits the service has always enforced
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Version: "default",
+		Limits: []Limit{
+			{
+				Name:      "daily",
+				Period:    Period{Unit: Day},
+				MaxAmount: DailyFundLimit,
+				MaxLoads:  DailyNumberOfLoadsLimit,
+			},
+			{
+				Name:      "weekly",
+				Period:    Period{Unit: Week},
+				MaxAmount: WeeklyFundLimit,
+			},
+		},
+	}
+}
+
+//LoadPolicy reads a json policy file and validates it
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePolicy(path, data)
+}
+
+func parsePolicy(path string, data []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("policy %s: %s", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("policy %s: %s", path, err)
+	}
+	return policy, nil
+}
+
+//Validate checks that every limit in the policy can be evaluated
+func (p *Policy) Validate() error {
+	if p.MinLoadAmount < 0 || p.MaxLoadAmount < 0 {
+		return errors.New("load amount bounds must not be negative")
+	}
+	if p.MaxLoadAmount > 0 && p.MinLoadAmount > p.MaxLoadAmount {
+		return fmt.Errorf("min load amount %.2f is more than max load amount %.2f", p.MinLoadAmount, p.MaxLoadAmount)
+	}
+	if p.Cooldown != nil {
+		if err := p.Cooldown.Validate(); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool)
+	for _, limit := range p.Limits {
+		if limit.Name == "" {
+			return errors.New("limit name is required")
+		}
+		if names[limit.Name] {
+			return fmt.Errorf("limit %s is defined more than once", limit.Name)
+		}
+		names[limit.Name] = true
+		if limit.MaxAmount < 0 || limit.MaxLoads < 0 {
+			return fmt.Errorf("limit %s must not be negative", limit.Name)
+		}
+		if err := limit.Period.Validate(); err != nil {
+			return fmt.Errorf("limit %s: %s", limit.Name, err)
+		}
+		if err := limit.Key.Validate(); err != nil {
+			return fmt.Errorf("limit %s: %s", limit.Name, err)
+		}
+		if err := limit.validateRules(); err != nil {
+			return fmt.Errorf("limit %s: %s", limit.Name, err)
+		}
+	}
+	return nil
+}
+
+//validateRules checks the limit's rules parse, and its weights are not negative
+func (l Limit) validateRules() error {
+	if l.When != "" {
+		if _, err := compileRule(l.When); err != nil {
+			return fmt.Errorf("when: %s", err)
+		}
+	}
+	for i, weight := range l.Weights {
+		if _, err := compileRule(weight.When); err != nil {
+			return fmt.Errorf("weight %d: when: %s", i+1, err)
+		}
+		if (weight.Amount != nil && *weight.Amount < 0) || (weight.Count != nil && *weight.Count < 0) {
+			return fmt.Errorf("weight %d must not be negative", i+1)
+		}
+	}
+	return nil
+}
+
+//keyedOn reports whether the fund was made with something the limit counts loads by. Every load has a customer.
+func (l Limit) keyedOn(fund Fund) bool {
+	return fund.value(l.Key) != ""
+}
+
+//applies reports whether the limit is checked for the load. The rules must have been validated.
+func (l Limit) applies(env ruleEnv) bool {
+	if l.When == "" {
+		return true
+	}
+	rule, err := compileRule(l.When)
+	return err == nil && rule.eval(env).b
+}
+
+//weigh returns how much of the load's amount, and how many loads, count towards the limit. The rules must have been validated.
+func (l Limit) weigh(env ruleEnv) (float64, int) {
+	amount, count := env.load.LoadAmount, 1
+	for _, weight := range l.Weights {
+		rule, err := compileRule(weight.When)
+		if err != nil || !rule.eval(env).b {
+			continue
+		}
+		if weight.Amount != nil {
+			amount *= *weight.Amount
+		}
+		if weight.Count != nil {
+			count *= *weight.Count
+		}
+		break
+	}
+	return amount, count
+}
+
+//Validate checks that the period has a known unit and, for custom periods, a length and anchor
+func (p Period) Validate() error {
+	switch p.Unit {
+	case Day, Week, Month, Quarter, Year:
+		return nil
+	case Days:
+		if p.Days <= 0 {
+			return errors.New("custom period needs a positive number of days")
+		}
+		if p.Anchor.IsZero() {
+			return errors.New("custom period needs an anchor date")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown period unit %q", p.Unit)
+}
+
+//Bounds returns the window containing t. Start is inclusive and end is exclusive, both at midnight in t's location.
+func (p Period) Bounds(t time.Time) (start, end time.Time) {
+	day := startOfDay(t)
+	switch p.Unit {
+	case Week:
+		//Weeks start on Monday, and time.Sunday is 0
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case Month:
+		start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(0, 1, 0)
+	case Quarter:
+		month := (day.Month()-1)/3*3 + 1
+		start = time.Date(day.Year(), month, 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(0, 3, 0)
+	case Year:
+		start = time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(1, 0, 0)
+	case Days:
+		anchor := time.Date(p.Anchor.Year(), p.Anchor.Month(), p.Anchor.Day(), 0, 0, 0, 0, day.Location())
+		elapsed := daysBetween(anchor, day)
+		periods := elapsed / p.Days
+		//round towards the earlier period for dates before the anchor
+		if elapsed%p.Days < 0 {
+			periods--
+		}
+		start = anchor.AddDate(0, 0, periods*p.Days)
+		return start, start.AddDate(0, 0, p.Days)
+	}
+	return day, day.AddDate(0, 0, 1)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+//daysBetween counts calendar days from a to b, ignoring any daylight saving shifts in between
+func daysBetween(a, b time.Time) int {
+	from := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
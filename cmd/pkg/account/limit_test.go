@@ -0,0 +1,127 @@
+package account
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PeriodTestSuite struct {
+	suite.Suite
+}
+
+func TestPeriod(t *testing.T) {
+	suite.Run(t, new(PeriodTestSuite))
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func (s *PeriodTestSuite) TestBounds() {
+	anchor := date(2020, 1, 6)
+	cases := []struct {
+		name          string
+		period        Period
+		at            time.Time
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{"day", Period{Unit: Day}, time.Date(2020, 2, 29, 23, 59, 59, 0, time.UTC), date(2020, 2, 29), date(2020, 3, 1)},
+		{"week on monday", Period{Unit: Week}, date(2020, 11, 16), date(2020, 11, 16), date(2020, 11, 23)},
+		{"week on sunday", Period{Unit: Week}, date(2020, 11, 22), date(2020, 11, 16), date(2020, 11, 23)},
+		{"week across year end", Period{Unit: Week}, date(2021, 1, 1), date(2020, 12, 28), date(2021, 1, 4)},
+		{"month end", Period{Unit: Month}, time.Date(2021, 1, 31, 23, 59, 59, 0, time.UTC), date(2021, 1, 1), date(2021, 2, 1)},
+		{"month start", Period{Unit: Month}, date(2021, 2, 1), date(2021, 2, 1), date(2021, 3, 1)},
+		{"leap february", Period{Unit: Month}, date(2020, 2, 29), date(2020, 2, 1), date(2020, 3, 1)},
+		{"non leap february", Period{Unit: Month}, date(2021, 2, 28), date(2021, 2, 1), date(2021, 3, 1)},
+		{"december", Period{Unit: Month}, date(2020, 12, 31), date(2020, 12, 1), date(2021, 1, 1)},
+		{"first quarter", Period{Unit: Quarter}, date(2020, 3, 31), date(2020, 1, 1), date(2020, 4, 1)},
+		{"second quarter", Period{Unit: Quarter}, date(2020, 4, 1), date(2020, 4, 1), date(2020, 7, 1)},
+		{"last quarter", Period{Unit: Quarter}, date(2020, 12, 31), date(2020, 10, 1), date(2021, 1, 1)},
+		{"leap year", Period{Unit: Year}, date(2020, 12, 31), date(2020, 1, 1), date(2021, 1, 1)},
+		{"year start", Period{Unit: Year}, date(2021, 1, 1), date(2021, 1, 1), date(2022, 1, 1)},
+		{"custom on anchor", Period{Unit: Days, Days: 14, Anchor: anchor}, anchor, anchor, date(2020, 1, 20)},
+		{"custom last day", Period{Unit: Days, Days: 14, Anchor: anchor}, date(2020, 1, 19), anchor, date(2020, 1, 20)},
+		{"custom across leap day", Period{Unit: Days, Days: 30, Anchor: date(2020, 2, 1)}, date(2020, 3, 2), date(2020, 3, 2), date(2020, 4, 1)},
+		{"custom before anchor", Period{Unit: Days, Days: 14, Anchor: anchor}, date(2020, 1, 5), date(2019, 12, 23), anchor},
+	}
+	for _, c := range cases {
+		start, end := c.period.Bounds(c.at)
+		if !start.Equal(c.expectedStart) || !end.Equal(c.expectedEnd) {
+			s.T().Errorf("%s: bounds were [%s, %s), expected [%s, %s)", c.name, start, end, c.expectedStart, c.expectedEnd)
+		}
+	}
+}
+
+func (s *PeriodTestSuite) TestBoundsKeepLocation() {
+	loc := time.FixedZone("EST", -5*60*60)
+	start, end := Period{Unit: Month}.Bounds(time.Date(2020, 2, 29, 22, 0, 0, 0, loc))
+	if !start.Equal(time.Date(2020, 2, 1, 0, 0, 0, 0, loc)) || !end.Equal(time.Date(2020, 3, 1, 0, 0, 0, 0, loc)) {
+		s.T().Errorf("bounds were [%s, %s), expected February in %s", start, end, loc)
+	}
+}
+
+func (s *PeriodTestSuite) TestValidatePolicy() {
+	cases := []struct {
+		name    string
+		policy  Policy
+		isValid bool
+	}{
+		{"default", *DefaultPolicy(), true},
+		{"missing name", Policy{Limits: []Limit{{Period: Period{Unit: Day}}}}, false},
+		{"duplicate name", Policy{Limits: []Limit{{Name: "a", Period: Period{Unit: Day}}, {Name: "a", Period: Period{Unit: Week}}}}, false},
+		{"unknown unit", Policy{Limits: []Limit{{Name: "a", Period: Period{Unit: "fortnight"}}}}, false},
+		{"custom without days", Policy{Limits: []Limit{{Name: "a", Period: Period{Unit: Days, Anchor: date(2020, 1, 1)}}}}, false},
+		{"custom without anchor", Policy{Limits: []Limit{{Name: "a", Period: Period{Unit: Days, Days: 10}}}}, false},
+		{"negative amount", Policy{Limits: []Limit{{Name: "a", Period: Period{Unit: Day}, MaxAmount: -1}}}, false},
+		{"load amount bounds", Policy{MinLoadAmount: 10, MaxLoadAmount: 10}, true},
+		{"negative load amount bound", Policy{MinLoadAmount: -1}, false},
+		{"min load amount above max", Policy{MinLoadAmount: 10, MaxLoadAmount: 5}, false},
+	}
+	for _, c := range cases {
+		err := c.policy.Validate()
+		if c.isValid && err != nil {
+			s.T().Errorf("%s: unexpected error %s", c.name, err)
+		}
+		if !c.isValid && err == nil {
+			s.T().Errorf("%s: error was expected, but no error return", c.name)
+		}
+	}
+}
+
+func (s *PeriodTestSuite) TestLoadPolicy() {
+	dir, err := ioutil.TempDir("", "policy")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "policy.json")
+	s.Require().NoError(ioutil.WriteFile(path, []byte(`{
+		"version": "2",
+		"limits": [
+			{"name": "daily", "period": {"unit": "day"}, "max_amount": 4000, "max_loads": 2},
+			{"name": "fortnightly", "period": {"unit": "days", "days": 14, "anchor": "2020-01-06T00:00:00Z"}, "max_amount": 30000}
+		]
+	}`), 0644))
+	policy, err := LoadPolicy(path)
+	s.Require().NoError(err)
+	expected := &Policy{
+		Version: "2",
+		Limits: []Limit{
+			{Name: "daily", Period: Period{Unit: Day}, MaxAmount: 4000, MaxLoads: 2},
+			{Name: "fortnightly", Period: Period{Unit: Days, Days: 14, Anchor: date(2020, 1, 6)}, MaxAmount: 30000},
+		},
+	}
+	if !reflect.DeepEqual(policy, expected) {
+		s.T().Errorf("policy: %+v, expected policy: %+v", policy, expected)
+	}
+
+	s.Require().NoError(ioutil.WriteFile(path, []byte(`{"limits": [{"name": "daily", "period": {"unit": "days"}}]}`), 0644))
+	if _, err = LoadPolicy(path); err == nil {
+		s.T().Error("error was expected for an invalid policy, but no error return")
+	}
+}
@@ -0,0 +1,103 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+//OutOfOrder is how a load older than the newest load already decided for the customer is treated.
+//The newest load time is tracked per customer, and only the customer's own loads are decided again, even for
+//limits keyed on a funding source, device or IP address that count other customers' loads too.
+type OutOfOrder string
+
+const (
+	//AcceptLate checks a late load against the loads before it and records it. Decisions already made for
+	//later loads are left alone, even if the late load would have changed them.
+	AcceptLate OutOfOrder = "accept"
+	//RejectLate declines a load older than the watermark, the newest load time less the allowed lateness.
+	//Loads within the allowed lateness are treated as AcceptLate.
+	RejectLate OutOfOrder = "reject"
+	//Reevaluate decides a late load as if it had arrived in order, then decides every later load again and
+	//reports each one whose decision changed as a correction
+	Reevaluate OutOfOrder = "reevaluate"
+)
+
+//Validate checks that o is one of the known policies, or empty for the default
+func (o OutOfOrder) Validate() error {
+	switch o {
+	case "", AcceptLate, RejectLate, Reevaluate:
+		return nil
+	}
+	return fmt.Errorf("unknown out of order policy %q", o)
+}
+
+//reevaluate rewinds the account to the late load's time, decides it, and replays the later loads after it
+func (a *CustomerAccount) reevaluate(ctx context.Context, fund Fund, policy *Policy) Decision {
+	type decided struct {
+		fund     Fund
+		accepted bool
+	}
+	var later []decided
+	for _, history := range []struct {
+		loads    map[string][]Fund
+		accepted bool
+	}{{a.Transactions, true}, {a.Declined, false}} {
+		for date, loads := range history.loads {
+			kept := loads[:0:0]
+			for _, load := range loads {
+				if load.Time.After(fund.Time) {
+					later = append(later, decided{load, history.accepted})
+					if history.accepted {
+						a.forgetEntities(load)
+					}
+				} else {
+					kept = append(kept, load)
+				}
+			}
+			if len(kept) == 0 {
+				delete(history.loads, date)
+			} else {
+				history.loads[date] = kept
+			}
+		}
+	}
+	sort.SliceStable(later, func(i, j int) bool {
+		if !later[i].fund.Time.Equal(later[j].fund.Time) {
+			return later[i].fund.Time.Before(later[j].fund.Time)
+		}
+		return later[i].fund.ID < later[j].fund.ID
+	})
+	decision := Decision{}
+	decision.Flags, decision.Err = a.checkLimits(ctx, &fund, policy)
+	a.record(fund, decision.Err == nil)
+	for _, load := range later {
+		//a load the customer's standing declined stays declined, whatever the standing is now
+		accepted := false
+		if load.fund.Standing == "" {
+			_, err := a.checkLimits(ctx, &load.fund, policy)
+			accepted = err == nil
+		}
+		a.record(load.fund, accepted)
+		if accepted != load.accepted {
+			decision.Corrections = append(decision.Corrections, Correction{Fund: load.fund, Accepted: accepted})
+		}
+	}
+	return decision
+}
+
+//loadsAfter returns the decided loads later than t, accepted or not
+func (a CustomerAccount) loadsAfter(t time.Time) []Fund {
+	var loads []Fund
+	for _, history := range []map[string][]Fund{a.Transactions, a.Declined} {
+		for _, day := range history {
+			for _, load := range day {
+				if load.Time.After(t) {
+					loads = append(loads, load)
+				}
+			}
+		}
+	}
+	return loads
+}
@@ -0,0 +1,164 @@
+package account
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/quick"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/suite"
+)
+
+type OutOfOrderTestSuite struct {
+	suite.Suite
+	monday time.Time
+	cache  *cache.Cache
+}
+
+func TestOutOfOrder(t *testing.T) {
+	suite.Run(t, new(OutOfOrderTestSuite))
+}
+
+//SetupTest fills the week from Tuesday to Friday up to the weekly limit, leaving Monday empty
+func (s *OutOfOrderTestSuite) SetupTest() {
+	s.monday = time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+	s.cache = cache.New(cache.NoExpiration, 0)
+	for day := 1; day <= 4; day++ {
+		fund := Fund{ID: fmt.Sprint(day), CustomerID: "18", LoadAmount: 5000.00, Time: s.monday.AddDate(0, 0, day)}
+		decision := CustomerAccount{}.Decide(fund, s.cache)
+		s.Require().True(decision.Accepted())
+	}
+}
+
+func (s *OutOfOrderTestSuite) late() Fund {
+	return Fund{ID: "late", CustomerID: "18", LoadAmount: 100.00, Time: s.monday}
+}
+
+func (s *OutOfOrderTestSuite) TestAcceptLateLeavesLaterDecisions() {
+	decision := CustomerAccount{OutOfOrder: AcceptLate}.Decide(s.late(), s.cache)
+	s.True(decision.Accepted(), "nothing before the late load on Monday")
+	s.Empty(decision.Corrections)
+	//the week is now over its limit, which AcceptLate knowingly allows
+	usage := CustomerAccount{}.Usage("18", s.monday.AddDate(0, 0, 6), s.cache)
+	s.Equal(20100.00, usage.Limits[1].UsedAmount)
+}
+
+func (s *OutOfOrderTestSuite) TestDefaultIsAcceptLate() {
+	decision := CustomerAccount{}.Decide(s.late(), s.cache)
+	s.True(decision.Accepted())
+	s.Empty(decision.Corrections)
+}
+
+func (s *OutOfOrderTestSuite) TestRejectLateBeyondWatermark() {
+	service := CustomerAccount{OutOfOrder: RejectLate, Lateness: 24 * time.Hour}
+	decision := service.Decide(s.late(), s.cache)
+	s.False(decision.Accepted())
+	s.False(decision.Duplicate)
+	s.EqualError(decision.Err, "accountID: 18 load is older than watermark 2020-11-19T10:00:00Z when process loadID: late")
+	s.Empty(decision.Corrections)
+
+	//the rejected load is remembered, so it cannot be retried under the same ID
+	decision = service.Decide(s.late(), s.cache)
+	s.True(decision.Duplicate)
+}
+
+func (s *OutOfOrderTestSuite) TestRejectLateWithinLateness() {
+	decision := CustomerAccount{OutOfOrder: RejectLate, Lateness: 7 * 24 * time.Hour}.Decide(s.late(), s.cache)
+	s.True(decision.Accepted())
+	s.Empty(decision.Corrections)
+}
+
+func (s *OutOfOrderTestSuite) TestReevaluateCorrectsLaterDecisions() {
+	service := CustomerAccount{OutOfOrder: Reevaluate}
+	decision := service.Decide(s.late(), s.cache)
+	s.True(decision.Accepted())
+	friday := Fund{ID: "4", CustomerID: "18", LoadAmount: 5000.00, Time: s.monday.AddDate(0, 0, 4)}
+	s.Equal([]Correction{{Fund: friday, Accepted: false, Totals: Totals{Day: 0, Week: 15100.00}}}, decision.Corrections)
+	usage := service.Usage("18", s.monday.AddDate(0, 0, 6), s.cache)
+	s.Equal(15100.00, usage.Limits[1].UsedAmount)
+
+	//a load arriving in order after the correction sees the corrected history
+	saturday := Fund{ID: "5", CustomerID: "18", LoadAmount: 4900.00, Time: s.monday.AddDate(0, 0, 5)}
+	decision = service.Decide(saturday, s.cache)
+	s.True(decision.Accepted())
+	s.Empty(decision.Corrections)
+}
+
+func (s *OutOfOrderTestSuite) TestReevaluateInOrderLoad() {
+	decision := CustomerAccount{OutOfOrder: Reevaluate}.Decide(Fund{ID: "5", CustomerID: "18", LoadAmount: 1.00, Time: s.monday.AddDate(0, 0, 5)}, s.cache)
+	s.False(decision.Accepted())
+	s.Empty(decision.Corrections)
+}
+
+func (s *OutOfOrderTestSuite) TestValidate() {
+	s.NoError(OutOfOrder("").Validate())
+	s.NoError(Reevaluate.Validate())
+	s.Error(OutOfOrder("drop").Validate())
+}
+
+//shuffledSequence is a loadSequence with unique IDs and times, delivered with some loads out of order
+type shuffledSequence struct {
+	inOrder []Fund
+	arrival []Fund
+}
+
+func (shuffledSequence) Generate(r *rand.Rand, size int) reflect.Value {
+	loads := []Fund(loadSequence{}.Generate(r, size).Interface().(loadSequence))
+	seen := make(map[time.Time]bool)
+	var unique []Fund
+	for i, fund := range loads {
+		if seen[fund.Time] {
+			continue
+		}
+		seen[fund.Time] = true
+		fund.ID = fmt.Sprint(i)
+		unique = append(unique, fund)
+	}
+	arrival := append([]Fund{}, unique...)
+	//delay a few loads by swapping them with a later one
+	for i := 0; i < len(arrival)/4; i++ {
+		j := r.Intn(len(arrival))
+		k := j + r.Intn(len(arrival)-j)
+		arrival[j], arrival[k] = arrival[k], arrival[j]
+	}
+	return reflect.ValueOf(shuffledSequence{inOrder: unique, arrival: arrival})
+}
+
+func TestPropertyReevaluateMatchesInOrderDecisions(t *testing.T) {
+	property := func(loads shuffledSequence) bool {
+		model := newReferenceModel()
+		expected := make(map[string]bool)
+		for _, fund := range loads.inOrder {
+			_, expected[fund.ID] = model.load(fund)
+		}
+		c := cache.New(cache.NoExpiration, 0)
+		service := CustomerAccount{OutOfOrder: Reevaluate}
+		actual := make(map[string]bool)
+		for _, fund := range loads.arrival {
+			decision := service.Decide(fund, c)
+			actual[fund.ID] = decision.Accepted()
+			for _, correction := range decision.Corrections {
+				actual[correction.Fund.ID] = correction.Accepted
+			}
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			var ids []string
+			for id := range expected {
+				if expected[id] != actual[id] {
+					ids = append(ids, id)
+				}
+			}
+			sort.Strings(ids)
+			t.Logf("decisions differ from in order processing for loads %v", ids)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,88 @@
+package account
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+)
+
+//PolicyFile is a policy read from a file that is reloaded when the file changes. A change that does not
+//parse or validate is not applied, and the policy read before it stays in force.
+type PolicyFile struct {
+	path    string
+	current atomic.Value
+	//mu serializes reloads
+	mu sync.Mutex
+	//seen is the content last read from the file, whether or not it was applied
+	seen []byte
+}
+
+//NewPolicyFile will read the policy in the file, which must be valid
+func NewPolicyFile(path string) (*PolicyFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := parsePolicy(path, data)
+	if err != nil {
+		return nil, err
+	}
+	f := &PolicyFile{path: path, seen: data}
+	f.current.Store(policy)
+	return f, nil
+}
+
+//Current returns the policy in force
+func (f *PolicyFile) Current() *Policy {
+	return f.current.Load().(*Policy)
+}
+
+//Policy returns the policy in force, which is the same for every customer
+func (f *PolicyFile) Policy(customerID string, now time.Time) *Policy {
+	return f.Current()
+}
+
+func (f *PolicyFile) policies() []*Policy {
+	return []*Policy{f.Current()}
+}
+
+//Reload reads the file again and swaps to its policy if the file has changed, reporting whether it swapped.
+//If the new policy is invalid, the error is returned once and the policy in force is kept until the file changes again.
+func (f *PolicyFile) Reload() (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(data, f.seen) {
+		return false, nil
+	}
+	f.seen = data
+	policy, err := parsePolicy(f.path, data)
+	if err != nil {
+		return false, err
+	}
+	f.current.Store(policy)
+	return true, nil
+}
+
+//Watch reloads the file every interval, as told by the clock, until stop is closed. After each reload that
+//swaps the policy or fails, reloaded is called with the policy in force and the error, if any.
+func (f *PolicyFile) Watch(clk clock.Clock, interval time.Duration, stop <-chan struct{}, reloaded func(*Policy, error)) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-clk.After(interval):
+			swapped, err := f.Reload()
+			if swapped || err != nil {
+				reloaded(f.Current(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package account
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/stretchr/testify/suite"
+)
+
+type PolicyFileTestSuite struct {
+	suite.Suite
+	dir  string
+	path string
+}
+
+func TestPolicyFile(t *testing.T) {
+	suite.Run(t, new(PolicyFileTestSuite))
+}
+
+func (s *PolicyFileTestSuite) SetupTest() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "policy")
+	s.Require().NoError(err)
+	s.path = filepath.Join(s.dir, "policy.json")
+	s.write(`{"version":"v1","limits":[{"name":"daily","period":{"unit":"day"},"max_amount":5000}]}`)
+}
+
+func (s *PolicyFileTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+func (s *PolicyFileTestSuite) write(policy string) {
+	s.Require().NoError(ioutil.WriteFile(s.path, []byte(policy), 0644))
+}
+
+func (s *PolicyFileTestSuite) TestReload() {
+	file, err := NewPolicyFile(s.path)
+	s.Require().NoError(err)
+	c := cache.New(cache.NoExpiration, 0)
+	service := CustomerAccount{Policies: file}
+	monday := time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+	decision := service.Decide(Fund{ID: "1", CustomerID: "18", LoadAmount: 6000.00, Time: monday}, c)
+	s.False(decision.Accepted())
+	s.Equal("v1", decision.PolicyVersion)
+
+	swapped, err := file.Reload()
+	s.NoError(err)
+	s.False(swapped, "the file has not changed")
+
+	s.write(`{"version":"v2","limits":[{"name":"daily","period":{"unit":"day"},"max_amount":10000}]}`)
+	swapped, err = file.Reload()
+	s.NoError(err)
+	s.True(swapped)
+	decision = service.Decide(Fund{ID: "2", CustomerID: "18", LoadAmount: 6000.00, Time: monday}, c)
+	s.True(decision.Accepted())
+	s.Equal("v2", decision.PolicyVersion)
+}
+
+func (s *PolicyFileTestSuite) TestInvalidPolicyIsNotApplied() {
+	file, err := NewPolicyFile(s.path)
+	s.Require().NoError(err)
+	s.write(`{"version":"v2","limits":[{"name":"daily","period":{"unit":"fortnight"}}]}`)
+	swapped, err := file.Reload()
+	s.False(swapped)
+	s.EqualError(err, "policy "+s.path+`: limit daily: unknown period unit "fortnight"`)
+	s.Equal("v1", file.Current().Version)
+
+	//the error is reported once, not on every reload of the same file
+	swapped, err = file.Reload()
+	s.False(swapped)
+	s.NoError(err)
+
+	s.write(`{"version":"v2",`)
+	_, err = file.Reload()
+	s.Error(err)
+	s.Equal("v1", file.Current().Version)
+
+	s.write(`{"version":"v3","limits":[]}`)
+	swapped, err = file.Reload()
+	s.NoError(err)
+	s.True(swapped)
+	s.Equal("v3", file.Current().Version)
+}
+
+func (s *PolicyFileTestSuite) TestNewPolicyFileMustBeValid() {
+	s.write(`{"limits":[{"name":""}]}`)
+	_, err := NewPolicyFile(s.path)
+	s.Error(err)
+	_, err = NewPolicyFile(filepath.Join(s.dir, "missing.json"))
+	s.Error(err)
+}
+
+func (s *PolicyFileTestSuite) TestWatch() {
+	file, err := NewPolicyFile(s.path)
+	s.Require().NoError(err)
+	fake := clock.NewFake(time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	type reload struct {
+		version string
+		err     error
+	}
+	reloads := make(chan reload, 1)
+	go func() {
+		file.Watch(fake, time.Second, stop, func(policy *Policy, err error) {
+			reloads <- reload{version: policy.Version, err: err}
+		})
+		close(done)
+	}()
+	tick := func() {
+		for fake.Waiters() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		fake.Advance(time.Second)
+	}
+
+	s.write(`{"version":"v2","limits":[]}`)
+	tick()
+	s.Equal(reload{version: "v2"}, <-reloads)
+
+	s.write(`{"version":"v3","limits":[{}]}`)
+	tick()
+	r := <-reloads
+	s.Equal("v2", r.version)
+	s.EqualError(r.err, "policy "+s.path+": limit name is required")
+
+	close(stop)
+	<-done
+}
+
+func (s *PolicyFileTestSuite) TestReloadConfigStore() {
+	store := NewConfigStore(DefaultPolicy())
+	policy := Policy{Version: "v2", Limits: DefaultPolicy().Limits}
+	reloaded, err := store.ReloadPolicy("policy-file", "reloaded", DefaultTier, policy)
+	s.Require().NoError(err)
+	s.Equal("default@1", reloaded.Version)
+	//a file changed without changing its version is still told apart
+	policy.Limits = policy.Limits[:1]
+	reloaded, err = store.ReloadPolicy("policy-file", "reloaded", DefaultTier, policy)
+	s.Require().NoError(err)
+	s.Equal("default@2", reloaded.Version)
+	s.Equal("reload_policy", store.Changes()[1].Action)
+}
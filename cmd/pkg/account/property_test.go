@@ -0,0 +1,193 @@
+package account
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/quick"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+//loadSequence is a randomly generated, time ordered run of loads for a handful of customers.
+//Load IDs are drawn from a small pool so duplicates are common.
+type loadSequence []Fund
+
+//start is a Thursday late in the year, so sequences cross week, month and year boundaries
+var start = time.Date(2020, 12, 24, 0, 0, 0, 0, time.UTC)
+
+func (loadSequence) Generate(r *rand.Rand, size int) reflect.Value {
+	loads := make(loadSequence, r.Intn(size*4+1))
+	offsets := make([]int, len(loads))
+	for i := range offsets {
+		//spread the loads over three weeks
+		offsets[i] = r.Intn(21 * 24 * 60)
+	}
+	sort.Ints(offsets)
+	for i := range loads {
+		loads[i] = Fund{
+			ID:         fmt.Sprint(r.Intn(size*4 + 1)),
+			CustomerID: fmt.Sprint(r.Intn(3)),
+			//amounts in whole cents up to $6,000 so single loads can exceed the daily limit
+			LoadAmount: float64(r.Intn(600000)+1) / 100,
+			Time:       start.Add(time.Duration(offsets[i]) * time.Minute),
+		}
+	}
+	return reflect.ValueOf(loads)
+}
+
+//referenceModel decides loads with the default limits the simplest way possible, by rescanning
+//every accepted load in integer cents, so it shares no code with CustomerAccount
+type referenceModel struct {
+	seen     map[string]bool
+	accepted []Fund
+}
+
+func newReferenceModel() *referenceModel {
+	return &referenceModel{seen: make(map[string]bool)}
+}
+
+//load returns whether the fund is a duplicate, and if not whether it is accepted
+func (m *referenceModel) load(fund Fund) (duplicate, accepted bool) {
+	key := fund.CustomerID + "/" + fund.ID
+	if m.seen[key] {
+		return true, false
+	}
+	m.seen[key] = true
+	var dayCents, weekCents int64
+	var dayLoads int
+	for _, load := range m.accepted {
+		if load.CustomerID != fund.CustomerID {
+			continue
+		}
+		if sameDay(load.Time, fund.Time) {
+			dayCents += cents(load.LoadAmount)
+			dayLoads++
+		}
+		if sameWeek(load.Time, fund.Time) {
+			weekCents += cents(load.LoadAmount)
+		}
+	}
+	amount := cents(fund.LoadAmount)
+	if dayLoads >= DailyNumberOfLoadsLimit ||
+		dayCents+amount > cents(DailyFundLimit) ||
+		weekCents+amount > cents(WeeklyFundLimit) {
+		return false, false
+	}
+	m.accepted = append(m.accepted, fund)
+	return false, true
+}
+
+func cents(amount float64) int64 {
+	return int64(amount*100 + 0.5)
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func sameWeek(a, b time.Time) bool {
+	monday := func(t time.Time) time.Time {
+		for t.Weekday() != time.Monday {
+			t = t.AddDate(0, 0, -1)
+		}
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return monday(a).Equal(monday(b))
+}
+
+var quickConfig = &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(1))}
+
+func TestPropertyMatchesReferenceModel(t *testing.T) {
+	property := func(loads loadSequence) bool {
+		c := cache.New(cache.NoExpiration, 0)
+		model := newReferenceModel()
+		for _, fund := range loads {
+			exists, err := CustomerAccount{}.LoadFund(fund, c)
+			duplicate, accepted := model.load(fund)
+			if exists != duplicate || (!exists && (err == nil) != accepted) {
+				t.Logf("load %+v: service returned exists=%v err=%v, model returned duplicate=%v accepted=%v",
+					fund, exists, err, duplicate, accepted)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyAcceptedLoadsNeverExceedLimits(t *testing.T) {
+	property := func(loads loadSequence) bool {
+		c := cache.New(cache.NoExpiration, 0)
+		var accepted []Fund
+		for _, fund := range loads {
+			if exists, err := (CustomerAccount{}).LoadFund(fund, c); !exists && err == nil {
+				accepted = append(accepted, fund)
+			}
+		}
+		for _, limit := range DefaultPolicy().Limits {
+			type window struct {
+				customerID string
+				start      time.Time
+			}
+			amounts := make(map[window]int64)
+			counts := make(map[window]int)
+			for _, fund := range accepted {
+				windowStart, _ := limit.Period.Bounds(fund.Time)
+				w := window{fund.CustomerID, windowStart}
+				amounts[w] += cents(fund.LoadAmount)
+				counts[w]++
+				if limit.MaxAmount > 0 && amounts[w] > cents(limit.MaxAmount) {
+					t.Logf("%s limit exceeded for customer %s in window starting %s", limit.Name, w.customerID, w.start)
+					return false
+				}
+				if limit.MaxLoads > 0 && counts[w] > limit.MaxLoads {
+					t.Logf("%s number of loads exceeded for customer %s in window starting %s", limit.Name, w.customerID, w.start)
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyDuplicatesNeverChangeState(t *testing.T) {
+	property := func(loads loadSequence, replay uint) bool {
+		if len(loads) == 0 {
+			return true
+		}
+		c := cache.New(cache.NoExpiration, 0)
+		for _, fund := range loads {
+			CustomerAccount{}.LoadFund(fund, c)
+		}
+		//replay an earlier load, possibly with a different amount and time, under the same ID
+		duplicate := loads[replay%uint(len(loads))]
+		duplicate.LoadAmount = 1.00
+		duplicate.Time = duplicate.Time.Add(time.Hour)
+		before, _ := c.Get(duplicate.CustomerID)
+		exists, err := CustomerAccount{}.LoadFund(duplicate, c)
+		after, _ := c.Get(duplicate.CustomerID)
+		if !exists || err == nil {
+			t.Logf("duplicate %+v was not detected", duplicate)
+			return false
+		}
+		if !reflect.DeepEqual(before, after) {
+			t.Logf("duplicate %+v changed account from %+v to %+v", duplicate, before, after)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, quickConfig); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,83 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+)
+
+//Reason is a code for why a load was declined, stable for clients to act on unlike the error message
+type Reason string
+
+const (
+	//ReasonWindowLimit declines a load that would take the customer past a limit on the amount or number
+	//of loads in a window
+	ReasonWindowLimit Reason = "window_limit"
+	//ReasonInvalidAmount declines a load whose amount is not a positive, finite number
+	ReasonInvalidAmount Reason = "invalid_amount"
+	//ReasonBelowMinAmount declines a load smaller than the policy allows for a single load
+	ReasonBelowMinAmount Reason = "below_min_amount"
+	//ReasonAboveMaxAmount declines a load larger than the policy allows for a single load
+	ReasonAboveMaxAmount Reason = "above_max_amount"
+	//ReasonCooldown declines a load made too soon after the customer's other loads
+	ReasonCooldown Reason = "cooldown"
+	//ReasonLate declines a load older than the customer's watermark
+	ReasonLate Reason = "late"
+	//ReasonInvalidRule declines a load checked against a limit whose rules do not parse
+	ReasonInvalidRule Reason = "invalid_rule"
+	//ReasonBlocked declines a load for a customer on the blocklist
+	ReasonBlocked Reason = "customer_blocked"
+	//ReasonFrozen declines a load for a frozen account
+	ReasonFrozen Reason = "account_frozen"
+	//ReasonClosed declines a load for a closed account
+	ReasonClosed Reason = "account_closed"
+)
+
+//Outcome is what became of a load
+type Outcome string
+
+const (
+	OutcomeAccepted Outcome = "accepted"
+	//OutcomeAcceptedFlagged is a load that was accepted, but exceeded a soft limit and should be reviewed
+	OutcomeAcceptedFlagged Outcome = "accepted_flagged"
+	OutcomeDeclined        Outcome = "declined"
+)
+
+//Flag is a soft limit an accepted load exceeded
+type Flag struct {
+	Limit   string `json:"limit"`
+	Reason  Reason `json:"reason"`
+	Message string `json:"message"`
+}
+
+//flag returns the flag for a load that failed a check against a soft limit
+func flag(limit Limit, err error) Flag {
+	return Flag{Limit: limit.Name, Reason: ReasonOf(err), Message: err.Error()}
+}
+
+//DeclineError is the error a load is declined with, along with the reason code
+type DeclineError struct {
+	Reason Reason
+	Err    error
+}
+
+func (e *DeclineError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeclineError) Unwrap() error {
+	return e.Err
+}
+
+//decline returns a DeclineError for the reason, with a message formatted like fmt.Errorf
+func decline(reason Reason, format string, args ...interface{}) error {
+	return &DeclineError{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+//ReasonOf returns the reason code of a decline error, or an empty Reason for any other error
+func ReasonOf(err error) Reason {
+	var declined *DeclineError
+	if errors.As(err, &declined) {
+		return declined.Reason
+	}
+	return ""
+}
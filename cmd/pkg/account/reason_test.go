@@ -0,0 +1,115 @@
+package account
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ReasonTestSuite struct {
+	suite.Suite
+	fixture
+}
+
+func TestReason(t *testing.T) {
+	suite.Run(t, new(ReasonTestSuite))
+}
+
+func (s *ReasonTestSuite) SetupTest() {
+	s.setup()
+}
+
+func bounded(min, max float64) *Policy {
+	policy := DefaultPolicy()
+	policy.MinLoadAmount = min
+	policy.MaxLoadAmount = max
+	return policy
+}
+
+func (s *ReasonTestSuite) TestLoadAmountBounds() {
+	service := CustomerAccount{Policy: bounded(10.00, 2500.00)}
+	decision := service.Decide(s.fund("1", "18", 9.99), s.cache)
+	s.False(decision.Accepted())
+	s.Equal(ReasonBelowMinAmount, decision.Reason())
+	s.EqualError(decision.Err, "accountID: 18 load amount 9.99 is below the minimum of 10.00 when process loadID: 1")
+
+	decision = service.Decide(s.fund("2", "18", 2500.01), s.cache)
+	s.Equal(ReasonAboveMaxAmount, decision.Reason())
+	s.EqualError(decision.Err, "accountID: 18 load amount 2500.01 is above the maximum of 2500.00 when process loadID: 2")
+
+	for _, id := range []string{"3", "4"} {
+		decision = service.Decide(s.fund(id, "18", 2500.00), s.cache)
+		s.True(decision.Accepted())
+		s.Empty(decision.Reason())
+	}
+	decision = service.Decide(s.fund("5", "18", 10.00), s.cache)
+	s.Equal(ReasonWindowLimit, decision.Reason())
+}
+
+func (s *ReasonTestSuite) TestInvalidAmounts() {
+	//without bounds on a single load, nothing else stops these
+	service := CustomerAccount{Policy: bounded(0, 0)}
+	for name, amount := range map[string]float64{
+		"nan":               math.NaN(),
+		"positive infinity": math.Inf(1),
+		"negative infinity": math.Inf(-1),
+		"negative":          -100.00,
+		"zero":              0,
+	} {
+		s.Run(name, func() {
+			decision := service.Decide(s.fund(name, "18", amount), s.cache)
+			s.False(decision.Accepted())
+			s.Equal(ReasonInvalidAmount, decision.Reason())
+		})
+	}
+	s.Run("bounded nan", func() {
+		decision := CustomerAccount{Policy: bounded(10.00, 2500.00)}.Decide(s.fund("bounded", "18", math.NaN()), s.cache)
+		s.Equal(ReasonInvalidAmount, decision.Reason())
+	})
+	s.Equal(0.00, service.Usage("18", s.start, s.cache).Limits[0].UsedAmount)
+}
+
+func (s *ReasonTestSuite) TestBoundsAreCheckedBeforeWindowLimits() {
+	service := CustomerAccount{Policy: bounded(0, 4000.00)}
+	//the load would also exceed the daily limit, but the bound on a single load is reported
+	decision := service.Decide(s.fund("1", "18", 5000.01), s.cache)
+	s.Equal(ReasonAboveMaxAmount, decision.Reason())
+
+	evaluation := service.Evaluate(s.fund("2", "18", 5000.01), s.cache)
+	s.False(evaluation.Accepted)
+	s.Equal(ReasonAboveMaxAmount, evaluation.ReasonCode)
+	s.Len(evaluation.Limits, 2, "every limit still reports its headroom")
+	s.False(evaluation.Limits[0].Passed)
+}
+
+func (s *ReasonTestSuite) TestBoundsPerTier() {
+	store := NewConfigStore(DefaultPolicy())
+	_, err := store.CreatePolicy("alice", "small loads only", "starter", *bounded(0, 100.00))
+	s.Require().NoError(err)
+	s.Require().NoError(store.CreateTier("alice", "small loads only", Tier{Name: "starter", Policy: "starter"}))
+	s.Require().NoError(store.AssignTier("alice", "new customer", "18", "starter"))
+	service := CustomerAccount{Policies: store}
+
+	decision := service.Decide(s.fund("1", "18", 100.01), s.cache)
+	s.Equal(ReasonAboveMaxAmount, decision.Reason())
+	decision = service.Decide(s.fund("2", "19", 100.01), s.cache)
+	s.True(decision.Accepted())
+}
+
+func (s *ReasonTestSuite) TestOtherReasons() {
+	service := CustomerAccount{OutOfOrder: RejectLate}
+	s.True(service.Decide(s.fund("1", "18", 100.00), s.cache).Accepted())
+	late := s.fund("2", "18", 100.00)
+	late.Time = s.start.Add(-time.Hour)
+	s.Equal(ReasonLate, service.Decide(late, s.cache).Reason())
+
+	decision := service.Decide(s.fund("1", "18", 100.00), s.cache)
+	s.True(decision.Duplicate)
+	s.Empty(decision.Reason())
+
+	s.Empty(ReasonOf(errors.New("some error")))
+	s.Equal(ReasonInvalidRule, ReasonOf(CustomerAccount{}.checkRules(&Fund{}, Limit{When: "load.amount >"})))
+}
@@ -0,0 +1,109 @@
+package account
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+//DefaultReviewQueueSize is how many flagged loads a ReviewQueue holds unless told otherwise
+const DefaultReviewQueueSize = 10000
+
+//ReviewQueue collects the loads that were accepted but flagged by a soft limit, for someone to review.
+//Loads leave the queue once they are acknowledged, or when it is full and a newer load is added, so the
+//log it writes to is the full history.
+type ReviewQueue struct {
+	mu    sync.Mutex
+	loads []FundDetail
+	size  int
+	w     io.Writer
+}
+
+//NewReviewQueue will create an empty ReviewQueue that holds up to DefaultReviewQueueSize loads
+func NewReviewQueue() *ReviewQueue {
+	return &ReviewQueue{size: DefaultReviewQueueSize}
+}
+
+//SetSize will make the queue hold up to size loads, dropping the oldest ones it holds beyond that
+func (q *ReviewQueue) SetSize(size int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.size = size
+	q.trim()
+}
+
+//LogTo will also write every flagged load to w as a line of json, as it is added
+func (q *ReviewQueue) LogTo(w io.Writer) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.w = w
+}
+
+//Add queues a flagged load, dropping the oldest load if the queue is full. The load stays queued even if
+//it cannot be written to the log.
+func (q *ReviewQueue) Add(load FundDetail) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.loads = append(q.loads, load)
+	q.trim()
+	if q.w == nil {
+		return nil
+	}
+	line, err := json.Marshal(load)
+	if err != nil {
+		return err
+	}
+	_, err = q.w.Write(append(line, '\n'))
+	return err
+}
+
+//trim drops the oldest loads beyond the queue's size
+func (q *ReviewQueue) trim() {
+	if q.size > 0 && len(q.loads) > q.size {
+		q.loads = append([]FundDetail{}, q.loads[len(q.loads)-q.size:]...)
+	}
+}
+
+//Acknowledge removes the customer's load from the queue once it has been reviewed, and returns it.
+//It fails with a NotFoundError when the load is not queued.
+func (q *ReviewQueue) Acknowledge(customerID, loadID string) (FundDetail, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, load := range q.loads {
+		if load.CustomerID == customerID && load.ID == loadID {
+			q.loads = append(q.loads[:i:i], q.loads[i+1:]...)
+			return load, nil
+		}
+	}
+	return FundDetail{}, NotFoundError{Kind: "flagged load", Name: loadID}
+}
+
+//Loads returns the flagged loads, oldest first
+func (q *ReviewQueue) Loads() []FundDetail {
+	return q.Page(0, 0)
+}
+
+//Page returns up to limit of the flagged loads, oldest first, skipping the first offset of them.
+//A limit that is not positive returns every load after offset.
+func (q *ReviewQueue) Page(offset, limit int) []FundDetail {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(q.loads) {
+		offset = len(q.loads)
+	}
+	end := len(q.loads)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return append([]FundDetail{}, q.loads[offset:end]...)
+}
+
+//Len returns how many flagged loads are queued
+func (q *ReviewQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.loads)
+}
@@ -0,0 +1,131 @@
+package account
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type ReviewTestSuite struct {
+	suite.Suite
+	fixture
+	service CustomerAccount
+}
+
+func TestReview(t *testing.T) {
+	suite.Run(t, new(ReviewTestSuite))
+}
+
+func (s *ReviewTestSuite) SetupTest() {
+	s.setup()
+	policy := DefaultPolicy()
+	policy.Limits = append(policy.Limits, Limit{Name: "daily_review", Period: Period{Unit: Day}, MaxAmount: 3000.00, Soft: true})
+	s.service = CustomerAccount{Policy: policy}
+}
+
+func (s *ReviewTestSuite) TestSoftLimitFlags() {
+	decision := s.service.Decide(s.fund("1", "18", 3000.00), s.cache)
+	s.Equal(OutcomeAccepted, decision.Outcome())
+	s.Empty(decision.Flags)
+
+	decision = s.service.Decide(s.fund("2", "18", 1000.00), s.cache)
+	s.True(decision.Accepted())
+	s.Equal(OutcomeAcceptedFlagged, decision.Outcome())
+	s.Equal([]Flag{{
+		Limit:   "daily_review",
+		Reason:  ReasonWindowLimit,
+		Message: "accountID: 18 exceed daily_review fund limit when process loadID: 2",
+	}}, decision.Flags)
+	s.Equal(4000.00, decision.Totals.Day, "a flagged load counts towards the limits")
+
+	decision = s.service.Decide(s.fund("3", "18", 1000.01), s.cache)
+	s.Equal(OutcomeDeclined, decision.Outcome())
+	s.Empty(decision.Flags, "a declined load is not flagged")
+}
+
+func (s *ReviewTestSuite) TestEvaluateFlags() {
+	evaluation := s.service.Evaluate(s.fund("1", "18", 3000.01), s.cache)
+	s.True(evaluation.Accepted)
+	s.Empty(evaluation.Reason)
+	s.Require().Len(evaluation.Flags, 1)
+	s.Equal("daily_review", evaluation.Flags[0].Limit)
+	s.False(evaluation.Limits[2].Passed)
+}
+
+func (s *ReviewTestSuite) TestReviewQueue() {
+	handler := NewHandler(s.service, validator.New(), s.cache)
+	var log bytes.Buffer
+	queue := NewReviewQueue()
+	queue.LogTo(&log)
+	handler.SetReviewQueue(queue)
+	s.Equal(queue, handler.ReviewQueue())
+
+	handler.DecideDetail(s.fund("1", "18", 2000.00))
+	flagged, _ := handler.DecideDetail(s.fund("2", "18", 2000.00))
+	handler.DecideDetail(s.fund("3", "18", 2000.00))
+	s.Equal(OutcomeAcceptedFlagged, flagged.Outcome)
+	s.Equal([]FundDetail{flagged}, queue.Loads())
+
+	var logged FundDetail
+	s.Require().NoError(json.Unmarshal(log.Bytes(), &logged))
+	s.Equal("2", logged.ID)
+	s.Equal(flagged.Flags, logged.Flags)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func (s *ReviewTestSuite) TestLogError() {
+	queue := NewReviewQueue()
+	queue.LogTo(failingWriter{})
+	s.EqualError(queue.Add(FundDetail{FundResponse: FundResponse{ID: "1"}}), "disk full")
+	s.Len(queue.Loads(), 1, "the load stays queued")
+}
+
+func (s *ReviewTestSuite) TestAcknowledge() {
+	queue := NewReviewQueue()
+	for _, id := range []string{"1", "2", "3"} {
+		s.Require().NoError(queue.Add(FundDetail{FundResponse: FundResponse{ID: id, CustomerID: "18"}}))
+	}
+	load, err := queue.Acknowledge("18", "2")
+	s.Require().NoError(err)
+	s.Equal("2", load.ID)
+	s.Equal([]string{"1", "3"}, ids(queue.Loads()))
+	_, err = queue.Acknowledge("18", "2")
+	s.Equal(NotFoundError{Kind: "flagged load", Name: "2"}, err)
+	_, err = queue.Acknowledge("19", "1")
+	s.Error(err, "load IDs are only unique per customer")
+}
+
+func (s *ReviewTestSuite) TestSizeAndPage() {
+	var log bytes.Buffer
+	queue := NewReviewQueue()
+	queue.LogTo(&log)
+	queue.SetSize(3)
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		s.Require().NoError(queue.Add(FundDetail{FundResponse: FundResponse{ID: id, CustomerID: "18"}}))
+	}
+	s.Equal(3, queue.Len())
+	s.Equal([]string{"3", "4", "5"}, ids(queue.Loads()), "the oldest loads are dropped")
+	s.Equal(5, bytes.Count(log.Bytes(), []byte("\n")), "but stay on the log")
+	s.Equal([]string{"4"}, ids(queue.Page(1, 1)))
+	s.Equal([]string{"4", "5"}, ids(queue.Page(1, 0)))
+	s.Empty(queue.Page(7, 1))
+	queue.SetSize(1)
+	s.Equal([]string{"5"}, ids(queue.Loads()))
+}
+
+func ids(loads []FundDetail) []string {
+	ids := []string{}
+	for _, load := range loads {
+		ids = append(ids, load.ID)
+	}
+	return ids
+}
@@ -0,0 +1,497 @@
+package account
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+//A rule is a boolean expression over a load and its customer, like
+//
+//	customer.age_days < 7 and load.day_of_week in ["saturday", "sunday"]
+//
+//Rules combine comparisons with and, or, not and parentheses. A comparison is ==, !=, <, <=, > or >= between
+//two values of the same type, or in to test a value against a list of literals. Values are fields of the load
+//and customer, numbers, quoted strings, true and false. Strings compare in lexical order, so times of day
+//written like "09:30" compare in time order.
+//
+//The fields are:
+//
+//	load.amount        number, the amount loaded
+//	load.hour          number, the hour of the day from 0 to 23
+//	load.time_of_day   string, the time of day like "23:05"
+//	load.day_of_week   string, the lower case day name like "monday"
+//	load.weekend       bool, whether the load is on a saturday or sunday
+//	customer.id        string
+//	customer.tier      string, the customer's tier, empty unless policies come from a ConfigStore
+//	customer.age_days  number, whole days from the customer's first load to the load
+//
+//Times of day and days of the week are in the load time's own offset, like limit windows.
+
+//ruleKind is the type of a value in a rule
+type ruleKind int
+
+const (
+	ruleBool ruleKind = iota
+	ruleNumber
+	ruleString
+)
+
+func (k ruleKind) String() string {
+	switch k {
+	case ruleNumber:
+		return "number"
+	case ruleString:
+		return "string"
+	}
+	return "bool"
+}
+
+type ruleValue struct {
+	b   bool
+	num float64
+	str string
+}
+
+//ruleEnv is what a rule is evaluated against: a load, and the customer as of that load
+type ruleEnv struct {
+	load      Fund
+	tier      string
+	firstLoad time.Time
+}
+
+type ruleField struct {
+	kind ruleKind
+	get  func(env ruleEnv) ruleValue
+}
+
+var ruleFields = map[string]ruleField{
+	"load.amount": {ruleNumber, func(env ruleEnv) ruleValue { return ruleValue{num: env.load.LoadAmount} }},
+	"load.hour":   {ruleNumber, func(env ruleEnv) ruleValue { return ruleValue{num: float64(env.load.Time.Hour())} }},
+	"load.time_of_day": {ruleString, func(env ruleEnv) ruleValue {
+		return ruleValue{str: env.load.Time.Format("15:04")}
+	}},
+	"load.day_of_week": {ruleString, func(env ruleEnv) ruleValue {
+		return ruleValue{str: strings.ToLower(env.load.Time.Weekday().String())}
+	}},
+	"load.weekend": {ruleBool, func(env ruleEnv) ruleValue {
+		day := env.load.Time.Weekday()
+		return ruleValue{b: day == time.Saturday || day == time.Sunday}
+	}},
+	"customer.id":   {ruleString, func(env ruleEnv) ruleValue { return ruleValue{str: env.load.CustomerID} }},
+	"customer.tier": {ruleString, func(env ruleEnv) ruleValue { return ruleValue{str: env.tier} }},
+	"customer.age_days": {ruleNumber, func(env ruleEnv) ruleValue {
+		if env.firstLoad.IsZero() || env.load.Time.Before(env.firstLoad) {
+			return ruleValue{}
+		}
+		return ruleValue{num: float64(daysBetween(env.firstLoad, env.load.Time))}
+	}},
+}
+
+//ruleNode is a parsed expression, whose type is checked as it is parsed
+type ruleNode interface {
+	kind() ruleKind
+	eval(env ruleEnv) ruleValue
+}
+
+type ruleLiteral struct {
+	k ruleKind
+	v ruleValue
+}
+
+func (n ruleLiteral) kind() ruleKind             { return n.k }
+func (n ruleLiteral) eval(env ruleEnv) ruleValue { return n.v }
+
+type ruleFieldNode struct {
+	field ruleField
+}
+
+func (n ruleFieldNode) kind() ruleKind             { return n.field.kind }
+func (n ruleFieldNode) eval(env ruleEnv) ruleValue { return n.field.get(env) }
+
+type ruleNot struct {
+	operand ruleNode
+}
+
+func (n ruleNot) kind() ruleKind { return ruleBool }
+func (n ruleNot) eval(env ruleEnv) ruleValue {
+	return ruleValue{b: !n.operand.eval(env).b}
+}
+
+type ruleLogical struct {
+	and         bool
+	left, right ruleNode
+}
+
+func (n ruleLogical) kind() ruleKind { return ruleBool }
+func (n ruleLogical) eval(env ruleEnv) ruleValue {
+	left := n.left.eval(env).b
+	if left != n.and {
+		return ruleValue{b: left}
+	}
+	return n.right.eval(env)
+}
+
+type ruleCompare struct {
+	op          string
+	left, right ruleNode
+}
+
+func (n ruleCompare) kind() ruleKind { return ruleBool }
+func (n ruleCompare) eval(env ruleEnv) ruleValue {
+	left, right := n.left.eval(env), n.right.eval(env)
+	var order int
+	switch n.left.kind() {
+	case ruleNumber:
+		order = compareNumbers(left.num, right.num)
+	case ruleString:
+		order = strings.Compare(left.str, right.str)
+	default:
+		if left.b != right.b {
+			order = 1
+		}
+	}
+	switch n.op {
+	case "==":
+		return ruleValue{b: order == 0}
+	case "!=":
+		return ruleValue{b: order != 0}
+	case "<":
+		return ruleValue{b: order < 0}
+	case "<=":
+		return ruleValue{b: order <= 0}
+	case ">":
+		return ruleValue{b: order > 0}
+	}
+	return ruleValue{b: order >= 0}
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type ruleIn struct {
+	operand ruleNode
+	list    []ruleValue
+}
+
+func (n ruleIn) kind() ruleKind { return ruleBool }
+func (n ruleIn) eval(env ruleEnv) ruleValue {
+	value := n.operand.eval(env)
+	for _, item := range n.list {
+		if item == value {
+			return ruleValue{b: true}
+		}
+	}
+	return ruleValue{}
+}
+
+//RuleError is a rule that cannot be parsed, with the column of the rule it went wrong at, counting from 1
+type RuleError struct {
+	Column int
+	Msg    string
+}
+
+func (e RuleError) Error() string {
+	return fmt.Sprintf("at column %d: %s", e.Column, e.Msg)
+}
+
+type ruleToken struct {
+	text string
+	//pos is the byte offset of the token in the rule
+	pos int
+	//quoted marks a string literal, whose text is unquoted
+	quoted bool
+}
+
+func lexRule(src string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], src[i])
+			if end < 0 {
+				return nil, RuleError{Column: i + 1, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, ruleToken{text: src[i+1 : i+1+end], pos: i, quoted: true})
+			i += end + 2
+		case strings.ContainsRune("()[],", c):
+			tokens = append(tokens, ruleToken{text: string(c), pos: i})
+			i++
+		case strings.ContainsRune("=!<>", c):
+			if i+1 < len(src) && src[i+1] == '=' {
+				tokens = append(tokens, ruleToken{text: src[i : i+2], pos: i})
+				i += 2
+			} else if c == '<' || c == '>' {
+				tokens = append(tokens, ruleToken{text: string(c), pos: i})
+				i++
+			} else {
+				return nil, RuleError{Column: i + 1, Msg: fmt.Sprintf("unexpected %q, did you mean %q", string(c), string(c)+"=")}
+			}
+		case c == '_' || c == '.' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] == '.' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || (i == start && src[i] == '-')) {
+				i++
+			}
+			tokens = append(tokens, ruleToken{text: src[start:i], pos: start})
+		default:
+			return nil, RuleError{Column: i + 1, Msg: fmt.Sprintf("unexpected %q", string(c))}
+		}
+	}
+	return tokens, nil
+}
+
+var ruleKeywords = map[string]bool{"and": true, "or": true, "not": true, "in": true, "true": true, "false": true}
+
+type ruleParser struct {
+	src    string
+	tokens []ruleToken
+	next   int
+}
+
+func (p *ruleParser) peek() (ruleToken, bool) {
+	if p.next >= len(p.tokens) {
+		return ruleToken{pos: len(p.src)}, false
+	}
+	return p.tokens[p.next], true
+}
+
+//accept consumes the next token if it is the given keyword or symbol
+func (p *ruleParser) accept(text string) bool {
+	if token, ok := p.peek(); ok && !token.quoted && token.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) errorf(token ruleToken, format string, args ...interface{}) error {
+	return RuleError{Column: token.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+//unexpected reports what was found where something else was expected
+func (p *ruleParser) unexpected(expected string) error {
+	token, ok := p.peek()
+	if !ok {
+		return p.errorf(token, "expected %s, found end of rule", expected)
+	}
+	return p.errorf(token, "expected %s, found %q", expected, token.text)
+}
+
+//parseRule parses and type checks a rule, which must be boolean
+func parseRule(src string) (ruleNode, error) {
+	tokens, err := lexRule(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &ruleParser{src: src, tokens: tokens}
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.peek(); ok {
+		return nil, p.unexpected(`"and", "or" or end of rule`)
+	}
+	if node.kind() != ruleBool {
+		return nil, RuleError{Column: 1, Msg: fmt.Sprintf("rule is a %s, not a condition", node.kind())}
+	}
+	return node, nil
+}
+
+func (p *ruleParser) or() (ruleNode, error) {
+	return p.logical("or", p.and)
+}
+
+func (p *ruleParser) and() (ruleNode, error) {
+	return p.logical("and", p.not)
+}
+
+func (p *ruleParser) logical(op string, operand func() (ruleNode, error)) (ruleNode, error) {
+	start, _ := p.peek()
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, _ := p.peek()
+		if !p.accept(op) {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != ruleBool {
+			return nil, p.errorf(start, "%q needs a condition on its left, not a %s", op, left.kind())
+		}
+		if right.kind() != ruleBool {
+			return nil, p.errorf(token, "%q needs a condition on its right, not a %s", op, right.kind())
+		}
+		left = ruleLogical{and: op == "and", left: left, right: right}
+	}
+}
+
+func (p *ruleParser) not() (ruleNode, error) {
+	token, _ := p.peek()
+	if !p.accept("not") {
+		return p.compare()
+	}
+	operand, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	if operand.kind() != ruleBool {
+		return nil, p.errorf(token, `"not" needs a condition, not a %s`, operand.kind())
+	}
+	return ruleNot{operand: operand}, nil
+}
+
+func (p *ruleParser) compare() (ruleNode, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	token, _ := p.peek()
+	if p.accept("in") {
+		list, err := p.list(left.kind())
+		if err != nil {
+			return nil, err
+		}
+		return ruleIn{operand: left, list: list}, nil
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != right.kind() {
+			return nil, p.errorf(token, "cannot compare %s with %s", left.kind(), right.kind())
+		}
+		if left.kind() == ruleBool && op != "==" && op != "!=" {
+			return nil, p.errorf(token, "cannot order conditions with %q", op)
+		}
+		return ruleCompare{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *ruleParser) primary() (ruleNode, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, p.unexpected("a value")
+	}
+	if p.accept("(") {
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected(`")"`)
+		}
+		return node, nil
+	}
+	if literal, ok := p.literal(); ok {
+		return literal, nil
+	}
+	if !token.quoted && !ruleKeywords[token.text] && (unicode.IsLetter(rune(token.text[0])) || token.text[0] == '_') {
+		field, ok := ruleFields[token.text]
+		if !ok {
+			return nil, p.errorf(token, "unknown field %q, expected one of %s", token.text, strings.Join(ruleFieldNames(), ", "))
+		}
+		p.next++
+		return ruleFieldNode{field: field}, nil
+	}
+	return nil, p.unexpected("a value")
+}
+
+//literal consumes the next token if it is a number, string, true or false
+func (p *ruleParser) literal() (ruleLiteral, bool) {
+	token, ok := p.peek()
+	if !ok {
+		return ruleLiteral{}, false
+	}
+	var literal ruleLiteral
+	switch {
+	case token.quoted:
+		literal = ruleLiteral{k: ruleString, v: ruleValue{str: token.text}}
+	case token.text == "true" || token.text == "false":
+		literal = ruleLiteral{k: ruleBool, v: ruleValue{b: token.text == "true"}}
+	//ParseFloat also reads words like inf and nan, which are not numbers here
+	case token.text != "" && strings.ContainsRune("-.0123456789", rune(token.text[0])):
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return ruleLiteral{}, false
+		}
+		literal = ruleLiteral{k: ruleNumber, v: ruleValue{num: number}}
+	default:
+		return ruleLiteral{}, false
+	}
+	p.next++
+	return literal, true
+}
+
+//list parses a bracketed list of literals of the given kind
+func (p *ruleParser) list(kind ruleKind) ([]ruleValue, error) {
+	if !p.accept("[") {
+		return nil, p.unexpected(`"["`)
+	}
+	var values []ruleValue
+	for {
+		token, _ := p.peek()
+		literal, ok := p.literal()
+		if !ok {
+			return nil, p.unexpected("a number or string")
+		}
+		if literal.k != kind {
+			return nil, p.errorf(token, "cannot look for a %s in a list of %s", kind, literal.k)
+		}
+		values = append(values, literal.v)
+		if p.accept("]") {
+			return values, nil
+		}
+		if !p.accept(",") {
+			return nil, p.unexpected(`"," or "]"`)
+		}
+	}
+}
+
+func ruleFieldNames() []string {
+	var names []string
+	for name := range ruleFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//rules caches each rule by its source, as the same few rules are evaluated for every load
+var rules sync.Map
+
+//compileRule parses a rule, or returns it from the cache
+func compileRule(src string) (ruleNode, error) {
+	if node, ok := rules.Load(src); ok {
+		return node.(ruleNode), nil
+	}
+	node, err := parseRule(src)
+	if err != nil {
+		return nil, err
+	}
+	rules.Store(src, node)
+	return node, nil
+}
@@ -0,0 +1,212 @@
+package account
+
+import (
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/stretchr/testify/suite"
+)
+
+type RuleTestSuite struct {
+	suite.Suite
+	//saturday is a saturday night load of a customer whose first load was the monday before
+	saturday ruleEnv
+}
+
+func TestRule(t *testing.T) {
+	suite.Run(t, new(RuleTestSuite))
+}
+
+func (s *RuleTestSuite) SetupTest() {
+	s.saturday = ruleEnv{
+		load:      Fund{ID: "1", CustomerID: "18", LoadAmount: 1500.00, Time: time.Date(2020, 11, 21, 23, 5, 0, 0, time.UTC)},
+		tier:      "gold",
+		firstLoad: date(2020, 11, 16),
+	}
+}
+
+func (s *RuleTestSuite) eval(src string) bool {
+	rule, err := parseRule(src)
+	s.Require().NoError(err, src)
+	return rule.eval(s.saturday).b
+}
+
+func (s *RuleTestSuite) TestEval() {
+	cases := []struct {
+		rule     string
+		expected bool
+	}{
+		{"load.amount > 1000", true},
+		{"load.amount >= 1500 and load.amount <= 1500", true},
+		{"load.amount < 1000.50", false},
+		{"load.hour == 23", true},
+		{`load.time_of_day >= "22:00" or load.time_of_day < "06:00"`, true},
+		{`load.day_of_week in ["saturday", "sunday"]`, true},
+		{`load.day_of_week in ['monday']`, false},
+		{"load.weekend", true},
+		{"not load.weekend", false},
+		{"load.weekend == false", false},
+		{"customer.age_days < 7", true},
+		{"customer.age_days == 5", true},
+		{`customer.id == "18" and customer.tier != "gold"`, false},
+		{`not (customer.tier == "gold" and load.amount > 2000)`, true},
+		{"true or false and false", true},
+		{"(true or false) and false", false},
+		{"load.amount in [-1, 1500]", true},
+	}
+	for _, c := range cases {
+		s.Equal(c.expected, s.eval(c.rule), c.rule)
+	}
+}
+
+func (s *RuleTestSuite) TestAgeOfNewCustomer() {
+	s.saturday.firstLoad = time.Time{}
+	s.True(s.eval("customer.age_days == 0"))
+}
+
+func (s *RuleTestSuite) TestErrors() {
+	cases := []struct {
+		rule     string
+		expected string
+	}{
+		{"", "at column 1: expected a value, found end of rule"},
+		{"load.amount >", "at column 14: expected a value, found end of rule"},
+		{"load.amt > 5", `at column 1: unknown field "load.amt", expected one of customer.age_days, customer.id, customer.tier, load.amount, load.day_of_week, load.hour, load.time_of_day, load.weekend`},
+		{`load.amount > "5"`, "at column 13: cannot compare number with string"},
+		{"load.weekend < true", `at column 14: cannot order conditions with "<"`},
+		{"load.amount", "at column 1: rule is a number, not a condition"},
+		{"load.amount and load.weekend", `at column 1: "and" needs a condition on its left, not a number`},
+		{"load.weekend or 5", `at column 14: "or" needs a condition on its right, not a number`},
+		{"not load.hour", `at column 1: "not" needs a condition, not a number`},
+		{"load.amount = 5", `at column 13: unexpected "=", did you mean "=="`},
+		{`customer.id == "18`, "at column 16: unterminated string"},
+		{"(load.weekend", `at column 14: expected ")", found end of rule`},
+		{"load.weekend load.weekend", `at column 14: expected "and", "or" or end of rule, found "load.weekend"`},
+		{`load.day_of_week in "sunday"`, `at column 21: expected "[", found "sunday"`},
+		{`load.day_of_week in ["sunday" "monday"]`, `at column 31: expected "," or "]", found "monday"`},
+		{"load.hour in [1, \"2\"]", "at column 18: cannot look for a number in a list of string"},
+		{"load.amount > inf", `at column 15: unknown field "inf", expected one of customer.age_days, customer.id, customer.tier, load.amount, load.day_of_week, load.hour, load.time_of_day, load.weekend`},
+		{"load.amount > 5 & true", `at column 17: unexpected "&"`},
+	}
+	for _, c := range cases {
+		_, err := parseRule(c.rule)
+		s.EqualError(err, c.expected, c.rule)
+	}
+}
+
+func (s *RuleTestSuite) TestValidate() {
+	double := 2.0
+	negative := -1
+	policy := Policy{Limits: []Limit{{Name: "weekly", Period: Period{Unit: Week}, Weights: []Weight{{When: "load.weekend", Amount: &double}}}}}
+	s.NoError(policy.Validate())
+	policy.Limits[0].When = "load.weekend =="
+	s.EqualError(policy.Validate(), "limit weekly: when: at column 16: expected a value, found end of rule")
+	policy.Limits[0].When = ""
+	policy.Limits[0].Weights = append(policy.Limits[0].Weights, Weight{When: "weekend"})
+	s.Contains(policy.Validate().Error(), `limit weekly: weight 2: when: at column 1: unknown field "weekend"`)
+	policy.Limits[0].Weights[1] = Weight{When: "true", Count: &negative}
+	s.EqualError(policy.Validate(), "limit weekly: weight 2 must not be negative")
+}
+
+//TestFirstWeekLimit holds customers to $1,000 a day for their first week
+func (s *RuleTestSuite) TestFirstWeekLimit() {
+	policy := DefaultPolicy()
+	policy.Limits = append(policy.Limits, Limit{Name: "first_week", Period: Period{Unit: Day}, MaxAmount: 1000.00, When: "customer.age_days < 7"})
+	s.Require().NoError(policy.Validate())
+	service := CustomerAccount{Policy: policy}
+	c := cache.New(cache.NoExpiration, 0)
+	monday := time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+	s.True(service.Decide(Fund{ID: "1", CustomerID: "18", LoadAmount: 1000.00, Time: monday}, c).Accepted())
+	decision := service.Decide(Fund{ID: "2", CustomerID: "18", LoadAmount: 0.01, Time: monday}, c)
+	s.EqualError(decision.Err, "accountID: 18 exceed first_week fund limit when process loadID: 2")
+	s.True(service.Decide(Fund{ID: "3", CustomerID: "18", LoadAmount: 1000.00, Time: monday.AddDate(0, 0, 6)}, c).Accepted())
+	s.False(service.Decide(Fund{ID: "4", CustomerID: "18", LoadAmount: 0.01, Time: monday.AddDate(0, 0, 6)}, c).Accepted())
+	s.True(service.Decide(Fund{ID: "5", CustomerID: "18", LoadAmount: 4000.00, Time: monday.AddDate(0, 0, 7)}, c).Accepted())
+
+	evaluation := service.Evaluate(Fund{ID: "6", CustomerID: "19", LoadAmount: 2000.00, Time: monday}, c)
+	s.False(evaluation.Accepted)
+	s.Len(evaluation.Limits, 3)
+	evaluation = service.Evaluate(Fund{ID: "6", CustomerID: "18", LoadAmount: 100.00, Time: monday.AddDate(0, 0, 8)}, c)
+	s.True(evaluation.Accepted)
+	s.Len(evaluation.Limits, 2, "the first week limit no longer applies")
+}
+
+//TestWeekendCountsDouble counts weekend loads twice towards the weekly limit
+func (s *RuleTestSuite) TestWeekendCountsDouble() {
+	double := 2.0
+	policy := DefaultPolicy()
+	policy.Limits[1].Weights = []Weight{{When: "load.weekend", Amount: &double}}
+	s.Require().NoError(policy.Validate())
+	service := CustomerAccount{Policy: policy}
+	c := cache.New(cache.NoExpiration, 0)
+	monday := time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+	for day := 0; day < 3; day++ {
+		s.True(service.Decide(Fund{ID: string(rune('a' + day)), CustomerID: "18", LoadAmount: 5000.00, Time: monday.AddDate(0, 0, day)}, c).Accepted())
+	}
+	//15,000 on weekdays leaves 5,000, which a weekend load uses up at half the amount
+	saturday := monday.AddDate(0, 0, 5)
+	s.False(service.Decide(Fund{ID: "d", CustomerID: "18", LoadAmount: 2500.01, Time: saturday}, c).Accepted())
+	s.True(service.Decide(Fund{ID: "e", CustomerID: "18", LoadAmount: 2500.00, Time: saturday}, c).Accepted())
+
+	usage := service.Usage("18", saturday, c)
+	s.Equal(20000.00, usage.Limits[1].UsedAmount)
+	s.Equal(2500.00, usage.Limits[0].UsedAmount, "the daily limit is not weighted")
+	s.Equal(Totals{Day: 2500.00, Week: 17500.00}, service.Decide(Fund{ID: "f", CustomerID: "18", LoadAmount: 0.01, Time: saturday}, c).Totals)
+}
+
+func (s *RuleTestSuite) TestWeightLeavesLoadsOut() {
+	zero := 0
+	policy := &Policy{Limits: []Limit{{Name: "daily_loads", Period: Period{Unit: Day}, MaxLoads: 1, Weights: []Weight{{When: "load.amount < 10", Count: &zero}}}}}
+	s.Require().NoError(policy.Validate())
+	service := CustomerAccount{Policy: policy}
+	c := cache.New(cache.NoExpiration, 0)
+	monday := time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+	s.True(service.Decide(Fund{ID: "1", CustomerID: "18", LoadAmount: 5.00, Time: monday}, c).Accepted())
+	s.True(service.Decide(Fund{ID: "2", CustomerID: "18", LoadAmount: 500.00, Time: monday}, c).Accepted())
+	s.True(service.Decide(Fund{ID: "3", CustomerID: "18", LoadAmount: 5.00, Time: monday}, c).Accepted())
+	s.False(service.Decide(Fund{ID: "4", CustomerID: "18", LoadAmount: 500.00, Time: monday}, c).Accepted())
+}
+
+func (s *RuleTestSuite) TestTierRule() {
+	gold := DefaultPolicy()
+	gold.Limits = append(gold.Limits, Limit{Name: "gold_night", Period: Period{Unit: Day}, MaxAmount: 100.00, When: `customer.tier == "gold" and load.hour < 6`})
+	store := NewConfigStore(gold)
+	service := CustomerAccount{Policies: store}
+	c := cache.New(cache.NoExpiration, 0)
+	night := time.Date(2020, 11, 16, 2, 0, 0, 0, time.UTC)
+	s.True(service.Decide(Fund{ID: "1", CustomerID: "18", LoadAmount: 200.00, Time: night}, c).Accepted(), "customer 18 is not gold yet")
+	s.Require().NoError(store.CreateTier("alice", "gold launch", Tier{Name: "gold", Policy: DefaultTier}))
+	s.Require().NoError(store.AssignTier("alice", "gold launch", "18", "gold"))
+	s.False(service.Decide(Fund{ID: "2", CustomerID: "18", LoadAmount: 200.00, Time: night}, c).Accepted())
+}
+
+func (s *RuleTestSuite) TestUnvalidatedPolicyDeclines() {
+	policy := &Policy{Limits: []Limit{{Name: "daily", Period: Period{Unit: Day}, When: "load.amount >"}}}
+	day := time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+	service := CustomerAccount{Policy: policy, Clock: clock.NewFake(day)}
+	decision := service.Decide(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: day}, cache.New(cache.NoExpiration, 0))
+	s.EqualError(decision.Err, "accountID: 18 limit daily cannot be checked when process loadID: 1: when: at column 14: expected a value, found end of rule")
+}
+
+//FuzzParseRule checks that any rule either fails to parse with a column inside it, or evaluates without panicking
+func FuzzParseRule(f *testing.F) {
+	f.Add(`customer.age_days < 7 and load.day_of_week in ["saturday", "sunday"]`)
+	f.Add(`not (load.time_of_day >= "22:00" or load.hour < 6) == true`)
+	f.Add(`load.amount in [-1, 1e3, .5]`)
+	f.Add(`((load.weekend`)
+	f.Add(`customer.id == '18`)
+	env := ruleEnv{load: Fund{ID: "1", CustomerID: "18", LoadAmount: 100.00, Time: time.Date(2020, 11, 21, 23, 5, 0, 0, time.UTC)}}
+	f.Fuzz(func(t *testing.T, src string) {
+		rule, err := parseRule(src)
+		if err != nil {
+			ruleErr, ok := err.(RuleError)
+			if !ok || ruleErr.Column < 1 || ruleErr.Column > len(src)+1 {
+				t.Errorf("rule %q failed with %v", src, err)
+			}
+			return
+		}
+		rule.eval(env)
+	})
+}
@@ -1,10 +1,15 @@
 package account
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"time"
 
 	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -13,59 +18,281 @@ const (
 	WeeklyFundLimit         = 20000.00
 )
 
+//dateLayout formats the key used to group loads by day in transaction history
+const dateLayout = "2006-01-02"
+
 type Service interface {
 	LoadFund(Fund, *cache.Cache) (bool, error)
+	Decide(Fund, *cache.Cache) Decision
+	DecideContext(context.Context, Fund, *cache.Cache) Decision
+	ReserveContext(context.Context, Fund, time.Duration, *cache.Cache) Decision
+	Capture(string, string, *cache.Cache) error
+	Release(string, string, *cache.Cache) error
+	Evaluate(Fund, *cache.Cache) Evaluation
+	Usage(string, time.Time, *cache.Cache) Usage
+	Sweep(*cache.Cache) int
 	checkIfLoadExists(string) error
-	checkDailyLimit(*Fund) error
-	checkWeeklyLimit(*Fund) error
+	checkLimit(*Fund, Limit) error
 }
 type CustomerAccount struct {
 	ID           string
 	LoadIDs      []string
 	Transactions map[string][]Fund
+	//Declined holds loads that failed a limit check, grouped by date like Transactions
+	Declined map[string][]Fund
+	//LatestLoad is the newest load time decided for the account
+	LatestLoad time.Time
+	//ExpiresAt is when the account is forgotten, by processing time rather than load time. Zero means never.
+	ExpiresAt time.Time
+	//Policy holds the limits to check loads against, DefaultPolicy is used when nil
+	Policy *Policy `json:"-"`
+	//Policies tells the policy in force for each customer when it can change at runtime, like a ConfigStore.
+	//When set it is used instead of Policy.
+	Policies PolicySource `json:"-"`
+	//Clock tells processing time for expiry, the system clock is used when nil
+	Clock clock.Clock `json:"-"`
+	//Retention is how long an account is kept after its last load, zero keeps accounts until the cache evicts them
+	Retention time.Duration `json:"-"`
+	//OutOfOrder is how to treat a load older than the newest one decided for the customer, AcceptLate when empty
+	OutOfOrder OutOfOrder `json:"-"`
+	//Lateness is how far behind the newest load a load may be before RejectLate declines it
+	Lateness time.Duration `json:"-"`
+	//tier is the customer's tier for the decision being made, for rules
+	tier string
+	//entities are the histories of what the loads being decided were made with, by cache key
+	entities map[string]*EntityHistory
 }
 type Fund struct {
 	ID         string    `json:"id"`
 	CustomerID string    `json:"customer_id"`
 	LoadAmount float64   `json:"load_amount"`
 	Time       time.Time `json:"time"`
+	//FundingSourceID, DeviceID and IP are what the load was made with, each empty when it is not known
+	FundingSourceID string `json:"funding_source_id,omitempty"`
+	DeviceID        string `json:"device_id,omitempty"`
+	IP              string `json:"ip,omitempty"`
+	//Tier is the customer's tier when the load was decided, for limits keyed on tiers. Empty is the default tier.
+	Tier string `json:"tier,omitempty"`
+	//Standing is why the customer's standing declined the load, like ReasonFrozen, so the decline is kept when the
+	//load is decided again after the standing changed. It is empty for loads the standing let through.
+	Standing Reason `json:"standing,omitempty"`
+	//HoldExpiresAt is when a reserved load that has not been captured is released, by processing time.
+	//It is nil for loads that were never reserved or have been captured.
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+}
+
+//Decision is the outcome of loading a fund
+type Decision struct {
+	//Duplicate is set when the loadID exists, and the load was ignored
+	Duplicate bool
+	//Err says why the load was declined or ignored, nil when it was accepted
+	Err error
+	//Corrections lists earlier decisions that changed because of this load
+	Corrections []Correction
+	//Totals are the customer's totals once the load is decided
+	Totals Totals
+	//PolicyVersion is the version of the policy the load was checked against
+	PolicyVersion string
+	//Flags lists the soft limits an accepted load exceeded, for review
+	Flags []Flag
+	//HoldExpiresAt is when an accepted reservation is released unless it is captured, nil for other loads
+	HoldExpiresAt *time.Time
+}
+
+//Correction replaces the decision previously made for a load
+type Correction struct {
+	Fund     Fund
+	Accepted bool
+	Totals   Totals
+}
+
+//Totals are the amounts accepted for a customer on the day and in the week of a load
+type Totals struct {
+	Day  float64 `json:"day_total"`
+	Week float64 `json:"week_total"`
+}
+
+//Accepted reports whether the load was accepted
+func (d Decision) Accepted() bool {
+	return !d.Duplicate && d.Err == nil
+}
+
+//Reason returns the code for why the load was declined, empty when it was accepted or is a duplicate
+func (d Decision) Reason() Reason {
+	return ReasonOf(d.Err)
+}
+
+//Outcome returns whether the load was accepted, accepted and flagged for review, or declined.
+//It is empty for a duplicate.
+func (d Decision) Outcome() Outcome {
+	switch {
+	case d.Duplicate:
+		return ""
+	case d.Err != nil:
+		return OutcomeDeclined
+	case len(d.Flags) > 0:
+		return OutcomeAcceptedFlagged
+	}
+	return OutcomeAccepted
 }
 
 //LoadFund will validate dupe transaction, and check account velocity limits before load fund into account
 func (a CustomerAccount) LoadFund(fund Fund, c *cache.Cache) (bool, error) {
+	ctx, span := tracer().Start(context.Background(), "CustomerAccount.LoadFund", trace.WithAttributes(fundAttributes(fund)...))
+	defer span.End()
+	decision := a.DecideContext(ctx, fund, c)
+	traceDecision(span, decision)
+	return decision.Duplicate, decision.Err
+}
+
+//Decide loads the fund like LoadFund, and also reports decisions for earlier loads that changed as a result
+func (a CustomerAccount) Decide(fund Fund, c *cache.Cache) Decision {
+	return a.DecideContext(context.Background(), fund, c)
+}
+
+//DecideContext decides the fund like Decide, tracing each step as a child of the span in ctx
+func (a CustomerAccount) DecideContext(ctx context.Context, fund Fund, c *cache.Cache) Decision {
+	return a.decide(ctx, fund, 0, c)
+}
+
+//decide decides the fund, holding an accepted load for hold when it is positive
+func (a CustomerAccount) decide(ctx context.Context, fund Fund, hold time.Duration, c *cache.Cache) Decision {
+	ctx, span := tracer().Start(ctx, "CustomerAccount.Decide", trace.WithAttributes(fundAttributes(fund)...))
+	defer span.End()
 	var err error
-	//Try to find customer account in cache, if not found create a new account
-	if x, found := c.Get(fund.CustomerID); found {
-		a = x.(CustomerAccount)
-	} else {
-		a = CustomerAccount{
-			ID: fund.CustomerID,
-		}
-	}
+	policy := a.policy(fund.CustomerID)
+	span.SetAttributes(attribute.String("policy.version", policy.Version))
+	//the account loaded from the cache has no clock, so processing time is told now
+	now, expiresAt := a.now(), a.expiresAt()
+	outOfOrder, lateness, policies := a.OutOfOrder, a.Lateness, a.policies()
+	_, read := tracer().Start(ctx, "store.read")
+	a = a.loadAccount(fund.CustomerID, c)
+	a.tier = policy.Tier
+	fund.Tier = policy.Tier
+	a.loadEntities(c, now, policy.keys(), fund)
+	read.SetAttributes(attribute.Int("account.loads", len(a.LoadIDs)), attribute.Int("account.entities", len(a.entities)))
+	read.End()
 	//Check against customer account to see if loadID alreay exits. If yes, set skip to true
-	if err = a.checkIfLoadExists(fund.ID); err != nil {
-		return true, err
+	_, check := tracer().Start(ctx, "check.duplicate")
+	err = a.checkIfLoadExists(fund.ID)
+	check.SetAttributes(attribute.Bool("duplicate", err != nil))
+	check.End()
+	if err != nil {
+		decision := Decision{Duplicate: true, Err: err}
+		traceDecision(span, decision)
+		return decision
 	}
 	//Log LoadID even if the load doesn't pass validation
 	a.LoadIDs = append(a.LoadIDs, fund.ID)
+	a.ExpiresAt = expiresAt
+	if hold > 0 {
+		holdExpiresAt := now.Add(hold)
+		fund.HoldExpiresAt = &holdExpiresAt
+		span.SetAttributes(attribute.String("hold.expires_at", holdExpiresAt.Format(time.RFC3339)))
+	}
+	decision := Decision{}
+	//a blocked customer or an account that is not active is declined before any limit is checked
+	standing := a.checkStanding(&fund, policy)
+	switch {
+	case standing != nil:
+		decision.Err = standing
+		fund.Standing = ReasonOf(standing)
+		a.record(fund, false)
+	case !fund.Time.Before(a.LatestLoad) || outOfOrder == AcceptLate || outOfOrder == "":
+		decision.Flags, decision.Err = a.checkLimits(ctx, &fund, policy)
+		a.record(fund, decision.Err == nil)
+	case outOfOrder == RejectLate && fund.Time.Before(a.LatestLoad.Add(-lateness)):
+		decision.Err = decline(ReasonLate, "accountID: %s load is older than watermark %s when process loadID: %s",
+			a.ID, a.LatestLoad.Add(-lateness).Format(time.RFC3339), fund.ID)
+		a.record(fund, false)
+	case outOfOrder == RejectLate:
+		decision.Flags, decision.Err = a.checkLimits(ctx, &fund, policy)
+		a.record(fund, decision.Err == nil)
+	default:
+		//loads decided again may have been made with other funding sources, devices or IP addresses
+		a.loadEntities(c, now, policy.keys(), a.loadsAfter(fund.Time)...)
+		decision = a.reevaluate(ctx, fund, policy)
+	}
+	decision.Totals = a.totals(fund.Time)
+	decision.PolicyVersion = policy.Version
+	if decision.Accepted() {
+		decision.HoldExpiresAt = fund.HoldExpiresAt
+	}
+	for i, correction := range decision.Corrections {
+		decision.Corrections[i].Totals = a.totals(correction.Fund.Time)
+	}
+	_, write := tracer().Start(ctx, "store.write")
+	//the load is committed to the customer and everything it was made with together, under the handler's lock.
+	//The histories are only for this decision, the next one reads them afresh.
+	entities := a.entities
+	a.entities = nil
+	//loads up to the lateness behind this one may still be decided against the windows they fall in
+	pruneEntities(entities, policies, fund.Time.Add(-lateness))
 	c.Set(a.ID, a, cache.DefaultExpiration)
-	if err = a.checkDailyLimit(&fund); err != nil {
-		return false, err
+	commitEntities(c, entities)
+	write.End()
+	traceDecision(span, decision)
+	return decision
+}
+//checkLimits checks the fund against the policy's load amount bounds and cooldown, then each limit in turn,
+//stopping at the first hard limit it exceeds. It returns the soft limits an accepted fund exceeds as flags.
+func (a CustomerAccount) checkLimits(ctx context.Context, fund *Fund, policy *Policy) ([]Flag, error) {
+	if err := a.checkLoadAmount(fund, policy); err != nil {
+		return nil, err
 	}
-	if err = a.checkWeeklyLimit(&fund); err != nil {
-		return false, err
+	if err := a.checkCooldown(fund, policy); err != nil {
+		return nil, err
+	}
+	var flags []Flag
+	for _, limit := range policy.Limits {
+		if err := a.checkRules(fund, limit); err != nil {
+			return nil, err
+		}
+		if !limit.applies(a.ruleEnv(*fund)) || !limit.keyedOn(*fund) {
+			continue
+		}
+		_, span := tracer().Start(ctx, "check.limit")
+		usage := a.usageIn(a.transactions(limit, *fund), limit, fund.Time)
+		err := a.checkUsage(fund, limit, usage)
+		a.traceLimit(span, fund, limit, usage, err)
+		span.End()
+		if err != nil && limit.Soft {
+			flags = append(flags, flag(limit, err))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
+	return flags, nil
+}
+//record adds a decided load to the account history
+func (a *CustomerAccount) record(fund Fund, accepted bool) {
 	//use date as key to group loads together as transaction history in account
-	date := fund.Time.Format("01/02/2019")
-	if len(a.Transactions) == 0 {
-		transactions := make(map[string][]Fund)
-		transactions[date] = []Fund{fund}
-		a.Transactions = transactions
-	} else {
+	date := fund.Time.Format(dateLayout)
+	if accepted {
+		if a.Transactions == nil {
+			a.Transactions = make(map[string][]Fund)
+		}
 		a.Transactions[date] = append(a.Transactions[date], fund)
+		a.recordEntities(fund)
+	} else {
+		if a.Declined == nil {
+			a.Declined = make(map[string][]Fund)
+		}
+		a.Declined[date] = append(a.Declined[date], fund)
+	}
+	if fund.Time.After(a.LatestLoad) {
+		a.LatestLoad = fund.Time
+	}
+}
+//totals adds up the loads accepted on the day and in the week of t, up to t
+func (a CustomerAccount) totals(t time.Time) Totals {
+	a = a.asOf(t)
+	return Totals{
+		Day:  a.usage(Limit{Period: Period{Unit: Day}}, t).UsedAmount,
+		Week: a.usage(Limit{Period: Period{Unit: Week}}, t).UsedAmount,
 	}
-	c.Set(a.ID, a, cache.DefaultExpiration)
-	return false, nil
 }
 func (a CustomerAccount) checkIfLoadExists(loadID string) error {
 	if find(a.LoadIDs, loadID) {
@@ -73,39 +300,106 @@ func (a CustomerAccount) checkIfLoadExists(loadID string) error {
 	}
 	return nil
 }
-func (a CustomerAccount) checkDailyLimit(fund *Fund) error {
-	//There are two limits for daily fund: number of loads per day, and maximum amount per day.
-	date := fund.Time.Format("01/02/2019")
-	if len(a.Transactions[date]) >= DailyNumberOfLoadsLimit {
-		return fmt.Errorf("accountID: %s exceed daily number of loads limit when process loadID: %s", a.ID, fund.ID)
+func (a CustomerAccount) checkLimit(fund *Fund, limit Limit) error {
+	return a.checkUsage(fund, limit, a.usageIn(a.transactions(limit, *fund), limit, fund.Time))
+}
+//checkLoadAmount declines a load outside the policy's bounds on the amount of a single load
+func (a CustomerAccount) checkLoadAmount(fund *Fund, policy *Policy) error {
+	//NaN fails every comparison, so it would pass the bounds below
+	if math.IsNaN(fund.LoadAmount) || math.IsInf(fund.LoadAmount, 0) || fund.LoadAmount <= 0 {
+		return decline(ReasonInvalidAmount, "accountID: %s load amount %.2f is not a positive amount when process loadID: %s",
+			a.ID, fund.LoadAmount, fund.ID)
 	}
-	var totalAmount float64
-	if len(a.Transactions[date]) > 0 {
-		for _, load := range a.Transactions[date] {
-			totalAmount += load.LoadAmount
-		}
+	if policy.MinLoadAmount > 0 && fund.LoadAmount < policy.MinLoadAmount {
+		return decline(ReasonBelowMinAmount, "accountID: %s load amount %.2f is below the minimum of %.2f when process loadID: %s",
+			a.ID, fund.LoadAmount, policy.MinLoadAmount, fund.ID)
+	}
+	if policy.MaxLoadAmount > 0 && fund.LoadAmount > policy.MaxLoadAmount {
+		return decline(ReasonAboveMaxAmount, "accountID: %s load amount %.2f is above the maximum of %.2f when process loadID: %s",
+			a.ID, fund.LoadAmount, policy.MaxLoadAmount, fund.ID)
+	}
+	return nil
+}
+//checkRules declines loads checked against a limit with rules that do not parse, which only happens for
+//policies that were never validated
+func (a CustomerAccount) checkRules(fund *Fund, limit Limit) error {
+	if err := limit.validateRules(); err != nil {
+		return decline(ReasonInvalidRule, "accountID: %s limit %s cannot be checked when process loadID: %s: %s", a.ID, limit.Name, fund.ID, err)
+	}
+	return nil
+}
+func (a CustomerAccount) checkUsage(fund *Fund, limit Limit, usage LimitUsage) error {
+	amount, count := limit.weigh(a.ruleEnv(*fund))
+	//A limit may cap both the number of loads and the amount loaded within its window
+	if limit.MaxLoads > 0 && usage.UsedLoads+count > limit.MaxLoads {
+		return decline(ReasonWindowLimit, "accountID: %s exceed %s number of loads limit when process loadID: %s", a.ID, limit.Name, fund.ID)
 	}
-	if (totalAmount + fund.LoadAmount) > DailyFundLimit {
-		return fmt.Errorf("accountID: %s exceed daily fund limit when process loadID: %s", a.ID, fund.ID)
+	if limit.MaxAmount > 0 && (usage.UsedAmount+amount) > limit.MaxAmount {
+		return decline(ReasonWindowLimit, "accountID: %s exceed %s fund limit when process loadID: %s", a.ID, limit.Name, fund.ID)
 	}
 	return nil
 }
-func (a CustomerAccount) checkWeeklyLimit(fund *Fund) error {
-	//Weeks start on Monday, and time.Monday is 1
-	//Find all loads between Monday and current fund request date, then check if excced amount limit per week
-	var totalAmount float64
-	for i := 0; i < int(fund.Time.Weekday()); i++ {
-		date := fund.Time.AddDate(0, 0, -i).Format("01/02/2019")
-		if len(a.Transactions[date]) > 0 {
-			for _, load := range a.Transactions[date] {
-				totalAmount += load.LoadAmount
+//ruleEnv is what the account's rules are evaluated against for a load
+func (a CustomerAccount) ruleEnv(load Fund) ruleEnv {
+	return ruleEnv{load: load, tier: a.tier, firstLoad: a.firstLoad()}
+}
+
+//firstLoad returns the day of the customer's first decided load, or zero if there is none
+func (a CustomerAccount) firstLoad() time.Time {
+	var first time.Time
+	for _, history := range []map[string][]Fund{a.Transactions, a.Declined} {
+		for date := range history {
+			day, err := time.Parse(dateLayout, date)
+			if err == nil && (first.IsZero() || day.Before(first)) {
+				first = day
 			}
 		}
 	}
-	if (totalAmount + fund.LoadAmount) > WeeklyFundLimit {
-		return fmt.Errorf("accountID: %s exceed weekly fund limit when process loadID: %s", a.ID, fund.ID)
+	return first
+}
+
+//loadAccount will find customer account in cache, if not found or expired create a new account.
+//Holds that have expired are released from it.
+func (a CustomerAccount) loadAccount(customerID string, c *cache.Cache) CustomerAccount {
+	now := a.now()
+	if x, found := c.Get(customerID); found {
+		if account, ok := x.(CustomerAccount); ok && !account.expired(now) {
+			account.Transactions, _, _ = releaseExpired(account.Transactions, now)
+			return account
+		}
+	}
+	return CustomerAccount{
+		ID: customerID,
 	}
-	return nil
+}
+func (a CustomerAccount) expired(now time.Time) bool {
+	return !a.ExpiresAt.IsZero() && !now.Before(a.ExpiresAt)
+}
+func (a CustomerAccount) expiresAt() time.Time {
+	if a.Retention <= 0 {
+		return time.Time{}
+	}
+	return a.now().Add(a.Retention)
+}
+func (a CustomerAccount) now() time.Time {
+	return a.clock().Now()
+}
+func (a CustomerAccount) clock() clock.Clock {
+	if a.Clock == nil {
+		return clock.New()
+	}
+	return a.Clock
+}
+//policy returns the policy in force for the customer, read once per decision so a change to the config
+//never applies to only part of one
+func (a CustomerAccount) policy(customerID string) *Policy {
+	if a.Policies != nil {
+		return a.Policies.Policy(customerID, a.now())
+	}
+	if a.Policy == nil {
+		return DefaultPolicy()
+	}
+	return a.Policy
 }
 func find(haystack []string, needle string) bool {
 	for _, value := range haystack {
@@ -36,11 +36,12 @@ func (s *CustomerAccountTestSuite) Reset() {
 
 func (s *CustomerAccountTestSuite) TestLoadIDExists() {
 	s.Reset()
+	date := time.Date(2020, 11, 18, 23, 59, 59, 0, time.UTC)
 	s.request = Fund{
 		ID:         "29360",
 		CustomerID: "18",
 		LoadAmount: 4000.00,
-		Time:       time.Now(),
+		Time:       date,
 	}
 	c := cache.New(5*time.Minute, 10*time.Minute)
 	var service Service
@@ -48,12 +49,12 @@ func (s *CustomerAccountTestSuite) TestLoadIDExists() {
 	v := validator.New()
 	handler := NewHandler(service, v, c)
 	transactions := make(map[string][]Fund)
-	transactions[time.Now().Format("01/02/2019")] = []Fund{
+	transactions[date.Format(dateLayout)] = []Fund{
 		Fund{
 			ID:         "29360",
 			CustomerID: "18",
 			LoadAmount: 4000.00,
-			Time:       time.Now(),
+			Time:       date,
 		},
 	}
 	data := CustomerAccount{
@@ -77,11 +78,12 @@ func (s *CustomerAccountTestSuite) TestLoadIDExists() {
 }
 func (s *CustomerAccountTestSuite) TestExceedDailyAmountLimit() {
 	s.Reset()
+	date := time.Date(2020, 11, 18, 23, 59, 59, 0, time.UTC)
 	s.request = Fund{
 		ID:         "29360",
 		CustomerID: "18",
 		LoadAmount: 5000.01,
-		Time:       time.Now(),
+		Time:       date,
 	}
 	c := cache.New(5*time.Minute, 10*time.Minute)
 	var service Service
@@ -90,7 +92,7 @@ func (s *CustomerAccountTestSuite) TestExceedDailyAmountLimit() {
 	handler := NewHandler(service, v, c)
 	s.resp, s.err = handler.service.LoadFund(s.request, c)
 	s.expectedResp = false
-	s.expectedErr = fmt.Errorf(
+	s.expectedErr = decline(ReasonWindowLimit,
 		"accountID: %s exceed daily fund limit when process loadID: %s",
 		s.request.CustomerID, s.request.ID,
 	)
@@ -106,11 +108,12 @@ func (s *CustomerAccountTestSuite) TestExceedDailyAmountLimit() {
 }
 func (s *CustomerAccountTestSuite) TestExceedDailyLoadsLimit() {
 	s.Reset()
+	date := time.Date(2020, 11, 18, 23, 59, 59, 0, time.UTC)
 	s.request = Fund{
 		ID:         "29370",
 		CustomerID: "18",
 		LoadAmount: 1.00,
-		Time:       time.Now(),
+		Time:       date,
 	}
 	c := cache.New(5*time.Minute, 10*time.Minute)
 	var service Service
@@ -118,24 +121,24 @@ func (s *CustomerAccountTestSuite) TestExceedDailyLoadsLimit() {
 	v := validator.New()
 	handler := NewHandler(service, v, c)
 	transactions := make(map[string][]Fund)
-	transactions[time.Now().Format("01/02/2019")] = []Fund{
+	transactions[date.Format(dateLayout)] = []Fund{
 		Fund{
 			ID:         "29360",
 			CustomerID: "18",
 			LoadAmount: 1.00,
-			Time:       time.Now(),
+			Time:       date,
 		},
 		Fund{
 			ID:         "29361",
 			CustomerID: "18",
 			LoadAmount: 1.00,
-			Time:       time.Now(),
+			Time:       date,
 		},
 		Fund{
 			ID:         "29362",
 			CustomerID: "18",
 			LoadAmount: 1.00,
-			Time:       time.Now(),
+			Time:       date,
 		},
 	}
 	data := CustomerAccount{
@@ -146,7 +149,7 @@ func (s *CustomerAccountTestSuite) TestExceedDailyLoadsLimit() {
 	c.Set("18", data, cache.DefaultExpiration)
 	s.resp, s.err = handler.service.LoadFund(s.request, c)
 	s.expectedResp = false
-	s.expectedErr = fmt.Errorf(
+	s.expectedErr = decline(ReasonWindowLimit,
 		"accountID: %s exceed daily number of loads limit when process loadID: %s",
 		s.request.CustomerID, s.request.ID,
 	)
@@ -163,11 +166,12 @@ func (s *CustomerAccountTestSuite) TestExceedDailyLoadsLimit() {
 
 func (s *CustomerAccountTestSuite) TestExceedDailyAmountLimitWithMultiple() {
 	s.Reset()
+	date := time.Date(2020, 11, 18, 23, 59, 59, 0, time.UTC)
 	s.request = Fund{
 		ID:         "29370",
 		CustomerID: "18",
 		LoadAmount: 2000.01,
-		Time:       time.Now(),
+		Time:       date,
 	}
 	c := cache.New(5*time.Minute, 10*time.Minute)
 	var service Service
@@ -175,18 +179,18 @@ func (s *CustomerAccountTestSuite) TestExceedDailyAmountLimitWithMultiple() {
 	v := validator.New()
 	handler := NewHandler(service, v, c)
 	transactions := make(map[string][]Fund)
-	transactions[time.Now().Format("01/02/2019")] = []Fund{
+	transactions[date.Format(dateLayout)] = []Fund{
 		Fund{
 			ID:         "29360",
 			CustomerID: "18",
 			LoadAmount: 1000.00,
-			Time:       time.Now(),
+			Time:       date,
 		},
 		Fund{
 			ID:         "29361",
 			CustomerID: "18",
 			LoadAmount: 2000.00,
-			Time:       time.Now(),
+			Time:       date,
 		},
 	}
 	data := CustomerAccount{
@@ -197,7 +201,7 @@ func (s *CustomerAccountTestSuite) TestExceedDailyAmountLimitWithMultiple() {
 	c.Set("18", data, cache.DefaultExpiration)
 	s.resp, s.err = handler.service.LoadFund(s.request, c)
 	s.expectedResp = false
-	s.expectedErr = fmt.Errorf(
+	s.expectedErr = decline(ReasonWindowLimit,
 		"accountID: %s exceed daily fund limit when process loadID: %s",
 		s.request.CustomerID, s.request.ID,
 	)
@@ -227,20 +231,20 @@ func (s *CustomerAccountTestSuite) TestExceedWeeklyAmountLimit() {
 	v := validator.New()
 	handler := NewHandler(service, v, c)
 	transactions := make(map[string][]Fund)
-	transactions[date.AddDate(0, 0, -1).Format("01/02/2019")] = []Fund{
+	transactions[date.AddDate(0, 0, -1).Format(dateLayout)] = []Fund{
 		Fund{
 			ID:         "29360",
 			CustomerID: "18",
 			LoadAmount: 10000.00,
-			Time:       time.Now(),
+			Time:       date.AddDate(0, 0, -1),
 		},
 	}
-	transactions[date.AddDate(0, 0, -2).Format("01/02/2019")] = []Fund{
+	transactions[date.AddDate(0, 0, -2).Format(dateLayout)] = []Fund{
 		Fund{
 			ID:         "29361",
 			CustomerID: "18",
 			LoadAmount: 10000.00,
-			Time:       time.Now(),
+			Time:       date.AddDate(0, 0, -2),
 		},
 	}
 	data := CustomerAccount{
@@ -251,7 +255,7 @@ func (s *CustomerAccountTestSuite) TestExceedWeeklyAmountLimit() {
 	c.Set("18", data, cache.DefaultExpiration)
 	s.resp, s.err = handler.service.LoadFund(s.request, c)
 	s.expectedResp = false
-	s.expectedErr = fmt.Errorf(
+	s.expectedErr = decline(ReasonWindowLimit,
 		"accountID: %s exceed weekly fund limit when process loadID: %s",
 		s.request.CustomerID, s.request.ID,
 	)
@@ -265,3 +269,94 @@ func (s *CustomerAccountTestSuite) TestExceedWeeklyAmountLimit() {
 		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
 	}
 }
+
+func (s *CustomerAccountTestSuite) TestExceedMonthlyAmountLimit() {
+	s.Reset()
+	date := time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)
+	s.request = Fund{
+		ID:         "29370",
+		CustomerID: "18",
+		LoadAmount: 0.01,
+		Time:       date,
+	}
+	c := cache.New(5*time.Minute, 10*time.Minute)
+	var service Service
+	service = CustomerAccount{
+		Policy: &Policy{
+			Limits: []Limit{{Name: "monthly", Period: Period{Unit: Month}, MaxAmount: 30000.00}},
+		},
+	}
+	transactions := make(map[string][]Fund)
+	//the load at the end of January belongs to the previous window
+	transactions[date.AddDate(0, 0, -29).Format(dateLayout)] = []Fund{
+		Fund{ID: "29359", CustomerID: "18", LoadAmount: 10000.00, Time: date.AddDate(0, 0, -29)},
+	}
+	transactions[date.AddDate(0, 0, -28).Format(dateLayout)] = []Fund{
+		Fund{ID: "29360", CustomerID: "18", LoadAmount: 15000.00, Time: date.AddDate(0, 0, -28)},
+	}
+	transactions[date.AddDate(0, 0, -1).Format(dateLayout)] = []Fund{
+		Fund{ID: "29361", CustomerID: "18", LoadAmount: 15000.00, Time: date.AddDate(0, 0, -1)},
+	}
+	data := CustomerAccount{
+		ID:           "18",
+		LoadIDs:      []string{"29359", "29360", "29361"},
+		Transactions: transactions,
+	}
+	c.Set("18", data, cache.DefaultExpiration)
+	s.resp, s.err = service.LoadFund(s.request, c)
+	s.expectedResp = false
+	s.expectedErr = decline(ReasonWindowLimit,
+		"accountID: %s exceed monthly fund limit when process loadID: %s",
+		s.request.CustomerID, s.request.ID,
+	)
+	if s.expectedErr != nil && s.err == nil {
+		s.T().Error("error was expected, but no error return")
+	}
+	if s.err != nil && !reflect.DeepEqual(s.err, s.expectedErr) {
+		s.T().Errorf("error expected was %s, but error returned was %s.", s.expectedErr, s.err)
+	}
+	if !reflect.DeepEqual(s.resp, s.expectedResp) {
+		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
+	}
+}
+
+func (s *CustomerAccountTestSuite) TestYearlyLimitResetsOnNewYear() {
+	s.Reset()
+	date := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.request = Fund{
+		ID:         "29370",
+		CustomerID: "18",
+		LoadAmount: 100.00,
+		Time:       date,
+	}
+	c := cache.New(5*time.Minute, 10*time.Minute)
+	var service Service
+	service = CustomerAccount{
+		Policy: &Policy{
+			Limits: []Limit{{Name: "yearly", Period: Period{Unit: Year}, MaxAmount: 1000.00}},
+		},
+	}
+	transactions := make(map[string][]Fund)
+	transactions[date.AddDate(0, 0, -1).Format(dateLayout)] = []Fund{
+		Fund{ID: "29360", CustomerID: "18", LoadAmount: 1000.00, Time: date.AddDate(0, 0, -1)},
+	}
+	data := CustomerAccount{
+		ID:           "18",
+		LoadIDs:      []string{"29360"},
+		Transactions: transactions,
+	}
+	c.Set("18", data, cache.DefaultExpiration)
+	s.resp, s.err = service.LoadFund(s.request, c)
+	s.expectedResp = false
+	if s.err != nil {
+		s.T().Errorf("no error was expected, but error returned was %s.", s.err)
+	}
+	if !reflect.DeepEqual(s.resp, s.expectedResp) {
+		s.T().Errorf("response: %v, expected response: %v", s.resp, s.expectedResp)
+	}
+	s.resp, s.err = service.LoadFund(Fund{ID: "29371", CustomerID: "18", LoadAmount: 900.01, Time: date.AddDate(0, 11, 30)}, c)
+	s.expectedErr = decline(ReasonWindowLimit, "accountID: %s exceed yearly fund limit when process loadID: %s", "18", "29371")
+	if s.err == nil || !reflect.DeepEqual(s.err, s.expectedErr) {
+		s.T().Errorf("error expected was %s, but error returned was %v.", s.expectedErr, s.err)
+	}
+}
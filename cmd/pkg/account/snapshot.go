@@ -0,0 +1,245 @@
+package account
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/atomicfile"
+)
+
+//SnapshotVersion is the schema version of snapshots. ReadSnapshot refuses any other version, so a change
+//to the schema must bump it and migrate snapshots of earlier versions as they are read.
+//Version 2 added the config section, which version 1 snapshots are read without.
+const SnapshotVersion = 2
+
+//SnapshotFormat is how a snapshot is encoded
+type SnapshotFormat string
+
+const (
+	SnapshotJSON SnapshotFormat = "json"
+	//SnapshotBinary is gob encoding, after a magic header that tells it apart from json
+	SnapshotBinary SnapshotFormat = "binary"
+)
+
+//snapshotMagic starts every binary snapshot
+var snapshotMagic = []byte("VLSNAP\x00")
+
+//Snapshot is the state of every customer account at a point in time, and of the config they were decided with
+type Snapshot struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	Accounts  []CustomerAccount `json:"accounts"`
+	//Config holds the policies, tiers, tier assignments, overrides and standing of customers of a ConfigStore.
+	//It is nil when the accounts were decided without one.
+	Config *Config `json:"config,omitempty"`
+	//Checksum is the hex sha256 of the accounts encoded as json, followed by the config, whatever the snapshot format
+	Checksum string `json:"checksum"`
+}
+
+//Accounts returns every account held in the cache, ordered by ID
+func Accounts(c *cache.Cache) []CustomerAccount {
+	accounts := []CustomerAccount{}
+	for _, item := range c.Items() {
+		if account, ok := item.Object.(CustomerAccount); ok {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].ID < accounts[j].ID
+	})
+	return accounts
+}
+
+//RestoreAccounts puts the accounts in the cache, replacing any held under the same ID, and adds their
+//accepted loads to the histories of the program and the tiers, funding sources, devices and IP addresses
+//they were made with
+func RestoreAccounts(c *cache.Cache, accounts []CustomerAccount) {
+	for _, account := range accounts {
+		c.Set(account.ID, account, cache.DefaultExpiration)
+	}
+	restoreEntities(c, accounts)
+}
+
+//NewSnapshot takes a snapshot of every account in the cache
+func NewSnapshot(c *cache.Cache, at time.Time) (Snapshot, error) {
+	return NewConfigSnapshot(c, nil, at)
+}
+
+//NewConfigSnapshot takes a snapshot of every account in the cache along with the config, if there is one
+func NewConfigSnapshot(c *cache.Cache, config *Config, at time.Time) (Snapshot, error) {
+	snapshot := Snapshot{Version: SnapshotVersion, CreatedAt: at, Accounts: Accounts(c), Config: config}
+	//empty histories are dropped, as binary snapshots cannot tell them from missing ones
+	for i, account := range snapshot.Accounts {
+		if len(account.Transactions) == 0 {
+			snapshot.Accounts[i].Transactions = nil
+		}
+		if len(account.Declined) == 0 {
+			snapshot.Accounts[i].Declined = nil
+		}
+	}
+	checksum, err := snapshot.checksum()
+	snapshot.Checksum = checksum
+	return snapshot, err
+}
+
+//Restore verifies the snapshot and puts its accounts in the cache, leaving the cache alone if it fails.
+//Its config is not restored, see ConfigStore.Restore.
+func (s Snapshot) Restore(c *cache.Cache) error {
+	if err := s.Verify(); err != nil {
+		return err
+	}
+	RestoreAccounts(c, s.Accounts)
+	return nil
+}
+
+//Verify checks the snapshot's version and checksum, and that every account is consistent with itself.
+//Snapshots of earlier versions are checked as of their version.
+func (s Snapshot) Verify() error {
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("snapshot version %d is not supported, expected %d", s.Version, SnapshotVersion)
+	}
+	checksum, err := s.checksum()
+	if err != nil {
+		return err
+	}
+	if checksum != s.Checksum {
+		return fmt.Errorf("snapshot checksum %s does not match its accounts, which sum to %s", s.Checksum, checksum)
+	}
+	ids := make(map[string]bool)
+	for _, account := range s.Accounts {
+		if account.ID == "" {
+			return errors.New("snapshot has an account without an ID")
+		}
+		if ids[account.ID] {
+			return fmt.Errorf("snapshot has account %s more than once", account.ID)
+		}
+		ids[account.ID] = true
+		if err = account.verify(); err != nil {
+			return err
+		}
+	}
+	if s.Config != nil {
+		return s.Config.verify()
+	}
+	return nil
+}
+
+//migrate brings a verified snapshot of an earlier version up to SnapshotVersion
+func (s *Snapshot) migrate() error {
+	if s.Version == SnapshotVersion {
+		return nil
+	}
+	//version 1 has no config section, which is the same as a nil config
+	s.Version = SnapshotVersion
+	checksum, err := s.checksum()
+	s.Checksum = checksum
+	return err
+}
+
+//verify checks that every load in the account's history belongs to it and has its ID recorded
+func (a CustomerAccount) verify() error {
+	for _, history := range []map[string][]Fund{a.Transactions, a.Declined} {
+		for date, loads := range history {
+			for _, load := range loads {
+				if load.CustomerID != a.ID {
+					return fmt.Errorf("account %s has load %s of customer %s", a.ID, load.ID, load.CustomerID)
+				}
+				if load.Time.Format(dateLayout) != date {
+					return fmt.Errorf("account %s has load %s filed under %s", a.ID, load.ID, date)
+				}
+				if !find(a.LoadIDs, load.ID) {
+					return fmt.Errorf("account %s has load %s without its ID", a.ID, load.ID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s Snapshot) checksum() (string, error) {
+	jsonByte, err := json.Marshal(s.Accounts)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write(jsonByte)
+	//version 1 sums only the accounts
+	if s.Version >= 2 {
+		if jsonByte, err = json.Marshal(s.Config); err != nil {
+			return "", err
+		}
+		hash.Write(jsonByte)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+//WriteSnapshot encodes the snapshot in the given format
+func WriteSnapshot(w io.Writer, s Snapshot, format SnapshotFormat) error {
+	switch format {
+	case SnapshotJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(&s)
+	case SnapshotBinary:
+		if _, err := w.Write(snapshotMagic); err != nil {
+			return err
+		}
+		return gob.NewEncoder(w).Encode(&s)
+	}
+	return fmt.Errorf("unknown snapshot format %q, expected json or binary", format)
+}
+
+//ReadSnapshot decodes a snapshot in either format, verifies it and migrates it to SnapshotVersion
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	reader := bufio.NewReader(r)
+	s := Snapshot{}
+	head, err := reader.Peek(len(snapshotMagic))
+	if err != nil && err != io.EOF {
+		return s, err
+	}
+	if bytes.Equal(head, snapshotMagic) {
+		reader.Discard(len(snapshotMagic))
+		err = gob.NewDecoder(reader).Decode(&s)
+	} else {
+		err = json.NewDecoder(reader).Decode(&s)
+	}
+	if err != nil {
+		return s, fmt.Errorf("snapshot: %s", err)
+	}
+	if err = s.Verify(); err != nil {
+		return s, err
+	}
+	return s, s.migrate()
+}
+
+//LoadSnapshot reads a snapshot file in either format and verifies it
+func LoadSnapshot(path string) (Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer file.Close()
+	snapshot, err := ReadSnapshot(file)
+	if err != nil {
+		return snapshot, fmt.Errorf("%s: %s", path, err)
+	}
+	return snapshot, nil
+}
+
+//Save writes the snapshot to a file through a rename, so a failed save leaves any earlier file intact
+func (s Snapshot) Save(path string, format SnapshotFormat) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		return WriteSnapshot(w, s, format)
+	})
+}
@@ -0,0 +1,168 @@
+package account
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnapshotTestSuite struct {
+	suite.Suite
+	cache *cache.Cache
+	at    time.Time
+}
+
+func TestSnapshot(t *testing.T) {
+	suite.Run(t, new(SnapshotTestSuite))
+}
+
+func (s *SnapshotTestSuite) SetupTest() {
+	s.cache = cache.New(cache.NoExpiration, 0)
+	s.at = time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	service := CustomerAccount{}
+	loads := []Fund{
+		{ID: "1", CustomerID: "18", LoadAmount: 4000.00, Time: s.at},
+		{ID: "2", CustomerID: "18", LoadAmount: 2000.00, Time: s.at.Add(time.Hour)},
+		{ID: "3", CustomerID: "19", LoadAmount: 100.25, Time: time.Date(2020, 11, 18, 10, 0, 0, 0, time.FixedZone("", -5*60*60))},
+	}
+	for _, load := range loads {
+		service.LoadFund(load, s.cache)
+	}
+}
+
+func (s *SnapshotTestSuite) TestRoundTrip() {
+	for _, format := range []SnapshotFormat{SnapshotJSON, SnapshotBinary} {
+		snapshot, err := NewSnapshot(s.cache, s.at)
+		s.Require().NoError(err)
+		var buf bytes.Buffer
+		s.Require().NoError(WriteSnapshot(&buf, snapshot, format))
+		read, err := ReadSnapshot(&buf)
+		s.Require().NoError(err, format)
+		restored := cache.New(cache.NoExpiration, 0)
+		s.Require().NoError(read.Restore(restored))
+		if !reflect.DeepEqual(checksumOf(s, Accounts(restored)), checksumOf(s, Accounts(s.cache))) {
+			s.T().Errorf("%s: restored accounts %+v, expected %+v", format, Accounts(restored), Accounts(s.cache))
+		}
+		//the restored accounts carry on deciding loads the same way
+		exists, err := CustomerAccount{}.LoadFund(Fund{ID: "5", CustomerID: "18", LoadAmount: 1000.01, Time: s.at.Add(2 * time.Hour)}, restored)
+		s.False(exists)
+		s.Error(err)
+		exists, _ = CustomerAccount{}.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: s.at.Add(2 * time.Hour)}, restored)
+		s.True(exists)
+	}
+}
+
+func checksumOf(s *SnapshotTestSuite, accounts []CustomerAccount) string {
+	snapshot := Snapshot{Accounts: accounts}
+	checksum, err := snapshot.checksum()
+	s.Require().NoError(err)
+	return checksum
+}
+
+func (s *SnapshotTestSuite) TestTamperedSnapshotIsRefused() {
+	snapshot, err := NewSnapshot(s.cache, s.at)
+	s.Require().NoError(err)
+	var buf bytes.Buffer
+	s.Require().NoError(WriteSnapshot(&buf, snapshot, SnapshotJSON))
+	tampered := strings.Replace(buf.String(), "4000", "40", 1)
+	_, err = ReadSnapshot(strings.NewReader(tampered))
+	s.Error(err)
+
+	restored := cache.New(cache.NoExpiration, 0)
+	snapshot.Accounts[0].LoadIDs = nil
+	s.Error(snapshot.Restore(restored))
+	s.Empty(restored.Items())
+}
+
+func (s *SnapshotTestSuite) TestVerify() {
+	snapshot, err := NewSnapshot(s.cache, s.at)
+	s.Require().NoError(err)
+	cases := []struct {
+		name   string
+		change func(*Snapshot)
+	}{
+		{"newer version", func(snapshot *Snapshot) { snapshot.Version = SnapshotVersion + 1 }},
+		{"duplicate account", func(snapshot *Snapshot) { snapshot.Accounts = append(snapshot.Accounts, snapshot.Accounts[0]) }},
+		{"load of another customer", func(snapshot *Snapshot) { snapshot.Accounts[0].ID = "19" }},
+	}
+	for _, c := range cases {
+		changed := snapshot
+		changed.Accounts = append([]CustomerAccount(nil), snapshot.Accounts...)
+		c.change(&changed)
+		//recompute the checksum so only the check under test can fail
+		changed.Checksum, err = changed.checksum()
+		s.Require().NoError(err)
+		if changed.Verify() == nil {
+			s.T().Errorf("%s: error was expected, but no error return", c.name)
+		}
+	}
+	s.NoError(snapshot.Verify())
+}
+
+func (s *SnapshotTestSuite) TestSaveAndLoad() {
+	dir, err := ioutil.TempDir("", "snapshot")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "accounts.snapshot")
+	snapshot, err := NewSnapshot(s.cache, s.at)
+	s.Require().NoError(err)
+	s.Require().NoError(snapshot.Save(path, SnapshotBinary))
+	loaded, err := LoadSnapshot(path)
+	s.Require().NoError(err)
+	s.Equal(snapshot.Checksum, loaded.Checksum)
+	s.True(loaded.CreatedAt.Equal(s.at))
+}
+
+func (s *SnapshotTestSuite) TestConfig() {
+	store := NewConfigStore(DefaultPolicy())
+	_, err := store.CreatePolicy("alice", "setup", "gold", *DefaultPolicy())
+	s.Require().NoError(err)
+	s.Require().NoError(store.CreateTier("alice", "setup", Tier{Name: "gold", Policy: "gold"}))
+	s.Require().NoError(store.AssignTier("alice", "setup", "18", "gold"))
+	_, err = store.CreateOverride("alice", "setup", Override{CustomerID: "18", Limit: "daily", MaxAmount: amount(9000), ExpiresAt: time.Now().Add(time.Hour)})
+	s.Require().NoError(err)
+	for _, format := range []SnapshotFormat{SnapshotJSON, SnapshotBinary} {
+		snapshot, err := NewConfigSnapshot(s.cache, store.Current(), s.at)
+		s.Require().NoError(err)
+		var buf bytes.Buffer
+		s.Require().NoError(WriteSnapshot(&buf, snapshot, format))
+		read, err := ReadSnapshot(&buf)
+		s.Require().NoError(err, format)
+		s.Require().NotNil(read.Config)
+		restored := NewConfigStore(DefaultPolicy())
+		s.Require().NoError(restored.Restore(read.Config))
+		s.Equal("gold@1+override-4", restored.Policy("18", time.Now()).Version, format)
+	}
+	//the config is covered by the checksum
+	snapshot, err := NewConfigSnapshot(s.cache, store.Current(), s.at)
+	s.Require().NoError(err)
+	snapshot.Config = nil
+	s.Error(snapshot.Verify())
+}
+
+func (s *SnapshotTestSuite) TestMigrateVersion1() {
+	snapshot := Snapshot{Version: 1, CreatedAt: s.at, Accounts: Accounts(s.cache)}
+	checksum, err := snapshot.checksum()
+	s.Require().NoError(err)
+	snapshot.Checksum = checksum
+	var buf bytes.Buffer
+	s.Require().NoError(WriteSnapshot(&buf, snapshot, SnapshotJSON))
+	read, err := ReadSnapshot(&buf)
+	s.Require().NoError(err)
+	s.Equal(SnapshotVersion, read.Version)
+	s.Nil(read.Config)
+	s.NoError(read.Verify())
+}
+
+func (s *SnapshotTestSuite) TestUnknownFormat() {
+	var buf bytes.Buffer
+	s.Error(WriteSnapshot(&buf, Snapshot{}, "xml"))
+}
@@ -0,0 +1,95 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+)
+
+//AccountStatus is whether a customer's account takes loads
+type AccountStatus string
+
+const (
+	//StatusActive is the status of every account that has not been frozen or closed
+	StatusActive AccountStatus = "active"
+	//StatusFrozen accounts decline loads until they are made active again
+	StatusFrozen AccountStatus = "frozen"
+	//StatusClosed accounts decline every load
+	StatusClosed AccountStatus = "closed"
+)
+
+//Validate checks the status is a known one
+func (s AccountStatus) Validate() error {
+	switch s {
+	case StatusActive, StatusFrozen, StatusClosed:
+		return nil
+	}
+	return fmt.Errorf("unknown account status %q, expected active, frozen or closed", s)
+}
+
+//Status returns the status of the customer's account
+func (c *Config) Status(customerID string) AccountStatus {
+	if status, ok := c.Statuses[customerID]; ok {
+		return status
+	}
+	return StatusActive
+}
+
+//SetStatus changes the status of the customer's account
+func (s *ConfigStore) SetStatus(actor, reason, customerID string, status AccountStatus) error {
+	if customerID == "" {
+		return errors.New("customer_id is required")
+	}
+	if err := status.Validate(); err != nil {
+		return err
+	}
+	return s.update(actor, reason, "set_status", customerID, func(c *Config) (interface{}, interface{}, error) {
+		before := c.Status(customerID)
+		if status == StatusActive {
+			delete(c.Statuses, customerID)
+		} else {
+			c.Statuses[customerID] = status
+		}
+		return before, status, nil
+	})
+}
+
+//Block adds the customer to the blocklist
+func (s *ConfigStore) Block(actor, reason, customerID string) error {
+	return s.setBlocked(actor, reason, "block", customerID, true)
+}
+
+//Unblock removes the customer from the blocklist
+func (s *ConfigStore) Unblock(actor, reason, customerID string) error {
+	return s.setBlocked(actor, reason, "unblock", customerID, false)
+}
+
+func (s *ConfigStore) setBlocked(actor, reason, action, customerID string, blocked bool) error {
+	if customerID == "" {
+		return errors.New("customer_id is required")
+	}
+	return s.update(actor, reason, action, customerID, func(c *Config) (interface{}, interface{}, error) {
+		before := c.Blocklist[customerID]
+		if before == blocked {
+			return nil, nil, existsError("blocklist entry for customer", customerID, blocked)
+		}
+		if blocked {
+			c.Blocklist[customerID] = true
+		} else {
+			delete(c.Blocklist, customerID)
+		}
+		return before, blocked, nil
+	})
+}
+
+//checkStanding declines every load for a blocked customer, or an account that is not active
+func (a CustomerAccount) checkStanding(fund *Fund, policy *Policy) error {
+	switch {
+	case policy.Blocked:
+		return decline(ReasonBlocked, "accountID: %s customer is blocked when process loadID: %s", a.ID, fund.ID)
+	case policy.Status == StatusFrozen:
+		return decline(ReasonFrozen, "accountID: %s account is frozen when process loadID: %s", a.ID, fund.ID)
+	case policy.Status == StatusClosed:
+		return decline(ReasonClosed, "accountID: %s account is closed when process loadID: %s", a.ID, fund.ID)
+	}
+	return nil
+}
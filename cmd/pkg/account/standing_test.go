@@ -0,0 +1,125 @@
+package account
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/suite"
+)
+
+type StandingTestSuite struct {
+	suite.Suite
+	store   *ConfigStore
+	service CustomerAccount
+	cache   *cache.Cache
+	day     time.Time
+}
+
+func TestStanding(t *testing.T) {
+	suite.Run(t, new(StandingTestSuite))
+}
+
+func (s *StandingTestSuite) SetupTest() {
+	s.store = NewConfigStore(DefaultPolicy())
+	s.service = CustomerAccount{Policies: s.store}
+	s.cache = cache.New(cache.NoExpiration, 0)
+	s.day = time.Date(2020, 11, 16, 10, 0, 0, 0, time.UTC)
+}
+
+func (s *StandingTestSuite) decide(id string, amount float64) Decision {
+	return s.service.Decide(Fund{ID: id, CustomerID: "18", LoadAmount: amount, Time: s.day}, s.cache)
+}
+
+func (s *StandingTestSuite) TestStatus() {
+	s.True(s.decide("1", 100.00).Accepted())
+
+	s.Require().NoError(s.store.SetStatus("alice", "chargeback", "18", StatusFrozen))
+	decision := s.decide("2", 100.00)
+	s.Equal(ReasonFrozen, decision.Reason())
+	s.EqualError(decision.Err, "accountID: 18 account is frozen when process loadID: 2")
+	s.Equal(100.00, decision.Totals.Day)
+
+	s.Require().NoError(s.store.SetStatus("alice", "chargeback resolved", "18", StatusActive))
+	s.True(s.decide("3", 100.00).Accepted())
+	s.Empty(s.store.Current().Statuses, "active accounts are not listed")
+
+	s.Require().NoError(s.store.SetStatus("alice", "customer left", "18", StatusClosed))
+	s.Equal(ReasonClosed, s.decide("4", 100.00).Reason())
+	s.True(s.decide("4", 100.00).Duplicate, "a declined load's ID is still logged")
+
+	s.EqualError(s.store.SetStatus("alice", "typo", "18", "thawed"), `unknown account status "thawed", expected active, frozen or closed`)
+	s.EqualError(s.store.SetStatus("alice", "typo", "", StatusFrozen), "customer_id is required")
+}
+
+func (s *StandingTestSuite) TestBlocklist() {
+	s.Require().NoError(s.store.Block("alice", "fraud ring", "18"))
+	s.Require().NoError(s.store.SetStatus("alice", "fraud ring", "18", StatusFrozen))
+	decision := s.decide("1", 100.00)
+	s.Equal(ReasonBlocked, decision.Reason(), "the blocklist is checked first")
+	s.EqualError(decision.Err, "accountID: 18 customer is blocked when process loadID: 1")
+
+	evaluation := s.service.Evaluate(Fund{ID: "2", CustomerID: "18", LoadAmount: 100.00, Time: s.day}, s.cache)
+	s.False(evaluation.Accepted)
+	s.Equal(ReasonBlocked, evaluation.ReasonCode)
+
+	s.Equal(ConflictError{Kind: "blocklist entry for customer", Name: "18"}, s.store.Block("alice", "again", "18"))
+	s.Require().NoError(s.store.Unblock("alice", "cleared", "18"))
+	s.Equal(NotFoundError{Kind: "blocklist entry for customer", Name: "18"}, s.store.Unblock("alice", "again", "18"))
+	s.Equal(ReasonFrozen, s.decide("3", 100.00).Reason())
+}
+
+func (s *StandingTestSuite) TestReevaluateKeepsStandingDeclines() {
+	s.service.OutOfOrder = Reevaluate
+	s.Require().NoError(s.store.SetStatus("alice", "chargeback", "18", StatusFrozen))
+	frozen := Fund{ID: "1", CustomerID: "18", LoadAmount: 100.00, Time: s.day.Add(time.Hour)}
+	s.Equal(ReasonFrozen, s.service.Decide(frozen, s.cache).Reason())
+	s.Require().NoError(s.store.SetStatus("alice", "chargeback resolved", "18", StatusActive))
+
+	//the late load is decided under the standing now, but the load made while frozen stays declined
+	decision := s.decide("2", 100.00)
+	s.True(decision.Accepted())
+	s.Empty(decision.Corrections)
+	s.Equal(100.00, decision.Totals.Day)
+	x, _ := s.cache.Get("18")
+	s.Equal(ReasonFrozen, x.(CustomerAccount).Declined["2020-11-16"][0].Standing)
+}
+
+func (s *StandingTestSuite) TestChangesAreAudited() {
+	s.Require().NoError(s.store.SetStatus("alice", "chargeback", "18", StatusFrozen))
+	s.Require().NoError(s.store.Block("bob", "fraud ring", "19"))
+	s.Error(s.store.Block("bob", "", "20"), "a reason is required")
+	changes := s.store.Changes()
+	s.Require().Len(changes, 2)
+	s.Equal(Change{Version: 1, Time: changes[0].Time, Actor: "alice", Reason: "chargeback", Action: "set_status",
+		Target: "18", Before: StatusActive, After: StatusFrozen}, changes[0])
+	s.Equal(Change{Version: 2, Time: changes[1].Time, Actor: "bob", Reason: "fraud ring", Action: "block",
+		Target: "19", Before: false, After: true}, changes[1])
+}
+
+func (s *StandingTestSuite) TestSurvivesRestart() {
+	dir, err := ioutil.TempDir("", "config")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	s.Require().NoError(s.store.SaveTo(path))
+	s.Require().NoError(s.store.SetStatus("alice", "chargeback", "18", StatusFrozen))
+	s.Require().NoError(s.store.Block("bob", "fraud ring", "19"))
+
+	config, err := LoadConfig(path)
+	s.Require().NoError(err)
+	restarted := NewConfigStore(DefaultPolicy())
+	s.Require().NoError(restarted.Restore(config))
+	s.service.Policies = restarted
+	s.Equal(ReasonFrozen, s.decide("1", 100.00).Reason())
+	s.True(restarted.Current().Blocklist["19"])
+	s.Equal(2, restarted.Current().Version)
+
+	//a change that cannot be saved is not made
+	s.Require().NoError(os.RemoveAll(dir))
+	s.Error(s.store.Unblock("bob", "cleared", "19"))
+	s.True(s.store.Current().Blocklist["19"])
+}
@@ -0,0 +1,29 @@
+package account
+
+import (
+	cache "github.com/patrickmn/go-cache"
+)
+
+//Sweep deletes every account whose retention has lapsed by the clock and returns how many were deleted.
+//The histories of funding sources, devices, IP addresses, tiers and the program lapse the same way, but are not counted.
+//It never writes an account or history back, so expired holds are left for the next load to release, and each one
+//is read again just before it is deleted in case a load renewed it since the sweep began. See FundHandler.Sweep to
+//wait for the loads being decided as well.
+func (a CustomerAccount) Sweep(c *cache.Cache) int {
+	now := a.now()
+	deleted := 0
+	for key := range c.Items() {
+		x, found := c.Get(key)
+		if !found {
+			continue
+		}
+		if account, ok := x.(CustomerAccount); ok && account.expired(now) {
+			c.Delete(key)
+			deleted++
+		}
+		if history, ok := x.(EntityHistory); ok && history.expired(now) {
+			c.Delete(key)
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,135 @@
+package account
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/stretchr/testify/suite"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type RetentionTestSuite struct {
+	suite.Suite
+	clock   *clock.Fake
+	service CustomerAccount
+	cache   *cache.Cache
+}
+
+func TestRetention(t *testing.T) {
+	suite.Run(t, new(RetentionTestSuite))
+}
+
+func (s *RetentionTestSuite) SetupTest() {
+	s.clock = clock.NewFake(time.Date(2020, 11, 18, 9, 0, 0, 0, time.UTC))
+	s.service = CustomerAccount{Clock: s.clock, Retention: 24 * time.Hour}
+	s.cache = cache.New(cache.NoExpiration, 0)
+}
+
+func (s *RetentionTestSuite) TestExpiryFollowsClockNotLoadTime() {
+	//the load happened years before it was processed, so only the clock decides when it expires
+	loadTime := time.Date(2000, 1, 3, 9, 0, 0, 0, time.UTC)
+	exists, err := s.service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 5000.00, Time: loadTime}, s.cache)
+	s.False(exists)
+	s.NoError(err)
+	x, _ := s.cache.Get("18")
+	s.Equal(s.clock.Now().Add(24*time.Hour), x.(CustomerAccount).ExpiresAt)
+
+	s.clock.Advance(23 * time.Hour)
+	exists, err = s.service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: loadTime}, s.cache)
+	s.True(exists, "account should still be retained")
+	s.Error(err)
+
+	//a duplicate does not extend retention
+	s.clock.Advance(time.Hour)
+	exists, err = s.service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: loadTime}, s.cache)
+	s.False(exists, "expired account should be forgotten")
+	s.NoError(err)
+}
+
+func (s *RetentionTestSuite) TestSweep() {
+	s.service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: s.clock.Now()}, s.cache)
+	s.clock.Advance(12 * time.Hour)
+	s.service.LoadFund(Fund{ID: "2", CustomerID: "19", LoadAmount: 1.00, Time: s.clock.Now()}, s.cache)
+	s.cache.Set("other", "not an account", cache.NoExpiration)
+
+	s.Equal(0, s.service.Sweep(s.cache))
+	s.clock.Advance(12 * time.Hour)
+	s.Equal(1, s.service.Sweep(s.cache))
+	_, found := s.cache.Get("18")
+	s.False(found)
+	_, found = s.cache.Get("19")
+	s.True(found)
+	_, found = s.cache.Get("other")
+	s.True(found)
+}
+
+func (s *RetentionTestSuite) TestRunSweeper() {
+	s.service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: s.clock.Now()}, s.cache)
+	handler := NewHandler(s.service, validator.New(), s.cache)
+	handler.SetClock(s.clock)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		handler.RunSweeper(time.Hour, stop)
+		close(done)
+	}()
+	s.waitForSweeper()
+	s.clock.Advance(25 * time.Hour)
+	s.waitForSweeper()
+	_, found := s.cache.Get("18")
+	s.False(found)
+	close(stop)
+	<-done
+}
+
+func (s *RetentionTestSuite) TestSweepWithConcurrentLoads() {
+	handler := NewHandler(s.service, validator.New(), s.cache)
+	handler.SetClock(s.clock)
+	for i := 0; i < 10; i++ {
+		handler.Load(Fund{ID: fmt.Sprint(i), CustomerID: fmt.Sprint(i), LoadAmount: 1.00, Time: s.clock.Now()})
+	}
+	//every account is due to expire, but is renewed by a load that may come in while it is swept
+	s.clock.Advance(24 * time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			handler.Load(Fund{ID: fmt.Sprint(i + 10), CustomerID: fmt.Sprint(i), LoadAmount: 1.00, Time: s.clock.Now()})
+		}(i)
+		go func() {
+			defer wg.Done()
+			handler.Sweep()
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 10; i++ {
+		x, found := s.cache.Get(fmt.Sprint(i))
+		s.Require().True(found, "customer %d was renewed", i)
+		s.Contains(x.(CustomerAccount).LoadIDs, fmt.Sprint(i+10))
+	}
+}
+
+//waitForSweeper blocks until the sweeper is waiting on the clock again
+func (s *RetentionTestSuite) waitForSweeper() {
+	deadline := time.Now().Add(time.Second)
+	for s.clock.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			s.FailNow("sweeper never waited on the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (s *RetentionTestSuite) TestNoRetention() {
+	service := CustomerAccount{Clock: s.clock}
+	service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: s.clock.Now()}, s.cache)
+	s.clock.Advance(24 * 365 * time.Hour)
+	exists, _ := service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: s.clock.Now()}, s.cache)
+	s.True(exists)
+	s.Equal(0, service.Sweep(s.cache))
+}
@@ -0,0 +1,71 @@
+package account
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//tracedHistory is the most load IDs a limit check's span lists. Limits keyed on the program count every customer's
+//loads, so the span only names the newest of them, and limit.used_loads says how many there are.
+const tracedHistory = 20
+
+//tracer traces every step of deciding a load. It does nothing unless a tracer provider is installed,
+//see the tracing package. It is looked up on every use so a provider installed later, or replaced in tests, is picked up.
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/rnidev/velocity-limits/cmd/pkg/account")
+}
+
+func fundAttributes(fund Fund) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("load.id", fund.ID),
+		attribute.String("customer.id", fund.CustomerID),
+		attribute.Float64("load.amount", fund.LoadAmount),
+		attribute.String("load.time", fund.Time.Format(time.RFC3339)),
+	}
+}
+
+//traceDecision records the outcome of a decision on the span
+func traceDecision(span trace.Span, decision Decision) {
+	span.SetAttributes(
+		attribute.Bool("decision.accepted", decision.Accepted()),
+		attribute.Bool("decision.duplicate", decision.Duplicate),
+		attribute.Int("decision.corrections", len(decision.Corrections)),
+		attribute.String("decision.outcome", string(decision.Outcome())),
+	)
+	if decision.Err != nil {
+		span.SetAttributes(attribute.String("decision.reason", decision.Err.Error()))
+	}
+}
+
+//traceLimit records what a limit check computed, and from which loads, on the span
+func (a CustomerAccount) traceLimit(span trace.Span, fund *Fund, limit Limit, usage LimitUsage, err error) {
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("load.id", fund.ID),
+		attribute.String("limit.name", limit.Name),
+		attribute.String("limit.key", fund.value(limit.Key)),
+		attribute.Float64("limit.max_amount", limit.MaxAmount),
+		attribute.Int("limit.max_loads", limit.MaxLoads),
+		attribute.String("limit.window_start", usage.WindowStart.Format(time.RFC3339)),
+		attribute.String("limit.window_end", usage.WindowEnd.Format(time.RFC3339)),
+		attribute.Float64("limit.used_amount", usage.UsedAmount),
+		attribute.Int("limit.used_loads", usage.UsedLoads),
+		attribute.StringSlice("limit.history", a.windowLoadIDs(limit, fund, tracedHistory)),
+		attribute.Bool("limit.passed", err == nil),
+	)
+	if err != nil {
+		span.SetAttributes(attribute.String("limit.reason", err.Error()))
+	}
+}
+
+//traceError marks the span failed, for errors that stop a request being decided at all
+func traceError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,118 @@
+package account
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type TraceTestSuite struct {
+	suite.Suite
+	previous trace.TracerProvider
+	recorder *tracetest.SpanRecorder
+	handler  FundHandler
+}
+
+func TestTrace(t *testing.T) {
+	suite.Run(t, new(TraceTestSuite))
+}
+
+func (s *TraceTestSuite) SetupTest() {
+	s.previous = otel.GetTracerProvider()
+	s.recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(s.recorder)))
+	s.handler = NewHandler(CustomerAccount{}, validator.New(), cache.New(cache.NoExpiration, 0))
+}
+
+func (s *TraceTestSuite) TearDownTest() {
+	otel.SetTracerProvider(s.previous)
+}
+
+//spans returns the ended spans by name, in the order they ended
+func (s *TraceTestSuite) spans(name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range s.recorder.Ended() {
+		if span.Name() == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		values[kv.Key] = kv.Value
+	}
+	return values
+}
+
+func (s *TraceTestSuite) TestDeclinedLoad() {
+	s.handler.Run(`{"id":"1","customer_id":"18","load_amount":"$3000.00","time":"2000-02-04T10:00:00Z"}`)
+	s.recorder = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(s.recorder)))
+	s.handler.Run(`{"id":"2","customer_id":"18","load_amount":"$2000.01","time":"2000-02-04T11:00:00Z"}`)
+
+	var names []string
+	for _, span := range s.recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	s.Equal([]string{"parse", "validate", "store.read", "check.duplicate", "check.limit", "store.write", "CustomerAccount.Decide", "FundHandler.Run"}, names)
+
+	root := s.spans("FundHandler.Run")[0]
+	for _, span := range s.recorder.Ended() {
+		s.Equal(root.SpanContext().TraceID(), span.SpanContext().TraceID(), span.Name())
+	}
+	s.Equal("2", attributes(root)["load.id"].AsString())
+
+	check := attributes(s.spans("check.limit")[0])
+	s.Equal("daily", check["limit.name"].AsString())
+	s.Equal(3000.00, check["limit.used_amount"].AsFloat64())
+	s.Equal([]string{"1"}, check["limit.history"].AsStringSlice())
+	s.False(check["limit.passed"].AsBool())
+	s.Contains(check["limit.reason"].AsString(), "exceed daily fund limit")
+
+	decide := attributes(s.spans("CustomerAccount.Decide")[0])
+	s.False(decide["decision.accepted"].AsBool())
+	s.Equal("default", decide["policy.version"].AsString())
+}
+
+func (s *TraceTestSuite) TestHistoryIsCapped() {
+	s.handler = NewHandler(CustomerAccount{Policy: &Policy{Version: "program", Limits: []Limit{
+		{Name: "program_daily", Period: Period{Unit: Day}, MaxLoads: 1000, Key: ByProgram},
+	}}}, validator.New(), cache.New(cache.NoExpiration, 0))
+	for i := 0; i < 30; i++ {
+		s.handler.Run(fmt.Sprintf(`{"id":"%d","customer_id":"%d","load_amount":"$10.00","time":"2000-02-04T10:%02d:00Z"}`, i, i, i))
+	}
+	checks := s.spans("check.limit")
+	check := attributes(checks[len(checks)-1])
+	s.Equal(29, int(check["limit.used_loads"].AsInt64()))
+	history := check["limit.history"].AsStringSlice()
+	s.Len(history, tracedHistory)
+	s.Equal("28", history[0], "the newest loads are listed")
+}
+
+func (s *TraceTestSuite) TestInvalidRequest() {
+	s.handler.Run(`{"id":"1","customer_id":"18","load_amount":"lots","time":"2000-02-04T10:00:00Z"}`)
+	validate := s.spans("validate")
+	s.Require().Len(validate, 1)
+	s.Equal("Error", validate[0].Status().Code.String())
+	s.Empty(s.spans("CustomerAccount.Decide"))
+}
+
+func (s *TraceTestSuite) TestLoadFund() {
+	CustomerAccount{}.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 1.00, Time: time.Date(2000, 2, 4, 10, 0, 0, 0, time.UTC)}, cache.New(cache.NoExpiration, 0))
+	root := s.spans("CustomerAccount.LoadFund")
+	s.Require().Len(root, 1)
+	s.True(attributes(root[0])["decision.accepted"].AsBool())
+	s.Len(s.spans("check.limit"), 2)
+}
@@ -0,0 +1,212 @@
+package account
+
+import (
+	"math"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+)
+
+//LimitUsage is how much of a limit a customer has consumed in the window containing a point in time.
+//The limit resets at WindowEnd. Remaining values are omitted for whatever the limit does not cap.
+type LimitUsage struct {
+	Limit           string    `json:"limit"`
+	WindowStart     time.Time `json:"window_start"`
+	WindowEnd       time.Time `json:"window_end"`
+	UsedAmount      float64   `json:"used_amount"`
+	UsedLoads       int       `json:"used_loads"`
+	RemainingAmount *float64  `json:"remaining_amount,omitempty"`
+	RemainingLoads  *int      `json:"remaining_loads,omitempty"`
+	//HeldAmount and HeldLoads are the part of the used amount and loads held by reservations not yet captured
+	HeldAmount float64 `json:"held_amount,omitempty"`
+	HeldLoads  int     `json:"held_loads,omitempty"`
+}
+
+//Usage is a customer's consumption of every limit at a point in time
+type Usage struct {
+	CustomerID string       `json:"customer_id"`
+	At         time.Time    `json:"at"`
+	Limits     []LimitUsage `json:"limits"`
+}
+
+//Headroom is the usage of a limit before an evaluated load, and whether the load fits in it
+type Headroom struct {
+	LimitUsage
+	Passed bool `json:"passed"`
+}
+
+//Evaluation is the outcome of a dry run of a load. Flags lists the soft limits the load would exceed,
+//which do not stop it being accepted.
+type Evaluation struct {
+	ID         string     `json:"id"`
+	CustomerID string     `json:"customer_id"`
+	Accepted   bool       `json:"accepted"`
+	Duplicate  bool       `json:"duplicate,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	ReasonCode Reason     `json:"reason_code,omitempty"`
+	Flags      []Flag     `json:"flags,omitempty"`
+	Limits     []Headroom `json:"limits,omitempty"`
+}
+
+//Evaluate runs every check LoadFund would for the fund and reports the headroom on each limit, without recording anything
+func (a CustomerAccount) Evaluate(fund Fund, c *cache.Cache) Evaluation {
+	policy, now := a.policy(fund.CustomerID), a.now()
+	a = a.loadAccount(fund.CustomerID, c)
+	a.tier = policy.Tier
+	fund.Tier = policy.Tier
+	a.loadEntities(c, now, policy.keys(), fund)
+	evaluation := Evaluation{
+		ID:         fund.ID,
+		CustomerID: fund.CustomerID,
+		Accepted:   true,
+	}
+	if err := a.checkIfLoadExists(fund.ID); err != nil {
+		evaluation.Accepted = false
+		evaluation.Duplicate = true
+		evaluation.Reason = err.Error()
+		return evaluation
+	}
+	err := a.checkStanding(&fund, policy)
+	if err == nil {
+		err = a.checkLoadAmount(&fund, policy)
+	}
+	if err == nil {
+		err = a.checkCooldown(&fund, policy)
+	}
+	if err != nil {
+		evaluation.Accepted = false
+		evaluation.Reason = err.Error()
+		evaluation.ReasonCode = ReasonOf(err)
+	}
+	//Unlike LoadFund, keep going after a failed check so every limit reports its headroom.
+	//Limits whose rules leave the load out are not reported.
+	for _, limit := range policy.Limits {
+		err := a.checkRules(&fund, limit)
+		if err == nil && (!limit.applies(a.ruleEnv(fund)) || !limit.keyedOn(fund)) {
+			continue
+		}
+		usage := a.usageIn(a.transactions(limit, fund), limit, fund.Time)
+		if err == nil {
+			err = a.checkUsage(&fund, limit, usage)
+		}
+		if err != nil && limit.Soft {
+			evaluation.Flags = append(evaluation.Flags, flag(limit, err))
+		} else if err != nil && evaluation.Accepted {
+			evaluation.Accepted = false
+			evaluation.Reason = err.Error()
+			evaluation.ReasonCode = ReasonOf(err)
+		}
+		evaluation.Limits = append(evaluation.Limits, Headroom{LimitUsage: usage, Passed: err == nil})
+	}
+	return evaluation
+}
+
+//Usage reports the customer's consumption of each limit as of the given time, ignoring any loads after it
+func (a CustomerAccount) Usage(customerID string, at time.Time, c *cache.Cache) Usage {
+	policy, now := a.policy(customerID), a.now()
+	a = a.loadAccount(customerID, c)
+	a.tier = policy.Tier
+	//the program and the customer's tier are known without a load, unlike a funding source, device or IP address
+	fund := Fund{CustomerID: customerID, Tier: policy.Tier}
+	a.loadEntities(c, now, policy.keys(), fund)
+	usage := Usage{
+		CustomerID: customerID,
+		At:         at,
+		Limits:     []LimitUsage{},
+	}
+	for _, limit := range policy.Limits {
+		if !limit.keyedOn(fund) {
+			continue
+		}
+		usage.Limits = append(usage.Limits, a.usageIn(loadsAsOf(a.transactions(limit, fund), at), limit, at))
+	}
+	return usage
+}
+
+func (a CustomerAccount) usage(limit Limit, at time.Time) LimitUsage {
+	return a.usageIn(a.Transactions, limit, at)
+}
+
+//usageIn adds up the loads in transactions that count towards the limit at the given time
+func (a CustomerAccount) usageIn(transactions map[string][]Fund, limit Limit, at time.Time) LimitUsage {
+	start, end := limit.Period.Bounds(at)
+	usage := LimitUsage{
+		Limit:       limit.Name,
+		WindowStart: start,
+		WindowEnd:   end,
+	}
+	var env ruleEnv
+	if len(limit.Weights) > 0 {
+		env = a.ruleEnv(Fund{})
+	}
+	//Find all loads between the start of the window and the requested date
+	for date := startOfDay(at); !date.Before(start); date = date.AddDate(0, 0, -1) {
+		for _, load := range transactions[date.Format(dateLayout)] {
+			amount, count := load.LoadAmount, 1
+			if len(limit.Weights) > 0 {
+				env.load = load
+				amount, count = limit.weigh(env)
+			}
+			usage.UsedAmount += amount
+			usage.UsedLoads += count
+			if load.HoldExpiresAt != nil {
+				usage.HeldAmount += amount
+				usage.HeldLoads += count
+			}
+		}
+	}
+	usage.UsedAmount = roundCents(usage.UsedAmount)
+	usage.HeldAmount = roundCents(usage.HeldAmount)
+	if limit.MaxAmount > 0 {
+		remaining := math.Max(roundCents(limit.MaxAmount-usage.UsedAmount), 0)
+		usage.RemainingAmount = &remaining
+	}
+	if limit.MaxLoads > 0 {
+		remaining := limit.MaxLoads - usage.UsedLoads
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.RemainingLoads = &remaining
+	}
+	return usage
+}
+
+//windowLoadIDs lists up to max of the accepted loads usage counts towards the limit for the fund, newest first
+func (a CustomerAccount) windowLoadIDs(limit Limit, fund *Fund, max int) []string {
+	start, _ := limit.Period.Bounds(fund.Time)
+	ids := []string{}
+	transactions := a.transactions(limit, *fund)
+	for date := startOfDay(fund.Time); !date.Before(start); date = date.AddDate(0, 0, -1) {
+		loads := transactions[date.Format(dateLayout)]
+		for i := len(loads) - 1; i >= 0; i-- {
+			if len(ids) == max {
+				return ids
+			}
+			ids = append(ids, loads[i].ID)
+		}
+	}
+	return ids
+}
+
+//asOf returns a copy of the account with only the loads made up to the given time
+func (a CustomerAccount) asOf(at time.Time) CustomerAccount {
+	a.Transactions = loadsAsOf(a.Transactions, at)
+	return a
+}
+
+//loadsAsOf returns the loads in transactions up to the given time
+func loadsAsOf(transactions map[string][]Fund, at time.Time) map[string][]Fund {
+	kept := make(map[string][]Fund)
+	for date, loads := range transactions {
+		for _, load := range loads {
+			if !load.Time.After(at) {
+				kept[date] = append(kept[date], load)
+			}
+		}
+	}
+	return kept
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
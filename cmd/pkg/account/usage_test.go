@@ -0,0 +1,188 @@
+package account
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/suite"
+)
+
+type UsageTestSuite struct {
+	suite.Suite
+}
+
+func TestLimitUsage(t *testing.T) {
+	suite.Run(t, new(UsageTestSuite))
+}
+
+func (s *UsageTestSuite) account(date time.Time) *cache.Cache {
+	c := cache.New(5*time.Minute, 10*time.Minute)
+	transactions := make(map[string][]Fund)
+	transactions[date.Format(dateLayout)] = []Fund{
+		Fund{ID: "29360", CustomerID: "18", LoadAmount: 1000.00, Time: date},
+		Fund{ID: "29361", CustomerID: "18", LoadAmount: 2500.50, Time: date},
+	}
+	transactions[date.AddDate(0, 0, -2).Format(dateLayout)] = []Fund{
+		Fund{ID: "29362", CustomerID: "18", LoadAmount: 15000.00, Time: date.AddDate(0, 0, -2)},
+	}
+	c.Set("18", CustomerAccount{
+		ID:           "18",
+		LoadIDs:      []string{"29360", "29361", "29362"},
+		Transactions: transactions,
+	}, cache.DefaultExpiration)
+	return c
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func (s *UsageTestSuite) TestAccepted() {
+	date := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	c := s.account(date)
+	resp := CustomerAccount{}.Evaluate(Fund{ID: "29370", CustomerID: "18", LoadAmount: 1000.00, Time: date}, c)
+	expectedResp := Evaluation{
+		ID:         "29370",
+		CustomerID: "18",
+		Accepted:   true,
+		Limits: []Headroom{
+			{
+				LimitUsage: LimitUsage{
+					Limit:           "daily",
+					WindowStart:     date,
+					WindowEnd:       date.AddDate(0, 0, 1),
+					UsedAmount:      3500.50,
+					UsedLoads:       2,
+					RemainingAmount: floatPtr(1499.50),
+					RemainingLoads:  intPtr(1),
+				},
+				Passed: true,
+			},
+			{
+				LimitUsage: LimitUsage{
+					Limit:           "weekly",
+					WindowStart:     date.AddDate(0, 0, -2),
+					WindowEnd:       date.AddDate(0, 0, 5),
+					UsedAmount:      18500.50,
+					UsedLoads:       3,
+					RemainingAmount: floatPtr(1499.50),
+				},
+				Passed: true,
+			},
+		},
+	}
+	if !reflect.DeepEqual(resp, expectedResp) {
+		s.T().Errorf("response: %+v, expected response: %+v", resp, expectedResp)
+	}
+}
+
+func (s *UsageTestSuite) TestDeclinedReportsEveryLimit() {
+	date := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	c := s.account(date)
+	resp := CustomerAccount{}.Evaluate(Fund{ID: "29370", CustomerID: "18", LoadAmount: 2000.00, Time: date}, c)
+	if resp.Accepted {
+		s.T().Error("load was expected to be declined")
+	}
+	if resp.Reason != "accountID: 18 exceed daily fund limit when process loadID: 29370" {
+		s.T().Errorf("unexpected reason %q", resp.Reason)
+	}
+	if len(resp.Limits) != 2 || resp.Limits[0].Passed || resp.Limits[1].Passed {
+		s.T().Errorf("both limits were expected to fail, got %+v", resp.Limits)
+	}
+}
+
+func (s *UsageTestSuite) TestDoesNotMutateAccount() {
+	date := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	c := s.account(date)
+	before, _ := c.Get("18")
+	CustomerAccount{}.Evaluate(Fund{ID: "29370", CustomerID: "18", LoadAmount: 10.00, Time: date}, c)
+	CustomerAccount{}.Evaluate(Fund{ID: "29371", CustomerID: "19", LoadAmount: 10.00, Time: date}, c)
+	after, _ := c.Get("18")
+	if !reflect.DeepEqual(before, after) {
+		s.T().Errorf("account changed from %+v to %+v", before, after)
+	}
+	if _, found := c.Get("19"); found {
+		s.T().Error("evaluating a new customer should not create an account")
+	}
+}
+
+func (s *UsageTestSuite) TestDuplicate() {
+	date := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	c := s.account(date)
+	resp := CustomerAccount{}.Evaluate(Fund{ID: "29360", CustomerID: "18", LoadAmount: 10.00, Time: date}, c)
+	expectedResp := Evaluation{
+		ID:         "29360",
+		CustomerID: "18",
+		Duplicate:  true,
+		Reason:     "loadID: 29360 exists",
+	}
+	if !reflect.DeepEqual(resp, expectedResp) {
+		s.T().Errorf("response: %+v, expected response: %+v", resp, expectedResp)
+	}
+}
+
+func (s *UsageTestSuite) TestUsage() {
+	date := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	c := s.account(date)
+	at := date.AddDate(0, 0, 4)
+	resp := CustomerAccount{}.Usage("18", at, c)
+	expectedResp := Usage{
+		CustomerID: "18",
+		At:         at,
+		Limits: []LimitUsage{
+			{
+				Limit:           "daily",
+				WindowStart:     date.AddDate(0, 0, 4),
+				WindowEnd:       date.AddDate(0, 0, 5),
+				RemainingAmount: floatPtr(5000.00),
+				RemainingLoads:  intPtr(3),
+			},
+			{
+				Limit:           "weekly",
+				WindowStart:     date.AddDate(0, 0, -2),
+				WindowEnd:       date.AddDate(0, 0, 5),
+				UsedAmount:      18500.50,
+				UsedLoads:       3,
+				RemainingAmount: floatPtr(1499.50),
+			},
+		},
+	}
+	if !reflect.DeepEqual(resp, expectedResp) {
+		s.T().Errorf("response: %+v, expected response: %+v", resp, expectedResp)
+	}
+}
+
+func (s *UsageTestSuite) TestUsageIgnoresLaterLoads() {
+	date := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	c := cache.New(5*time.Minute, 10*time.Minute)
+	var service Service
+	service = CustomerAccount{}
+	service.LoadFund(Fund{ID: "1", CustomerID: "18", LoadAmount: 100.00, Time: date.Add(9 * time.Hour)}, c)
+	service.LoadFund(Fund{ID: "2", CustomerID: "18", LoadAmount: 200.00, Time: date.Add(15 * time.Hour)}, c)
+	service.LoadFund(Fund{ID: "3", CustomerID: "18", LoadAmount: 400.00, Time: date.AddDate(0, 0, 1)}, c)
+	resp := service.Usage("18", date.Add(12*time.Hour), c)
+	if resp.Limits[0].UsedAmount != 100.00 || resp.Limits[0].UsedLoads != 1 {
+		s.T().Errorf("daily usage was %+v, expected only the morning load", resp.Limits[0])
+	}
+	if resp.Limits[1].UsedAmount != 100.00 {
+		s.T().Errorf("weekly usage was %+v, expected only the morning load", resp.Limits[1])
+	}
+	resp = service.Usage("18", date.AddDate(0, 0, 1), c)
+	if resp.Limits[0].UsedAmount != 400.00 || resp.Limits[1].UsedAmount != 700.00 {
+		s.T().Errorf("usage was %+v, expected all loads in the week", resp.Limits)
+	}
+}
+
+func (s *UsageTestSuite) TestUsageUnknownCustomer() {
+	at := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	resp := CustomerAccount{}.Usage("404", at, cache.New(5*time.Minute, 10*time.Minute))
+	if len(resp.Limits) != 2 || resp.Limits[0].UsedLoads != 0 || *resp.Limits[0].RemainingAmount != DailyFundLimit {
+		s.T().Errorf("unknown customer should have full headroom, got %+v", resp.Limits)
+	}
+}
@@ -0,0 +1,39 @@
+//Package atomicfile replaces files whole, so a crash or a failed write leaves either the old file or the new one
+package atomicfile
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//Write replaces the file at path with what write writes. It writes to a temporary file in the same directory,
+//syncs and closes it, then renames it over path, removing it instead if anything fails.
+func Write(path string, write func(w io.Writer) error) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if err = write(temp); err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+//WriteBytes replaces the file at path with data, like Write
+func WriteBytes(path string, data []byte) error {
+	return Write(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
@@ -0,0 +1,66 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AtomicFileTestSuite struct {
+	suite.Suite
+	dir  string
+	path string
+}
+
+func TestAtomicFile(t *testing.T) {
+	suite.Run(t, new(AtomicFileTestSuite))
+}
+
+func (s *AtomicFileTestSuite) SetupTest() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "atomicfile")
+	s.Require().NoError(err)
+	s.path = filepath.Join(s.dir, "state.json")
+}
+
+func (s *AtomicFileTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+func (s *AtomicFileTestSuite) TestReplaces() {
+	s.Require().NoError(WriteBytes(s.path, []byte("old")))
+	s.Require().NoError(WriteBytes(s.path, []byte("new")))
+	data, err := ioutil.ReadFile(s.path)
+	s.Require().NoError(err)
+	s.Equal("new", string(data))
+	s.files(1)
+}
+
+func (s *AtomicFileTestSuite) TestFailedWriteKeepsOldFile() {
+	s.Require().NoError(WriteBytes(s.path, []byte("old")))
+	err := Write(s.path, func(w io.Writer) error {
+		w.Write([]byte("half"))
+		return errors.New("disk full")
+	})
+	s.EqualError(err, "disk full")
+	data, err := ioutil.ReadFile(s.path)
+	s.Require().NoError(err)
+	s.Equal("old", string(data))
+	s.files(1)
+}
+
+func (s *AtomicFileTestSuite) TestMissingDirectory() {
+	s.Error(WriteBytes(filepath.Join(s.dir, "missing", "state.json"), []byte("new")))
+}
+
+//files checks no temporary files are left behind
+func (s *AtomicFileTestSuite) files(n int) {
+	files, err := ioutil.ReadDir(s.dir)
+	s.Require().NoError(err)
+	s.Len(files, n)
+}
@@ -0,0 +1,93 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+//Clock tells the current processing time, as opposed to the time recorded on a load
+type Clock interface {
+	Now() time.Time
+	//After sends the time on the returned channel once d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+//New returns a Clock backed by the system time
+func New() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+//Fake is a Clock that only moves when told to, for deterministic tests
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+//NewFake returns a Fake clock stopped at now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+//Now returns the time the clock was last set to
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+//After returns a channel that receives once the clock is advanced by at least d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, waiter{until: f.now.Add(d), ch: ch})
+	return ch
+}
+
+//Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+//Set moves the clock to t and fires every After that is due by then
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.until.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- t
+	}
+	f.waiters = pending
+}
+
+//Waiters returns how many calls to After have not fired yet, so tests can wait for a goroutine to start waiting
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
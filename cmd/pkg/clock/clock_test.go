@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ClockTestSuite struct {
+	suite.Suite
+}
+
+func TestClock(t *testing.T) {
+	suite.Run(t, new(ClockTestSuite))
+}
+
+func (s *ClockTestSuite) TestFakeNow() {
+	start := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	s.Equal(start, f.Now())
+	f.Advance(90 * time.Minute)
+	s.Equal(start.Add(90*time.Minute), f.Now())
+	f.Set(start)
+	s.Equal(start, f.Now())
+}
+
+func (s *ClockTestSuite) TestFakeAfter() {
+	start := time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	short := f.After(time.Minute)
+	long := f.After(time.Hour)
+	s.Equal(2, f.Waiters())
+
+	f.Advance(59 * time.Second)
+	s.Empty(short)
+	f.Advance(time.Second)
+	s.Equal(start.Add(time.Minute), <-short)
+	s.Empty(long)
+	s.Equal(1, f.Waiters())
+
+	f.Advance(2 * time.Hour)
+	s.Equal(start.Add(2*time.Hour+time.Minute), <-long)
+	s.Equal(0, f.Waiters())
+
+	s.Equal(f.Now(), <-f.After(0))
+}
+
+func (s *ClockTestSuite) TestRealClock() {
+	before := time.Now()
+	now := New().Now()
+	s.False(now.Before(before))
+	s.NotNil(New().After(time.Millisecond))
+}
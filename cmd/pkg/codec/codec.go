@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+)
+
+//Decoder reads fund requests one at a time. It returns io.EOF after the last request, and a *RecordError
+//for a request that cannot be decoded, after which decoding can carry on with the next one.
+type Decoder interface {
+	Decode() (account.FundRequest, error)
+}
+
+//Encoder writes records such as account.FundResponse. Flush must be called after the last one.
+type Encoder interface {
+	Encode(record interface{}) error
+	Flush() error
+}
+
+//Format decodes requests from and encodes records to one file format
+type Format interface {
+	NewDecoder(r io.Reader) Decoder
+	NewEncoder(w io.Writer) Encoder
+}
+
+//RecordError is a request that could not be decoded
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+var (
+	mu         sync.RWMutex
+	formats    = make(map[string]Format)
+	extensions = make(map[string]string)
+)
+
+func init() {
+	Register("ndjson", NDJSON{}, ".ndjson", ".jsonl", ".txt")
+	Register("csv", CSV{}, ".csv")
+	Register("tsv", CSV{Comma: '\t'}, ".tsv")
+}
+
+//Register makes a format available by name, and for files with any of the given extensions
+func Register(name string, format Format, exts ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	formats[name] = format
+	for _, ext := range exts {
+		extensions[strings.ToLower(ext)] = name
+	}
+}
+
+//Lookup returns the format registered under name
+func Lookup(name string) (Format, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if format, found := formats[name]; found {
+		return format, nil
+	}
+	names := make([]string, 0, len(formats))
+	for registered := range formats {
+		names = append(names, registered)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown format %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+//ForPath returns the name of the format registered for the path's extension, or ndjson if there is none
+func ForPath(path string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if name, found := extensions[strings.ToLower(filepath.Ext(path))]; found {
+		return name
+	}
+	return "ndjson"
+}
+
+//ReadAll decodes every request, passing each one that cannot be decoded to skip instead of stopping
+func ReadAll(d Decoder, skip func(error)) ([]account.FundRequest, error) {
+	var requests []account.FundRequest
+	for {
+		request, err := d.Decode()
+		if err == io.EOF {
+			return requests, nil
+		}
+		if recordErr, ok := err.(*RecordError); ok {
+			skip(recordErr)
+			continue
+		}
+		if err != nil {
+			return requests, err
+		}
+		requests = append(requests, request)
+	}
+}
@@ -0,0 +1,184 @@
+package codec
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/stretchr/testify/suite"
+)
+
+type CodecTestSuite struct {
+	suite.Suite
+}
+
+func TestCodec(t *testing.T) {
+	suite.Run(t, new(CodecTestSuite))
+}
+
+var requests = []account.FundRequest{
+	{ID: "1", CustomerID: "18", LoadAmount: "$3000.00", Time: "2000-02-04T10:00:00Z"},
+	{ID: "2", CustomerID: "19", LoadAmount: "$1500.00", Time: "2000-02-04T11:00:00Z"},
+}
+
+func (s *CodecTestSuite) decode(format Format, input string) ([]account.FundRequest, []error) {
+	var skipped []error
+	decoded, err := ReadAll(format.NewDecoder(strings.NewReader(input)), func(err error) {
+		skipped = append(skipped, err)
+	})
+	s.Require().NoError(err)
+	return decoded, skipped
+}
+
+func (s *CodecTestSuite) TestForPath() {
+	cases := map[string]string{
+		"input.txt":        "ndjson",
+		"input.NDJSON":     "ndjson",
+		"dir.csv/input":    "ndjson",
+		"loads.csv":        "csv",
+		"loads.tsv":        "tsv",
+		"/tmp/loads.jsonl": "ndjson",
+	}
+	for path, expected := range cases {
+		if name := ForPath(path); name != expected {
+			s.T().Errorf("%s: format was %s, expected %s", path, name, expected)
+		}
+	}
+	_, err := Lookup("xml")
+	s.Error(err)
+}
+
+func (s *CodecTestSuite) TestNDJSON() {
+	input := `{"id":"1","customer_id":"18","load_amount":"$3000.00","time":"2000-02-04T10:00:00Z"}
+
+not json
+{"id":"2","customer_id":"19","load_amount":"$1500.00","time":"2000-02-04T11:00:00Z"}`
+	decoded, skipped := s.decode(NDJSON{}, input)
+	if !reflect.DeepEqual(decoded, requests) {
+		s.T().Errorf("requests: %+v, expected requests: %+v", decoded, requests)
+	}
+	s.Require().Len(skipped, 1)
+	s.Equal(3, skipped[0].(*RecordError).Line)
+}
+
+func (s *CodecTestSuite) TestNDJSONLongLine() {
+	long := account.FundRequest{ID: strings.Repeat("x", 10000), CustomerID: "1", LoadAmount: "$1", Time: "2000-01-01T00:00:00Z"}
+	var buf bytes.Buffer
+	encoder := NDJSON{}.NewEncoder(&buf)
+	s.Require().NoError(encoder.Encode(&long))
+	s.Require().NoError(encoder.Flush())
+	decoded, skipped := s.decode(NDJSON{}, buf.String())
+	s.Empty(skipped)
+	s.Equal([]account.FundRequest{long}, decoded)
+}
+
+func (s *CodecTestSuite) TestNDJSONEncode() {
+	var buf bytes.Buffer
+	encoder := NDJSON{}.NewEncoder(&buf)
+	s.Require().NoError(encoder.Encode(&account.FundResponse{ID: "1", CustomerID: "18", Accepted: true}))
+	s.Require().NoError(encoder.Encode(&account.FundResponse{ID: "2", CustomerID: "18", Accepted: false, Correction: true}))
+	s.Require().NoError(encoder.Flush())
+	s.Equal(`{"id":"1","customer_id":"18","accepted":true}
+{"id":"2","customer_id":"18","accepted":false,"correction":true}
+`, buf.String())
+}
+
+func (s *CodecTestSuite) TestCSV() {
+	input := `time,load_amount,customer_id,id
+2000-02-04T10:00:00Z,$3000.00,18,1
+"2000-02-04T10:30:00Z,"$1",18,x
+2000-02-04T11:00:00Z,$1500.00,19,2
+`
+	decoded, skipped := s.decode(CSV{}, input)
+	if !reflect.DeepEqual(decoded, requests) {
+		s.T().Errorf("requests: %+v, expected requests: %+v", decoded, requests)
+	}
+	s.Len(skipped, 1)
+}
+
+func (s *CodecTestSuite) TestCSVColumnsAndDelimiter() {
+	columns, err := ParseColumns("id=load_id,customer_id=customer,time=at")
+	s.Require().NoError(err)
+	input := "load_id;customer;load_amount;at;note\n" +
+		"1;18;$3000.00;2000-02-04T10:00:00Z;first\n" +
+		"2;19;$1500.00;2000-02-04T11:00:00Z\n"
+	decoded, skipped := s.decode(CSV{Comma: ';', Columns: columns}, input)
+	s.Empty(skipped)
+	if !reflect.DeepEqual(decoded, requests) {
+		s.T().Errorf("requests: %+v, expected requests: %+v", decoded, requests)
+	}
+}
+
+func (s *CodecTestSuite) TestCSVNoHeader() {
+	input := "1,18,$3000.00,2000-02-04T10:00:00Z\n2,19,$1500.00,2000-02-04T11:00:00Z\n"
+	decoded, _ := s.decode(CSV{NoHeader: true}, input)
+	if !reflect.DeepEqual(decoded, requests) {
+		s.T().Errorf("requests: %+v, expected requests: %+v", decoded, requests)
+	}
+}
+
+func (s *CodecTestSuite) TestCSVOptionalColumns() {
+	columns, err := ParseColumns("device_id=device")
+	s.Require().NoError(err)
+	input := "id,customer_id,load_amount,time,device,ip\n" +
+		"1,18,$3000.00,2000-02-04T10:00:00Z,phone,10.0.0.1\n"
+	decoded, skipped := s.decode(CSV{Columns: columns}, input)
+	s.Empty(skipped)
+	expected := requests[0]
+	expected.DeviceID = "phone"
+	expected.IP = "10.0.0.1"
+	s.Equal([]account.FundRequest{expected}, decoded)
+
+	decoded, _ = s.decode(CSV{NoHeader: true}, "1,18,$3000.00,2000-02-04T10:00:00Z,card,phone\n")
+	expected = requests[0]
+	expected.FundingSourceID = "card"
+	expected.DeviceID = "phone"
+	s.Equal([]account.FundRequest{expected}, decoded)
+}
+
+func (s *CodecTestSuite) TestCSVMissingColumn() {
+	_, err := ReadAll(CSV{}.NewDecoder(strings.NewReader("id,customer_id,amount,time\n")), func(error) {})
+	s.Error(err)
+}
+
+func (s *CodecTestSuite) TestParseColumns() {
+	for _, mapping := range []string{"id", "id=", "amount=value", "id=a,,time=b"} {
+		if _, err := ParseColumns(mapping); err == nil {
+			s.T().Errorf("%q: error was expected, but no error return", mapping)
+		}
+	}
+}
+
+func (s *CodecTestSuite) TestCSVEncode() {
+	type nested struct {
+		account.FundResponse
+		Amount  float64  `json:"amount"`
+		Limits  []string `json:"limits"`
+		Skipped string   `json:"-"`
+		Count   *int     `json:"count,omitempty"`
+	}
+	var buf bytes.Buffer
+	encoder := CSV{Comma: '\t'}.NewEncoder(&buf)
+	s.Require().NoError(encoder.Encode(&nested{
+		FundResponse: account.FundResponse{ID: "1", CustomerID: "18", Accepted: true},
+		Amount:       1500.5,
+		Limits:       []string{"daily", "weekly"},
+		Skipped:      "x",
+	}))
+	//an empty list leaves its cell empty
+	s.Require().NoError(encoder.Encode(&nested{FundResponse: account.FundResponse{ID: "2", CustomerID: "18"}}))
+	s.Require().NoError(encoder.Flush())
+	s.Equal("id\tcustomer_id\taccepted\tcorrection\tamount\tlimits\tcount\n"+
+		"1\t18\ttrue\tfalse\t1500.5\t\"[\"\"daily\"\",\"\"weekly\"\"]\"\t\n"+
+		"2\t18\tfalse\tfalse\t0\t\t\n", buf.String())
+}
+
+func (s *CodecTestSuite) TestCSVEncodeNoHeader() {
+	var buf bytes.Buffer
+	encoder := CSV{NoHeader: true}.NewEncoder(&buf)
+	s.Require().NoError(encoder.Encode(&account.FundResponse{ID: "1", CustomerID: "18", Accepted: true}))
+	s.Require().NoError(encoder.Flush())
+	s.Equal("1,18,true,false\n", buf.String())
+}
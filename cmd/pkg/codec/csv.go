@@ -0,0 +1,269 @@
+package codec
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+)
+
+//requestFields are the request columns, in the order used for files without a header
+var requestFields = []string{"id", "customer_id", "load_amount", "time"}
+
+//optionalFields are the request columns a file may leave out, following the others in files without a header
+var optionalFields = []string{"funding_source_id", "device_id", "ip"}
+
+//columnFields are every request column, in the order used for files without a header
+var columnFields = append(append([]string{}, requestFields...), optionalFields...)
+
+//CSV is delimiter separated values with a header row naming the columns
+type CSV struct {
+	//Comma is the field delimiter, a comma when zero
+	Comma rune
+	//Columns maps request fields (id, customer_id, load_amount and time, and optionally funding_source_id, device_id
+	//and ip) to the header names used for them in the file. A field that is not mapped is read from the column with
+	//its own name.
+	Columns map[string]string
+	//NoHeader reads files without a header row, with columns in the order id, customer_id, load_amount and time,
+	//optionally followed by funding_source_id, device_id and ip, and writes records without one
+	NoHeader bool
+}
+
+//ParseColumns reads a column mapping such as "id=load_id,time=timestamp"
+func ParseColumns(mapping string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if mapping == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(mapping, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("column mapping %q is not field=column", pair)
+		}
+		if !isRequestField(parts[0]) {
+			return nil, fmt.Errorf("unknown field %q, expected one of %s", parts[0], strings.Join(columnFields, ", "))
+		}
+		columns[parts[0]] = parts[1]
+	}
+	return columns, nil
+}
+
+func (f CSV) NewDecoder(r io.Reader) Decoder {
+	reader := csv.NewReader(r)
+	reader.Comma = f.comma()
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return &csvDecoder{format: f, reader: reader}
+}
+
+func (f CSV) NewEncoder(w io.Writer) Encoder {
+	writer := csv.NewWriter(w)
+	writer.Comma = f.comma()
+	return &csvEncoder{writer: writer, noHeader: f.NoHeader}
+}
+
+func (f CSV) comma() rune {
+	if f.Comma == 0 {
+		return ','
+	}
+	return f.Comma
+}
+
+type csvDecoder struct {
+	format CSV
+	reader *csv.Reader
+	//index holds the column of each request field, once the header is read
+	index map[string]int
+}
+
+func (d *csvDecoder) Decode() (account.FundRequest, error) {
+	if d.index == nil {
+		if err := d.readHeader(); err != nil {
+			return account.FundRequest{}, err
+		}
+	}
+	record, err := d.reader.Read()
+	if err == io.EOF {
+		return account.FundRequest{}, err
+	}
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		return account.FundRequest{}, &RecordError{Line: parseErr.Line, Err: parseErr.Err}
+	}
+	if err != nil {
+		return account.FundRequest{}, err
+	}
+	field := func(name string) string {
+		if i, found := d.index[name]; found && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	return account.FundRequest{
+		ID:         field("id"),
+		CustomerID: field("customer_id"),
+		LoadAmount: field("load_amount"),
+		Time:       field("time"),
+		//optional columns missing from the file are left empty
+		FundingSourceID: field("funding_source_id"),
+		DeviceID:        field("device_id"),
+		IP:              field("ip"),
+	}, nil
+}
+
+func (d *csvDecoder) readHeader() error {
+	d.index = make(map[string]int)
+	if d.format.NoHeader {
+		for i, name := range columnFields {
+			d.index[name] = i
+		}
+		return nil
+	}
+	header, err := d.reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.TrimSpace(column)] = i
+	}
+	for _, name := range requestFields {
+		column := name
+		if mapped, found := d.format.Columns[name]; found {
+			column = mapped
+		}
+		i, found := columns[column]
+		if !found {
+			return fmt.Errorf("csv header has no %q column for %s", column, name)
+		}
+		d.index[name] = i
+	}
+	for _, name := range optionalFields {
+		column := name
+		if mapped, found := d.format.Columns[name]; found {
+			column = mapped
+		}
+		if i, found := columns[column]; found {
+			d.index[name] = i
+		}
+	}
+	return nil
+}
+
+type csvEncoder struct {
+	writer   *csv.Writer
+	header   []string
+	noHeader bool
+}
+
+//Encode writes the exported fields of a struct as a row, named by their json tags. Unless NoHeader is set the header row is
+//written before the first record, so every record should be of the same type.
+func (e *csvEncoder) Encode(record interface{}) error {
+	names, values, err := flatten(reflect.ValueOf(record))
+	if err != nil {
+		return err
+	}
+	if e.header == nil {
+		e.header = names
+		if e.noHeader {
+			return e.writer.Write(values)
+		}
+		if err = e.writer.Write(names); err != nil {
+			return err
+		}
+	}
+	return e.writer.Write(values)
+}
+
+func (e *csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+//flatten lists the json names and formatted values of a struct's fields, including those of embedded structs
+func flatten(v reflect.Value) ([]string, []string, error) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("csv can only encode structs, not %s", v.Kind())
+	}
+	var names, values []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embeddedNames, embeddedValues, err := flatten(v.Field(i))
+			if err != nil {
+				return nil, nil, err
+			}
+			names = append(names, embeddedNames...)
+			values = append(values, embeddedValues...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		value, err := format(v.Field(i))
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+	return names, values, nil
+}
+
+func format(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return "", nil
+		}
+		return t.Format(time.RFC3339Nano), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return "", nil
+		}
+	}
+	//anything else, such as a list, is kept whole as json in a single cell
+	jsonByte, err := json.Marshal(v.Interface())
+	return string(jsonByte), err
+}
+
+func isRequestField(name string) bool {
+	for _, field := range columnFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
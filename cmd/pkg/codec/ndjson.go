@@ -0,0 +1,80 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+)
+
+//NDJSON is newline delimited json, one object per line. Blank lines are skipped.
+type NDJSON struct{}
+
+func (NDJSON) NewDecoder(r io.Reader) Decoder {
+	return &ndjsonDecoder{reader: bufio.NewReader(r)}
+}
+
+func (NDJSON) NewEncoder(w io.Writer) Encoder {
+	return &ndjsonEncoder{writer: bufio.NewWriter(w)}
+}
+
+type ndjsonDecoder struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func (d *ndjsonDecoder) Decode() (account.FundRequest, error) {
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return account.FundRequest{}, err
+		}
+		d.line++
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		request := account.FundRequest{}
+		if err = json.Unmarshal(line, &request); err != nil {
+			return account.FundRequest{}, &RecordError{Line: d.line, Err: err}
+		}
+		return request, nil
+	}
+}
+
+//readLine reads a whole line however long it is, returning io.EOF once there are no more lines
+func (d *ndjsonDecoder) readLine() ([]byte, error) {
+	var buffer bytes.Buffer
+	for {
+		line, isPrefix, err := d.reader.ReadLine()
+		buffer.Write(line)
+		if err != nil {
+			if err == io.EOF && buffer.Len() > 0 {
+				return buffer.Bytes(), nil
+			}
+			return nil, err
+		}
+		if !isPrefix {
+			return buffer.Bytes(), nil
+		}
+	}
+}
+
+type ndjsonEncoder struct {
+	writer *bufio.Writer
+}
+
+func (e *ndjsonEncoder) Encode(record interface{}) error {
+	jsonByte, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(e.writer, string(jsonByte))
+	return err
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.writer.Flush()
+}
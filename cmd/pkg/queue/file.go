@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/atomicfile"
+)
+
+//FileBroker keeps each topic as a file of json lines in a directory, and each committed offset in a file
+//of its own, so topics and offsets survive restarts. It is for local runs with a single process.
+type FileBroker struct {
+	dir string
+	mu  sync.Mutex
+	//ends is where the last fetch of each topic stopped reading, so the next can carry on from there
+	ends map[string]position
+}
+
+//position is the byte in a topic file that the line of an offset starts at
+type position struct {
+	offset int64
+	at     int64
+}
+
+//record is how a message is written to a topic file, its offset is its line number
+type record struct {
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value"`
+}
+
+//NewFileBroker will create a FileBroker in dir, creating the directory if needed
+func NewFileBroker(dir string) (*FileBroker, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileBroker{dir: dir, ends: make(map[string]position)}, nil
+}
+
+//Fetch reads the topic file from where the last fetch stopped, unless offset is before it, and only
+//from its first line then
+func (b *FileBroker) Fetch(topic string, offset int64, max int) ([]Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	file, err := os.Open(b.topicPath(topic))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	next := b.ends[topic]
+	if next.offset > offset {
+		next = position{}
+	}
+	if _, err = file.Seek(next.at, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var messages []Message
+	reader := bufio.NewReader(file)
+	for len(messages) < max {
+		data, err := reader.ReadBytes('\n')
+		//a line without its newline was not completely written, so it is not there yet
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return messages, err
+		}
+		line := next.offset
+		next = position{offset: line + 1, at: next.at + int64(len(data))}
+		if line < offset {
+			continue
+		}
+		r := record{}
+		if err = json.Unmarshal(data, &r); err != nil {
+			return messages, err
+		}
+		messages = append(messages, Message{Offset: line, Key: r.Key, Value: []byte(r.Value)})
+	}
+	if b.ends == nil {
+		b.ends = make(map[string]position)
+	}
+	b.ends[topic] = next
+	return messages, nil
+}
+
+//Publish appends the messages to the topic file and syncs it to disk
+func (b *FileBroker) Publish(topic string, messages ...Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var buffer bytes.Buffer
+	for _, message := range messages {
+		jsonByte, err := json.Marshal(record{Key: message.Key, Value: string(message.Value)})
+		if err != nil {
+			return err
+		}
+		buffer.Write(jsonByte)
+		buffer.WriteByte('\n')
+	}
+	file, err := os.OpenFile(b.topicPath(topic), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(buffer.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//Commit replaces the offset file through a rename, so a crash leaves either the old offset or the new one
+func (b *FileBroker) Commit(group, topic string, offset int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return atomicfile.WriteBytes(b.offsetPath(group, topic), []byte(strconv.FormatInt(offset, 10)))
+}
+
+func (b *FileBroker) Committed(group, topic string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, err := ioutil.ReadFile(b.offsetPath(group, topic))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (b *FileBroker) topicPath(topic string) string {
+	return filepath.Join(b.dir, topic+".log")
+}
+
+func (b *FileBroker) offsetPath(group, topic string) string {
+	return filepath.Join(b.dir, group+"."+topic+".offset")
+}
@@ -0,0 +1,95 @@
+package queue
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FileBrokerTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestFileBroker(t *testing.T) {
+	suite.Run(t, new(FileBrokerTestSuite))
+}
+
+func (s *FileBrokerTestSuite) SetupTest() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "broker")
+	s.Require().NoError(err)
+}
+
+func (s *FileBrokerTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+func (s *FileBrokerTestSuite) TestSurvivesReopen() {
+	broker, err := NewFileBroker(s.dir)
+	s.Require().NoError(err)
+	s.Require().NoError(broker.Publish("loads", Message{Key: "18", Value: []byte("first\nline")}, Message{Value: []byte(`{"id":"2"}`)}))
+	s.Require().NoError(broker.Publish("loads", Message{Value: []byte("third")}))
+	s.Require().NoError(broker.Commit("funds", "loads", 2))
+
+	broker, err = NewFileBroker(s.dir)
+	s.Require().NoError(err)
+	messages, err := broker.Fetch("loads", 1, 10)
+	s.Require().NoError(err)
+	s.Equal([]Message{{Offset: 1, Value: []byte(`{"id":"2"}`)}, {Offset: 2, Value: []byte("third")}}, messages)
+	messages, err = broker.Fetch("loads", 0, 1)
+	s.Require().NoError(err)
+	s.Equal([]Message{{Offset: 0, Key: "18", Value: []byte("first\nline")}}, messages)
+	offset, err := broker.Committed("funds", "loads")
+	s.Require().NoError(err)
+	s.Equal(int64(2), offset)
+}
+
+func (s *FileBrokerTestSuite) TestEmpty() {
+	broker, err := NewFileBroker(s.dir)
+	s.Require().NoError(err)
+	messages, err := broker.Fetch("loads", 0, 10)
+	s.Require().NoError(err)
+	s.Empty(messages)
+	offset, err := broker.Committed("funds", "loads")
+	s.Require().NoError(err)
+	s.Equal(int64(0), offset)
+}
+
+func (s *FileBrokerTestSuite) TestIgnoresPartialLine() {
+	broker, err := NewFileBroker(s.dir)
+	s.Require().NoError(err)
+	s.Require().NoError(broker.Publish("loads", Message{Value: []byte("whole")}))
+	file, err := os.OpenFile(filepath.Join(s.dir, "loads.log"), os.O_WRONLY|os.O_APPEND, 0644)
+	s.Require().NoError(err)
+	_, err = file.WriteString(`{"value":"torn`)
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+	messages, err := broker.Fetch("loads", 0, 10)
+	s.Require().NoError(err)
+	s.Len(messages, 1)
+}
+
+func (s *FileBrokerTestSuite) TestFetchCarriesOnFromLastFetch() {
+	broker, err := NewFileBroker(s.dir)
+	s.Require().NoError(err)
+	s.Require().NoError(broker.Publish("loads", Message{Value: []byte("first")}, Message{Value: []byte("second")}))
+	messages, err := broker.Fetch("loads", 0, 10)
+	s.Require().NoError(err)
+	s.Len(messages, 2)
+	//lines already read are not read again, so spoiling one does not stop later fetches
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, "loads.log"))
+	s.Require().NoError(err)
+	data[0] = '['
+	s.Require().NoError(ioutil.WriteFile(filepath.Join(s.dir, "loads.log"), data, 0644))
+	s.Require().NoError(broker.Publish("loads", Message{Value: []byte("third")}))
+	messages, err = broker.Fetch("loads", 2, 10)
+	s.Require().NoError(err)
+	s.Equal([]Message{{Offset: 2, Value: []byte("third")}}, messages)
+	//an earlier offset is read from the start of the file again
+	_, err = broker.Fetch("loads", 0, 10)
+	s.Error(err)
+}
@@ -0,0 +1,52 @@
+package queue
+
+import "sync"
+
+//MemoryBroker keeps topics and offsets in memory, for tests and local runs
+type MemoryBroker struct {
+	mu      sync.Mutex
+	topics  map[string][]Message
+	offsets map[string]int64
+}
+
+//NewMemoryBroker will create an empty MemoryBroker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: make(map[string][]Message), offsets: make(map[string]int64)}
+}
+
+func (b *MemoryBroker) Fetch(topic string, offset int64, max int) ([]Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	messages := b.topics[topic]
+	if offset >= int64(len(messages)) {
+		return nil, nil
+	}
+	end := offset + int64(max)
+	if end > int64(len(messages)) {
+		end = int64(len(messages))
+	}
+	return append([]Message(nil), messages[offset:end]...), nil
+}
+
+func (b *MemoryBroker) Publish(topic string, messages ...Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, message := range messages {
+		message.Offset = int64(len(b.topics[topic]))
+		b.topics[topic] = append(b.topics[topic], message)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Commit(group, topic string, offset int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.offsets[group+"/"+topic] = offset
+	return nil
+}
+
+func (b *MemoryBroker) Committed(group, topic string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.offsets[group+"/"+topic], nil
+}
@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+)
+
+//Message is a record on a topic. Offsets count from zero in the order messages were published.
+type Message struct {
+	Offset int64
+	Key    string
+	Value  []byte
+}
+
+//Broker holds topics of messages and the offsets consumer groups have committed on them.
+//MemoryBroker and FileBroker are for local testing, adapters for real brokers implement the same interface.
+type Broker interface {
+	//Fetch returns up to max messages from the topic starting at offset, or none if there are no more yet
+	Fetch(topic string, offset int64, max int) ([]Message, error)
+	//Publish appends messages to the topic, returning only once they are durably written
+	Publish(topic string, messages ...Message) error
+	//Commit records that the group has processed the topic up to, but not including, offset
+	Commit(group, topic string, offset int64) error
+	//Committed returns the offset the group committed on the topic, zero if it never has
+	Committed(group, topic string) (int64, error)
+}
+
+//Consumer decides fund requests read from an input topic and publishes the responses to an output topic.
+//The input offset is committed only after the responses for everything before it are published, so after
+//a failure the requests are read again rather than lost.
+type Consumer struct {
+	Broker Broker
+	//Group is the consumer group offsets are committed for
+	Group       string
+	InputTopic  string
+	OutputTopic string
+	Handler     *account.FundHandler
+	//BatchSize is the most requests to decide between commits, 100 when zero
+	BatchSize int
+	//PollInterval is how long Run waits when the input topic has no new requests, a second when zero
+	PollInterval time.Duration
+	//Clock tells Run when to poll again, the system clock is used when nil
+	Clock clock.Clock
+	//StatePath, when set, is where a snapshot of the accounts in Cache is saved along with the input offset
+	//it is up to before each commit. The first Poll restores it and resumes from its offset instead.
+	StatePath string
+	Cache     *cache.Cache
+
+	//pending are responses not yet published, and next is the offset to commit once they are
+	pending []Message
+	next    int64
+	fetched bool
+}
+
+//Poll decides the next batch of requests, publishes their responses and commits the input offset.
+//It returns how many requests were read, zero when the consumer is caught up. If publishing or committing
+//fails the batch is kept, and the next Poll retries it before reading anything new.
+func (c *Consumer) Poll() (int, error) {
+	if !c.fetched {
+		offset, err := c.start()
+		if err != nil {
+			return 0, err
+		}
+		c.next, c.fetched = offset, true
+	}
+	if c.pending != nil {
+		if err := c.flush(); err != nil {
+			return 0, err
+		}
+	}
+	messages, err := c.Broker.Fetch(c.InputTopic, c.next, c.batchSize())
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+	c.pending = []Message{}
+	for _, message := range messages {
+		//ignored requests have no responses
+		for _, response := range c.Handler.Process(string(message.Value)) {
+			value, err := json.Marshal(&response)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			c.pending = append(c.pending, Message{Key: response.CustomerID, Value: value})
+		}
+		c.next = message.Offset + 1
+	}
+	return len(messages), c.flush()
+}
+
+//Run polls until stop is closed, waiting PollInterval whenever the consumer is caught up or a poll fails
+func (c *Consumer) Run(stop <-chan struct{}) {
+	clk := c.clock()
+	for {
+		read, err := c.Poll()
+		if err != nil {
+			log.Print(err)
+		}
+		if read > 0 && err == nil {
+			select {
+			case <-stop:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-clk.After(c.pollInterval()):
+		}
+	}
+}
+
+//flush publishes the pending responses, then saves the state with and commits the offset after the requests they answer
+func (c *Consumer) flush() error {
+	if len(c.pending) > 0 {
+		if err := c.Broker.Publish(c.OutputTopic, c.pending...); err != nil {
+			return err
+		}
+		c.pending = []Message{}
+	}
+	if c.StatePath != "" {
+		if err := c.save(); err != nil {
+			return err
+		}
+	}
+	if err := c.Broker.Commit(c.Group, c.InputTopic, c.next); err != nil {
+		return err
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *Consumer) clock() clock.Clock {
+	if c.Clock == nil {
+		return clock.New()
+	}
+	return c.Clock
+}
+
+func (c *Consumer) batchSize() int {
+	if c.BatchSize <= 0 {
+		return 100
+	}
+	return c.BatchSize
+}
+
+func (c *Consumer) pollInterval() time.Duration {
+	if c.PollInterval <= 0 {
+		return time.Second
+	}
+	return c.PollInterval
+}
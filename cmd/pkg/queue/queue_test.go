@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/stretchr/testify/suite"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type ConsumerTestSuite struct {
+	suite.Suite
+	broker   *flakyBroker
+	consumer *Consumer
+}
+
+func TestConsumer(t *testing.T) {
+	suite.Run(t, new(ConsumerTestSuite))
+}
+
+//flakyBroker fails the next publishes or commits it is told to
+type flakyBroker struct {
+	*MemoryBroker
+	failPublishes int
+	failCommits   int
+}
+
+func (b *flakyBroker) Publish(topic string, messages ...Message) error {
+	if b.failPublishes > 0 {
+		b.failPublishes--
+		return errors.New("publish failed")
+	}
+	return b.MemoryBroker.Publish(topic, messages...)
+}
+
+func (b *flakyBroker) Commit(group, topic string, offset int64) error {
+	if b.failCommits > 0 {
+		b.failCommits--
+		return errors.New("commit failed")
+	}
+	return b.MemoryBroker.Commit(group, topic, offset)
+}
+
+var requests = []string{
+	`{"id":"1","customer_id":"18","load_amount":"$3000.00","time":"2000-02-04T10:00:00Z"}`,
+	`{"id":"1","customer_id":"18","load_amount":"$3000.00","time":"2000-02-04T10:00:00Z"}`,
+	`not json`,
+	`{"id":"2","customer_id":"18","load_amount":"$2000.01","time":"2000-02-04T11:00:00Z"}`,
+	`{"id":"3","customer_id":"19","load_amount":"$2000.00","time":"2000-02-04T12:00:00Z"}`,
+}
+
+var expected = []string{
+	`{"id":"1","customer_id":"18","accepted":true}`,
+	`{"id":"2","customer_id":"18","accepted":false}`,
+	`{"id":"3","customer_id":"19","accepted":true}`,
+}
+
+func (s *ConsumerTestSuite) SetupTest() {
+	s.broker = &flakyBroker{MemoryBroker: NewMemoryBroker()}
+	for _, request := range requests {
+		s.Require().NoError(s.broker.Publish("loads", Message{Value: []byte(request)}))
+	}
+	handler := account.NewHandler(account.CustomerAccount{}, validator.New(), cache.New(cache.NoExpiration, 0))
+	s.consumer = &Consumer{
+		Broker:      s.broker,
+		Group:       "funds",
+		InputTopic:  "loads",
+		OutputTopic: "responses",
+		Handler:     &handler,
+		BatchSize:   2,
+	}
+}
+
+//responses returns the values published to the output topic
+func (s *ConsumerTestSuite) responses() []string {
+	messages, err := s.broker.Fetch("responses", 0, 100)
+	s.Require().NoError(err)
+	values := []string{}
+	for _, message := range messages {
+		values = append(values, string(message.Value))
+	}
+	return values
+}
+
+func (s *ConsumerTestSuite) committed() int64 {
+	offset, err := s.broker.Committed("funds", "loads")
+	s.Require().NoError(err)
+	return offset
+}
+
+func (s *ConsumerTestSuite) drain() {
+	for {
+		read, err := s.consumer.Poll()
+		s.Require().NoError(err)
+		if read == 0 {
+			return
+		}
+	}
+}
+
+func (s *ConsumerTestSuite) TestConsume() {
+	s.drain()
+	s.Equal(expected, s.responses())
+	s.Equal(int64(len(requests)), s.committed())
+}
+
+func (s *ConsumerTestSuite) TestResumesFromCommittedOffset() {
+	s.Require().NoError(s.broker.Commit("funds", "loads", 3))
+	s.drain()
+	//the handler never saw load 1, so load 2 fits in the daily limit
+	s.Equal([]string{`{"id":"2","customer_id":"18","accepted":true}`, expected[2]}, s.responses())
+}
+
+func (s *ConsumerTestSuite) TestResumesWithSavedState() {
+	dir, err := ioutil.TempDir("", "consumer")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	s.consumer.StatePath = filepath.Join(dir, "funds.state")
+	s.consumer.Cache = cache.New(cache.NoExpiration, 0)
+	handler := account.NewHandler(account.CustomerAccount{}, validator.New(), s.consumer.Cache)
+	s.consumer.Handler = &handler
+	_, err = s.consumer.Poll()
+	s.Require().NoError(err)
+	//a restarted consumer starts with no accounts of its own
+	c := cache.New(cache.NoExpiration, 0)
+	handler = account.NewHandler(account.CustomerAccount{}, validator.New(), c)
+	s.consumer = &Consumer{Broker: s.broker, Group: "funds", InputTopic: "loads", OutputTopic: "responses",
+		Handler: &handler, BatchSize: 2, StatePath: s.consumer.StatePath, Cache: c}
+	s.drain()
+	s.Equal(expected, s.responses(), "load 1 is restored, so load 2 is still over the daily limit")
+	s.Equal(int64(len(requests)), s.committed())
+}
+
+func (s *ConsumerTestSuite) TestPublishFailureRetriesBatch() {
+	s.broker.failPublishes = 1
+	_, err := s.consumer.Poll()
+	s.Error(err)
+	s.Empty(s.responses())
+	s.Equal(int64(0), s.committed())
+
+	s.drain()
+	s.Equal(expected, s.responses())
+	s.Equal(int64(len(requests)), s.committed())
+}
+
+func (s *ConsumerTestSuite) TestCommitFailureDoesNotRepublish() {
+	s.broker.failCommits = 1
+	_, err := s.consumer.Poll()
+	s.Error(err)
+	s.Equal(expected[:1], s.responses())
+	s.Equal(int64(0), s.committed())
+
+	s.drain()
+	s.Equal(expected, s.responses())
+}
+
+func (s *ConsumerTestSuite) TestRun() {
+	fake := clock.NewFake(time.Date(2020, 11, 18, 0, 0, 0, 0, time.UTC))
+	s.consumer.Clock = fake
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.consumer.Run(stop)
+		close(done)
+	}()
+	s.waitForPoll(fake)
+	s.Equal(expected, s.responses())
+
+	s.Require().NoError(s.broker.Publish("loads", Message{Value: []byte(`{"id":"4","customer_id":"19","load_amount":"$1.00","time":"2000-02-04T13:00:00Z"}`)}))
+	fake.Advance(time.Second)
+	s.waitForPoll(fake)
+	s.Equal(append(expected, `{"id":"4","customer_id":"19","accepted":true}`), s.responses())
+	close(stop)
+	<-done
+}
+
+//waitForPoll waits for the consumer to catch up and wait on the clock
+func (s *ConsumerTestSuite) waitForPoll(fake *clock.Fake) {
+	deadline := time.Now().Add(time.Second)
+	for fake.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			s.FailNow("consumer never waited on the clock")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
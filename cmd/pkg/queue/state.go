@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/atomicfile"
+)
+
+//state is the accounts a consumer has built up, and the input offset of the first request they have not seen
+type state struct {
+	Offset   int64            `json:"offset"`
+	Snapshot account.Snapshot `json:"snapshot"`
+}
+
+//start returns the offset to resume from. With a StatePath that is the offset saved with the accounts,
+//which are restored to the cache, or zero when nothing was saved yet. Otherwise it is the committed offset.
+func (c *Consumer) start() (int64, error) {
+	if c.StatePath == "" {
+		return c.Broker.Committed(c.Group, c.InputTopic)
+	}
+	data, err := ioutil.ReadFile(c.StatePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	saved := state{}
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return 0, fmt.Errorf("state %s: %s", c.StatePath, err)
+	}
+	if err = saved.Snapshot.Restore(c.Cache); err != nil {
+		return 0, fmt.Errorf("state %s: %s", c.StatePath, err)
+	}
+	return saved.Offset, nil
+}
+
+//save writes the accounts and the offset after the requests they have seen through a rename, so a crash
+//leaves either the old state or the new one
+func (c *Consumer) save() error {
+	snapshot, err := account.NewSnapshot(c.Cache, c.clock().Now())
+	if err != nil {
+		return err
+	}
+	jsonByte, err := json.Marshal(state{Offset: c.next, Snapshot: snapshot})
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteBytes(c.StatePath, jsonByte)
+}
@@ -0,0 +1,172 @@
+package rpc
+
+import (
+	"context"
+	"io"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/velocitypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//Server exposes a FundHandler over gRPC
+type Server struct {
+	velocitypb.UnimplementedVelocityLimitsServer
+	handler *account.FundHandler
+}
+
+//New will create a Server that loads and evaluates funds with the handler
+func New(handler *account.FundHandler) *Server {
+	return &Server{handler: handler}
+}
+
+//Register will serve the VelocityLimits service on g
+func (s *Server) Register(g *grpc.Server) {
+	velocitypb.RegisterVelocityLimitsServer(g, s)
+}
+
+func (s *Server) LoadFund(ctx context.Context, req *velocitypb.FundRequest) (*velocitypb.LoadFundResponse, error) {
+	fund, err := s.handler.ParseRequest(fundRequest(req))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	detail, corrections := s.handler.DecideDetail(fund)
+	if (detail.FundResponse == account.FundResponse{}) {
+		return nil, status.Errorf(codes.AlreadyExists, "loadID: %s exists", fund.ID)
+	}
+	return loadFundResponse(detail, corrections), nil
+}
+
+func (s *Server) Evaluate(ctx context.Context, req *velocitypb.FundRequest) (*velocitypb.Evaluation, error) {
+	fund, err := s.handler.ParseRequest(fundRequest(req))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	evaluation := s.handler.EvaluateFund(fund)
+	out := &velocitypb.Evaluation{
+		Id:         evaluation.ID,
+		CustomerId: evaluation.CustomerID,
+		Accepted:   evaluation.Accepted,
+		Duplicate:  evaluation.Duplicate,
+		Reason:     evaluation.Reason,
+		ReasonCode: string(evaluation.ReasonCode),
+		Flags:      flags(evaluation.Flags),
+	}
+	for _, headroom := range evaluation.Limits {
+		out.Limits = append(out.Limits, &velocitypb.Headroom{Usage: limitUsage(headroom.LimitUsage), Passed: headroom.Passed})
+	}
+	return out, nil
+}
+
+//GetUsage reports a customer's consumption of each limit, as of the requested time or now
+func (s *Server) GetUsage(ctx context.Context, req *velocitypb.UsageRequest) (*velocitypb.Usage, error) {
+	if req.GetCustomerId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "customer_id is required")
+	}
+	at := s.handler.Now()
+	if req.GetAt() != nil {
+		if err := req.GetAt().CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		at = req.GetAt().AsTime()
+	}
+	usage := s.handler.Usage(req.GetCustomerId(), at)
+	out := &velocitypb.Usage{CustomerId: usage.CustomerID, At: timestamppb.New(usage.At)}
+	for _, limit := range usage.Limits {
+		out.Limits = append(out.Limits, limitUsage(limit))
+	}
+	return out, nil
+}
+
+//BatchLoad decides each request in the order received. Requests that are invalid or duplicates get
+//an ignored response so every request is answered, and the stream carries on.
+func (s *Server) BatchLoad(stream velocitypb.VelocityLimits_BatchLoadServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(s.batchLoad(req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) batchLoad(req *velocitypb.FundRequest) *velocitypb.LoadFundResponse {
+	fund, err := s.handler.ParseRequest(fundRequest(req))
+	if err != nil {
+		return &velocitypb.LoadFundResponse{Ignored: true, Error: err.Error()}
+	}
+	detail, corrections := s.handler.DecideDetail(fund)
+	if (detail.FundResponse == account.FundResponse{}) {
+		return &velocitypb.LoadFundResponse{Ignored: true, Error: "loadID: " + fund.ID + " exists"}
+	}
+	return loadFundResponse(detail, corrections)
+}
+
+func fundRequest(req *velocitypb.FundRequest) account.FundRequest {
+	return account.FundRequest{
+		ID:              req.GetId(),
+		CustomerID:      req.GetCustomerId(),
+		LoadAmount:      req.GetLoadAmount(),
+		Time:            req.GetTime(),
+		FundingSourceID: req.GetFundingSourceId(),
+		DeviceID:        req.GetDeviceId(),
+		IP:              req.GetIp(),
+	}
+}
+
+func loadFundResponse(detail account.FundDetail, corrections []account.FundDetail) *velocitypb.LoadFundResponse {
+	out := &velocitypb.LoadFundResponse{Response: fundResponse(detail)}
+	for _, correction := range corrections {
+		out.Corrections = append(out.Corrections, fundResponse(correction))
+	}
+	return out
+}
+
+func fundResponse(detail account.FundDetail) *velocitypb.FundResponse {
+	return &velocitypb.FundResponse{
+		Id:         detail.ID,
+		CustomerId: detail.CustomerID,
+		Accepted:   detail.Accepted,
+		Correction: detail.Correction,
+		Reason:     string(detail.Reason),
+		Outcome:    string(detail.Outcome),
+		Flags:      flags(detail.Flags),
+	}
+}
+
+func flags(flags []account.Flag) []*velocitypb.Flag {
+	var out []*velocitypb.Flag
+	for _, flag := range flags {
+		out = append(out, &velocitypb.Flag{Limit: flag.Limit, Reason: string(flag.Reason), Message: flag.Message})
+	}
+	return out
+}
+
+func limitUsage(usage account.LimitUsage) *velocitypb.LimitUsage {
+	out := &velocitypb.LimitUsage{
+		Limit:       usage.Limit,
+		WindowStart: timestamppb.New(usage.WindowStart),
+		WindowEnd:   timestamppb.New(usage.WindowEnd),
+		UsedAmount:  usage.UsedAmount,
+		UsedLoads:   int32(usage.UsedLoads),
+		HeldAmount:  usage.HeldAmount,
+		HeldLoads:   int32(usage.HeldLoads),
+	}
+	if usage.RemainingAmount != nil {
+		remaining := *usage.RemainingAmount
+		out.RemainingAmount = &remaining
+	}
+	if usage.RemainingLoads != nil {
+		remaining := int32(*usage.RemainingLoads)
+		out.RemainingLoads = &remaining
+	}
+	return out
+}
@@ -0,0 +1,222 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/velocitypb"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type RPCTestSuite struct {
+	suite.Suite
+	server  *grpc.Server
+	handler *account.FundHandler
+	conn    *grpc.ClientConn
+	client  velocitypb.VelocityLimitsClient
+}
+
+func TestRPC(t *testing.T) {
+	suite.Run(t, new(RPCTestSuite))
+}
+
+func (s *RPCTestSuite) SetupTest() {
+	s.serve(account.CustomerAccount{})
+}
+
+//serve will serve a handler deciding loads with the service, replacing any served already
+func (s *RPCTestSuite) serve(service account.CustomerAccount) {
+	if s.server != nil {
+		s.TearDownTest()
+	}
+	c := cache.New(cache.NoExpiration, 10*time.Minute)
+	handler := account.NewHandler(service, validator.New(), c)
+	listener := bufconn.Listen(1024 * 1024)
+	s.server = grpc.NewServer()
+	s.handler = &handler
+	New(s.handler).Register(s.server)
+	go s.server.Serve(listener)
+	var err error
+	s.conn, err = grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	s.client = velocitypb.NewVelocityLimitsClient(s.conn)
+}
+
+func (s *RPCTestSuite) TearDownTest() {
+	s.conn.Close()
+	s.server.Stop()
+	s.server = nil
+}
+
+func request(id, amount, at string) *velocitypb.FundRequest {
+	return &velocitypb.FundRequest{Id: id, CustomerId: "18", LoadAmount: amount, Time: at}
+}
+
+func (s *RPCTestSuite) TestLoadFund() {
+	resp, err := s.client.LoadFund(context.Background(), request("1", "$4000.00", "2000-02-04T12:27:00Z"))
+	s.Require().NoError(err)
+	s.True(resp.GetResponse().GetAccepted())
+	s.Equal("1", resp.GetResponse().GetId())
+
+	s.Empty(resp.GetResponse().GetReason())
+
+	resp, err = s.client.LoadFund(context.Background(), request("2", "$1000.01", "2000-02-04T13:27:00Z"))
+	s.Require().NoError(err)
+	s.False(resp.GetResponse().GetAccepted())
+	s.Equal("window_limit", resp.GetResponse().GetReason())
+
+	_, err = s.client.LoadFund(context.Background(), request("1", "$1.00", "2000-02-04T14:27:00Z"))
+	s.Equal(codes.AlreadyExists, status.Code(err))
+
+	_, err = s.client.LoadFund(context.Background(), request("3", "lots", "2000-02-04T14:27:00Z"))
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *RPCTestSuite) TestDeviceLimit() {
+	s.serve(account.CustomerAccount{Policy: &account.Policy{Version: "devices", Limits: []account.Limit{
+		{Name: "device_daily", Period: account.Period{Unit: account.Day}, MaxLoads: 1, Key: account.ByDevice},
+	}}})
+	first := request("1", "$100.00", "2000-02-04T12:27:00Z")
+	first.DeviceId = "phone"
+	resp, err := s.client.LoadFund(context.Background(), first)
+	s.Require().NoError(err)
+	s.True(resp.GetResponse().GetAccepted())
+	//another customer on the same device is held to its limit
+	second := &velocitypb.FundRequest{Id: "2", CustomerId: "19", LoadAmount: "$100.00", Time: "2000-02-04T13:27:00Z", DeviceId: "phone"}
+	resp, err = s.client.LoadFund(context.Background(), second)
+	s.Require().NoError(err)
+	s.False(resp.GetResponse().GetAccepted())
+	second.Id, second.DeviceId = "3", "tablet"
+	resp, err = s.client.LoadFund(context.Background(), second)
+	s.Require().NoError(err)
+	s.True(resp.GetResponse().GetAccepted())
+}
+
+func (s *RPCTestSuite) TestSoftLimitFlags() {
+	policy := account.DefaultPolicy()
+	policy.Limits = append(policy.Limits, account.Limit{Name: "daily_review", Period: account.Period{Unit: account.Day}, MaxAmount: 3000.00, Soft: true})
+	s.serve(account.CustomerAccount{Policy: policy})
+	resp, err := s.client.LoadFund(context.Background(), request("1", "$2000.00", "2000-02-04T12:27:00Z"))
+	s.Require().NoError(err)
+	s.Equal("accepted", resp.GetResponse().GetOutcome())
+	s.Empty(resp.GetResponse().GetFlags())
+
+	evaluation, err := s.client.Evaluate(context.Background(), request("2", "$2000.00", "2000-02-04T13:27:00Z"))
+	s.Require().NoError(err)
+	s.True(evaluation.GetAccepted())
+	s.Require().Len(evaluation.GetFlags(), 1)
+	s.Equal("daily_review", evaluation.GetFlags()[0].GetLimit())
+
+	resp, err = s.client.LoadFund(context.Background(), request("2", "$2000.00", "2000-02-04T13:27:00Z"))
+	s.Require().NoError(err)
+	s.True(resp.GetResponse().GetAccepted())
+	s.Equal("accepted_flagged", resp.GetResponse().GetOutcome())
+	s.Require().Len(resp.GetResponse().GetFlags(), 1)
+	s.Equal("daily_review", resp.GetResponse().GetFlags()[0].GetLimit())
+	s.Equal("window_limit", resp.GetResponse().GetFlags()[0].GetReason())
+}
+
+func (s *RPCTestSuite) TestEvaluateDoesNotLoad() {
+	evaluation, err := s.client.Evaluate(context.Background(), request("1", "$4000.00", "2000-02-04T12:27:00Z"))
+	s.Require().NoError(err)
+	s.True(evaluation.GetAccepted())
+	s.Require().Len(evaluation.GetLimits(), 2)
+	s.Equal("daily", evaluation.GetLimits()[0].GetUsage().GetLimit())
+	s.Equal(5000.00, evaluation.GetLimits()[0].GetUsage().GetRemainingAmount())
+
+	resp, err := s.client.LoadFund(context.Background(), request("1", "$4000.00", "2000-02-04T12:27:00Z"))
+	s.Require().NoError(err)
+	s.True(resp.GetResponse().GetAccepted())
+
+	evaluation, err = s.client.Evaluate(context.Background(), request("2", "$1000.01", "2000-02-04T13:27:00Z"))
+	s.Require().NoError(err)
+	s.False(evaluation.GetAccepted())
+	s.Equal("window_limit", evaluation.GetReasonCode())
+	s.NotEmpty(evaluation.GetReason())
+}
+
+func (s *RPCTestSuite) TestGetUsage() {
+	_, err := s.client.LoadFund(context.Background(), request("1", "$4000.00", "2000-02-04T12:27:00Z"))
+	s.Require().NoError(err)
+	usage, err := s.client.GetUsage(context.Background(), &velocitypb.UsageRequest{
+		CustomerId: "18",
+		At:         timestamppb.New(time.Date(2000, 2, 4, 23, 0, 0, 0, time.UTC)),
+	})
+	s.Require().NoError(err)
+	s.Require().Len(usage.GetLimits(), 2)
+	daily := usage.GetLimits()[0]
+	s.Equal(4000.00, daily.GetUsedAmount())
+	s.Equal(int32(1), daily.GetUsedLoads())
+	s.Equal(int32(2), daily.GetRemainingLoads())
+	s.Equal(time.Date(2000, 2, 5, 0, 0, 0, 0, time.UTC), daily.GetWindowEnd().AsTime())
+	//the weekly limit does not cap the number of loads
+	s.Nil(usage.GetLimits()[1].RemainingLoads)
+
+	_, err = s.client.GetUsage(context.Background(), &velocitypb.UsageRequest{})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *RPCTestSuite) TestHeld() {
+	fund, err := s.handler.ParseRequest(account.FundRequest{ID: "1", CustomerID: "18", LoadAmount: "$1500.00", Time: "2000-02-04T12:27:00Z"})
+	s.Require().NoError(err)
+	detail, _ := s.handler.ReserveDetail(fund, 0)
+	s.Require().True(detail.Accepted)
+	usage, err := s.client.GetUsage(context.Background(), &velocitypb.UsageRequest{
+		CustomerId: "18",
+		At:         timestamppb.New(time.Date(2000, 2, 4, 23, 0, 0, 0, time.UTC)),
+	})
+	s.Require().NoError(err)
+	s.Equal(1500.00, usage.GetLimits()[0].GetHeldAmount())
+	s.Equal(int32(1), usage.GetLimits()[0].GetHeldLoads())
+	evaluation, err := s.client.Evaluate(context.Background(), request("2", "$100.00", "2000-02-04T13:27:00Z"))
+	s.Require().NoError(err)
+	s.Equal(1500.00, evaluation.GetLimits()[0].GetUsage().GetHeldAmount())
+}
+
+func (s *RPCTestSuite) TestBatchLoad() {
+	stream, err := s.client.BatchLoad(context.Background())
+	s.Require().NoError(err)
+	requests := []*velocitypb.FundRequest{
+		request("1", "$3000.00", "2000-02-04T10:00:00Z"),
+		request("1", "$3000.00", "2000-02-04T10:00:00Z"),
+		request("2", "not an amount", "2000-02-04T11:00:00Z"),
+		request("3", "$2000.01", "2000-02-04T12:00:00Z"),
+		request("4", "$2000.00", "2000-02-04T13:00:00Z"),
+	}
+	for _, req := range requests {
+		s.Require().NoError(stream.Send(req))
+	}
+	s.Require().NoError(stream.CloseSend())
+	var responses []*velocitypb.LoadFundResponse
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		s.Require().NoError(err)
+		responses = append(responses, resp)
+	}
+	s.Require().Len(responses, len(requests))
+	s.True(responses[0].GetResponse().GetAccepted())
+	s.True(responses[1].GetIgnored())
+	s.Equal("loadID: 1 exists", responses[1].GetError())
+	s.True(responses[2].GetIgnored())
+	s.False(responses[3].GetResponse().GetAccepted())
+	s.True(responses[4].GetResponse().GetAccepted())
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+)
+
+//admin manages the policies, tiers and overrides in a ConfigStore over HTTP, and lists the loads flagged
+//for review, for callers with a token
+type admin struct {
+	store   *account.ConfigStore
+	handler *account.FundHandler
+	review  *account.ReviewQueue
+	//tokens maps each bearer token to the actor it authenticates
+	tokens map[string]string
+	mux    *http.ServeMux
+}
+
+//change is the body of every request that changes the config. Reason is required, and which of the
+//other fields are used depends on what is changed.
+type change struct {
+	Reason   string                `json:"reason"`
+	Name     string                `json:"name"`
+	Policy   account.Policy        `json:"policy"`
+	Tier     account.Tier          `json:"tier"`
+	Override account.Override      `json:"override"`
+	Status   account.AccountStatus `json:"status"`
+}
+
+//EnableAdmin will serve the admin API under /admin/, authenticating each request with a bearer token from tokens,
+//which maps each token to the actor recorded for the changes made with it
+func (s *Server) EnableAdmin(store *account.ConfigStore, tokens map[string]string) {
+	a := &admin{store: store, handler: s.handler, review: s.handler.ReviewQueue(), tokens: tokens, mux: http.NewServeMux()}
+	a.mux.HandleFunc("GET /admin/config", a.config)
+	a.mux.HandleFunc("GET /admin/snapshot", a.snapshot)
+	a.mux.HandleFunc("GET /admin/audit", a.audit)
+	a.mux.HandleFunc("GET /admin/policies", a.policies)
+	a.mux.HandleFunc("POST /admin/policies", a.change(a.createPolicy))
+	a.mux.HandleFunc("PUT /admin/policies/{name}", a.change(a.updatePolicy))
+	a.mux.HandleFunc("GET /admin/tiers", a.tiers)
+	a.mux.HandleFunc("POST /admin/tiers", a.change(a.createTier))
+	a.mux.HandleFunc("PUT /admin/tiers/{name}", a.change(a.updateTier))
+	a.mux.HandleFunc("PUT /admin/customers/{id}/tier", a.change(a.assignTier))
+	a.mux.HandleFunc("GET /admin/overrides", a.overrides)
+	a.mux.HandleFunc("POST /admin/overrides", a.change(a.createOverride))
+	a.mux.HandleFunc("PUT /admin/overrides/{id}", a.change(a.updateOverride))
+	a.mux.HandleFunc("PUT /admin/customers/{id}/status", a.change(a.setStatus))
+	a.mux.HandleFunc("GET /admin/blocklist", a.blocklist)
+	a.mux.HandleFunc("PUT /admin/blocklist/{id}", a.change(a.block))
+	a.mux.HandleFunc("DELETE /admin/blocklist/{id}", a.change(a.unblock))
+	a.mux.HandleFunc("GET /admin/review", a.reviewQueue)
+	a.mux.HandleFunc("DELETE /admin/review/{customer_id}/{id}", a.acknowledge)
+	s.mux.Handle("/admin/", a)
+}
+
+//LoadTokens reads a json object of admin bearer tokens and the actor each one authenticates
+func LoadTokens(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]string)
+	if err = json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("tokens %s: %s", path, err)
+	}
+	for token, actor := range tokens {
+		if token == "" || actor == "" {
+			return nil, fmt.Errorf("tokens %s: every token needs an actor", path)
+		}
+	}
+	return tokens, nil
+}
+
+func (a *admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.actor(r) == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+//actor returns who the request's bearer token authenticates, or nothing if it does not authenticate anyone
+func (a *admin) actor(r *http.Request) string {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return ""
+	}
+	actor := ""
+	//compare with every token in constant time, so the time taken gives nothing away
+	for candidate, name := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			actor = name
+		}
+	}
+	return actor
+}
+
+//change decodes the body of a change before passing it to next with the actor making it
+func (a *admin) change(next func(*http.Request, string, change) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body change
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		result, err := next(r, a.actor(r), body)
+		var notFound account.NotFoundError
+		var conflict account.ConflictError
+		switch {
+		case errors.As(err, &notFound):
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		case errors.As(err, &conflict):
+			writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, result)
+		}
+	}
+}
+
+func (a *admin) config(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.store.Current())
+}
+
+//snapshot returns a json snapshot of every account and the config, which the server can be started from with -import
+func (a *admin) snapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := a.handler.Snapshot(a.store.Current())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+func (a *admin) audit(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.store.Changes())
+}
+
+func (a *admin) policies(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.store.Current().Policies)
+}
+
+func (a *admin) createPolicy(r *http.Request, actor string, body change) (interface{}, error) {
+	return a.store.CreatePolicy(actor, body.Reason, body.Name, body.Policy)
+}
+
+func (a *admin) updatePolicy(r *http.Request, actor string, body change) (interface{}, error) {
+	return a.store.UpdatePolicy(actor, body.Reason, r.PathValue("name"), body.Policy)
+}
+
+func (a *admin) tiers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.store.Current().Tiers)
+}
+
+func (a *admin) createTier(r *http.Request, actor string, body change) (interface{}, error) {
+	return body.Tier, a.store.CreateTier(actor, body.Reason, body.Tier)
+}
+
+func (a *admin) updateTier(r *http.Request, actor string, body change) (interface{}, error) {
+	body.Tier.Name = r.PathValue("name")
+	return body.Tier, a.store.UpdateTier(actor, body.Reason, body.Tier)
+}
+
+func (a *admin) assignTier(r *http.Request, actor string, body change) (interface{}, error) {
+	customerID := r.PathValue("id")
+	if err := a.store.AssignTier(actor, body.Reason, customerID, body.Tier.Name); err != nil {
+		return nil, err
+	}
+	return a.store.Current().Tier(customerID), nil
+}
+
+func (a *admin) overrides(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.store.Current().Overrides)
+}
+
+func (a *admin) createOverride(r *http.Request, actor string, body change) (interface{}, error) {
+	return a.store.CreateOverride(actor, body.Reason, body.Override)
+}
+
+func (a *admin) updateOverride(r *http.Request, actor string, body change) (interface{}, error) {
+	body.Override.ID = r.PathValue("id")
+	return body.Override, a.store.UpdateOverride(actor, body.Reason, body.Override)
+}
+
+//setStatus changes the status of a customer's account and returns its standing
+func (a *admin) setStatus(r *http.Request, actor string, body change) (interface{}, error) {
+	customerID := r.PathValue("id")
+	if err := a.store.SetStatus(actor, body.Reason, customerID, body.Status); err != nil {
+		return nil, err
+	}
+	return a.standing(customerID), nil
+}
+
+func (a *admin) blocklist(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.store.Current().Blocklist)
+}
+
+func (a *admin) block(r *http.Request, actor string, body change) (interface{}, error) {
+	customerID := r.PathValue("id")
+	if err := a.store.Block(actor, body.Reason, customerID); err != nil {
+		return nil, err
+	}
+	return a.standing(customerID), nil
+}
+
+func (a *admin) unblock(r *http.Request, actor string, body change) (interface{}, error) {
+	customerID := r.PathValue("id")
+	if err := a.store.Unblock(actor, body.Reason, customerID); err != nil {
+		return nil, err
+	}
+	return a.standing(customerID), nil
+}
+
+//standing is the response to a change to a customer's status or blocklist entry
+type standing struct {
+	CustomerID string                `json:"customer_id"`
+	Status     account.AccountStatus `json:"status"`
+	Blocked    bool                  `json:"blocked"`
+}
+
+func (a *admin) standing(customerID string) standing {
+	config := a.store.Current()
+	return standing{CustomerID: customerID, Status: config.Status(customerID), Blocked: config.Blocklist[customerID]}
+}
+
+//defaultReviewPage is how many flagged loads are listed when no limit is asked for
+const defaultReviewPage = 100
+
+//reviewQueue lists a page of the loads accepted but flagged by a soft limit, oldest first, starting at the
+//offset query parameter and up to limit of them. The total queued is in the X-Total-Count header.
+func (a *admin) reviewQueue(w http.ResponseWriter, r *http.Request) {
+	offset, limit := 0, defaultReviewPage
+	for _, param := range []struct {
+		name  string
+		value *int
+		min   int
+	}{{"offset", &offset, 0}, {"limit", &limit, 1}} {
+		query := r.URL.Query().Get(param.name)
+		if query == "" {
+			continue
+		}
+		n, err := strconv.Atoi(query)
+		if err != nil || n < param.min {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid %s: %q", param.name, query)})
+			return
+		}
+		*param.value = n
+	}
+	loads := []account.FundDetail{}
+	total := 0
+	if a.review != nil {
+		loads = a.review.Page(offset, limit)
+		total = a.review.Len()
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	writeJSON(w, http.StatusOK, loads)
+}
+
+//acknowledge removes a reviewed load from the review queue and returns it
+func (a *admin) acknowledge(w http.ResponseWriter, r *http.Request) {
+	if a.review == nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "there is no review queue"})
+		return
+	}
+	load, err := a.review.Acknowledge(r.PathValue("customer_id"), r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, load)
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	validator "gopkg.in/go-playground/validator.v9"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AdminTestSuite struct {
+	suite.Suite
+	store  *account.ConfigStore
+	server *httptest.Server
+}
+
+func TestAdmin(t *testing.T) {
+	suite.Run(t, new(AdminTestSuite))
+}
+
+func (s *AdminTestSuite) SetupTest() {
+	c := cache.New(cache.NoExpiration, 10*time.Minute)
+	s.store = account.NewConfigStore(account.DefaultPolicy())
+	handler := account.NewHandler(account.CustomerAccount{Policies: s.store}, validator.New(), c)
+	handler.SetReviewQueue(account.NewReviewQueue())
+	server := New(&handler)
+	server.EnableAdmin(s.store, map[string]string{"alice-token": "alice"})
+	s.server = httptest.NewServer(server)
+}
+
+func (s *AdminTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *AdminTestSuite) do(method, path, token, body string, out interface{}) int {
+	req, err := http.NewRequest(method, s.server.URL+path, strings.NewReader(body))
+	s.Require().NoError(err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+	return resp.StatusCode
+}
+
+func (s *AdminTestSuite) TestUnauthorized() {
+	var errResp errorResponse
+	s.Equal(http.StatusUnauthorized, s.do(http.MethodGet, "/admin/config", "", "", &errResp))
+	s.Equal(http.StatusUnauthorized, s.do(http.MethodGet, "/admin/config", "mallory-token", "", &errResp))
+	s.Equal(http.StatusUnauthorized, s.do(http.MethodPost, "/admin/policies", "", `{"reason":"sneaky","name":"open"}`, &errResp))
+	s.Equal("unauthorized", errResp.Error)
+	s.Empty(s.store.Changes())
+}
+
+func (s *AdminTestSuite) TestTierChangeAppliesToLoads() {
+	var policy account.Policy
+	status := s.do(http.MethodPost, "/admin/policies", "alice-token",
+		`{"reason":"vip launch","name":"vip","policy":{"limits":[{"name":"daily","period":{"unit":"day"},"max_amount":10000}]}}`, &policy)
+	s.Require().Equal(http.StatusOK, status)
+	s.Equal("vip@1", policy.Version)
+
+	var tier account.Tier
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPost, "/admin/tiers", "alice-token", `{"reason":"vip launch","tier":{"name":"vip","policy":"vip"}}`, &tier))
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPut, "/admin/customers/18/tier", "alice-token", `{"reason":"signed up","tier":{"name":"vip"}}`, &tier))
+	s.Equal(account.Tier{Name: "vip", Policy: "vip"}, tier)
+
+	var resp loadResponse
+	req, err := http.Post(s.server.URL+"/loads", "application/json",
+		strings.NewReader(`{"id":"1","customer_id":"18","load_amount":"$8000.00","time":"2000-02-04T12:27:00Z"}`))
+	s.Require().NoError(err)
+	defer req.Body.Close()
+	s.Require().NoError(json.NewDecoder(req.Body).Decode(&resp))
+	s.True(resp.Accepted)
+	s.Equal("vip@1", resp.PolicyVersion)
+
+	var changes []account.Change
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/audit", "alice-token", "", &changes))
+	s.Require().Len(changes, 3)
+	s.Equal("alice", changes[2].Actor)
+	s.Equal("signed up", changes[2].Reason)
+	s.Equal("assign_tier", changes[2].Action)
+}
+
+func (s *AdminTestSuite) TestOverride() {
+	var override account.Override
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body := `{"reason":"house purchase","override":{"customer_id":"18","limit":"daily","max_amount":9000,"expires_at":"` + expiresAt.Format(time.RFC3339) + `"}}`
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPost, "/admin/overrides", "alice-token", body, &override))
+	s.Equal("1", override.ID)
+
+	var overrides []account.Override
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/overrides", "alice-token", "", &overrides))
+	maxAmount := 9000.00
+	s.Equal([]account.Override{{ID: "1", CustomerID: "18", Limit: "daily", MaxAmount: &maxAmount, ExpiresAt: expiresAt}}, overrides)
+	s.Equal(account.DailyNumberOfLoadsLimit, s.store.Policy("18", time.Now()).Limits[0].MaxLoads, "max_loads was not given")
+
+	body = `{"reason":"purchase cancelled","override":{"customer_id":"18","limit":"daily","max_amount":9000,"expires_at":"2000-01-01T00:00:00Z"}}`
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPut, "/admin/overrides/1", "alice-token", body, &override))
+	s.Equal("default", s.store.Policy("18", time.Now()).Version)
+}
+
+func (s *AdminTestSuite) TestSnapshot() {
+	s.Require().NoError(s.store.SetStatus("alice", "fraud", "19", account.StatusFrozen))
+	var snapshot account.Snapshot
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/snapshot", "alice-token", "", &snapshot))
+	s.NoError(snapshot.Verify())
+	s.Require().NotNil(snapshot.Config)
+	restored := account.NewConfigStore(account.DefaultPolicy())
+	s.Require().NoError(restored.Restore(snapshot.Config))
+	s.Equal(account.StatusFrozen, restored.Current().Status("19"))
+}
+
+func (s *AdminTestSuite) TestReviewQueue() {
+	policy := `{"reason":"watch big days","policy":{"limits":[` +
+		`{"name":"daily","period":{"unit":"day"},"max_amount":5000},` +
+		`{"name":"daily_review","period":{"unit":"day"},"max_amount":1000,"soft":true}]}}`
+	var updated account.Policy
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPut, "/admin/policies/default", "alice-token", policy, &updated))
+
+	var resp loadResponse
+	req, err := http.Post(s.server.URL+"/loads", "application/json",
+		strings.NewReader(`{"id":"1","customer_id":"18","load_amount":"$2000.00","time":"2000-02-04T12:27:00Z"}`))
+	s.Require().NoError(err)
+	defer req.Body.Close()
+	s.Require().NoError(json.NewDecoder(req.Body).Decode(&resp))
+	s.True(resp.Accepted)
+	s.Equal(account.OutcomeAcceptedFlagged, resp.Outcome)
+	s.Require().Len(resp.Flags, 1)
+	s.Equal("daily_review", resp.Flags[0].Limit)
+
+	var loads []account.FundDetail
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/review", "alice-token", "", &loads))
+	s.Require().Len(loads, 1)
+	s.Equal("1", loads[0].ID)
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/review?offset=1&limit=10", "alice-token", "", &loads))
+	s.Empty(loads)
+	var errResp errorResponse
+	s.Equal(http.StatusBadRequest, s.do(http.MethodGet, "/admin/review?limit=0", "alice-token", "", &errResp))
+	s.Equal(`invalid limit: "0"`, errResp.Error)
+	s.Equal(http.StatusUnauthorized, s.do(http.MethodGet, "/admin/review", "", "", &errResp))
+
+	var load account.FundDetail
+	s.Require().Equal(http.StatusOK, s.do(http.MethodDelete, "/admin/review/18/1", "alice-token", "", &load))
+	s.Equal("1", load.ID)
+	s.Equal(http.StatusNotFound, s.do(http.MethodDelete, "/admin/review/18/1", "alice-token", "", &errResp))
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/review", "alice-token", "", &loads))
+	s.Empty(loads)
+}
+
+func (s *AdminTestSuite) TestStanding() {
+	var standing map[string]interface{}
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPut, "/admin/customers/18/status", "alice-token", `{"reason":"chargeback","status":"frozen"}`, &standing))
+	s.Equal(map[string]interface{}{"customer_id": "18", "status": "frozen", "blocked": false}, standing)
+	s.Require().Equal(http.StatusOK, s.do(http.MethodPut, "/admin/blocklist/19", "alice-token", `{"reason":"fraud ring"}`, &standing))
+	s.Equal(true, standing["blocked"])
+
+	var resp loadResponse
+	req, err := http.Post(s.server.URL+"/loads", "application/json",
+		strings.NewReader(`{"id":"1","customer_id":"18","load_amount":"$100.00","time":"2000-02-04T12:27:00Z"}`))
+	s.Require().NoError(err)
+	defer req.Body.Close()
+	s.Require().NoError(json.NewDecoder(req.Body).Decode(&resp))
+	s.False(resp.Accepted)
+	s.Equal(account.ReasonFrozen, resp.Reason)
+
+	var blocklist map[string]bool
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/blocklist", "alice-token", "", &blocklist))
+	s.Equal(map[string]bool{"19": true}, blocklist)
+	s.Require().Equal(http.StatusOK, s.do(http.MethodDelete, "/admin/blocklist/19", "alice-token", `{"reason":"cleared"}`, &standing))
+	var errResp errorResponse
+	s.Equal(http.StatusNotFound, s.do(http.MethodDelete, "/admin/blocklist/19", "alice-token", `{"reason":"cleared"}`, &errResp))
+	s.Equal(http.StatusBadRequest, s.do(http.MethodPut, "/admin/customers/18/status", "alice-token", `{"reason":"typo","status":"thawed"}`, &errResp))
+	s.Len(s.store.Changes(), 3)
+}
+
+func (s *AdminTestSuite) TestErrors() {
+	var errResp errorResponse
+	s.Equal(http.StatusBadRequest, s.do(http.MethodPost, "/admin/policies", "alice-token", `{"name":"vip","policy":{"limits":[]}}`, &errResp))
+	s.Equal("reason is required", errResp.Error)
+	s.Equal(http.StatusConflict, s.do(http.MethodPost, "/admin/policies", "alice-token", `{"reason":"again","name":"default","policy":{"limits":[]}}`, &errResp))
+	s.Equal(http.StatusNotFound, s.do(http.MethodPut, "/admin/policies/vip", "alice-token", `{"reason":"typo","policy":{"limits":[]}}`, &errResp))
+	s.Equal(http.StatusBadRequest, s.do(http.MethodPut, "/admin/tiers/default", "alice-token", `{"reason":"bad","tier":{"policy":"vip"}}`, &errResp))
+	s.Equal(http.StatusBadRequest, s.do(http.MethodPost, "/admin/tiers", "alice-token", `{`, &errResp))
+
+	var config account.Config
+	s.Require().Equal(http.StatusOK, s.do(http.MethodGet, "/admin/config", "alice-token", "", &config))
+	s.Equal(0, config.Version)
+	s.Equal("default", config.Policies["default"].Version)
+}
+
+func (s *AdminTestSuite) TestLoadTokens() {
+	dir, err := ioutil.TempDir("", "tokens")
+	s.Require().NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens.json")
+	s.Require().NoError(ioutil.WriteFile(path, []byte(`{"alice-token":"alice"}`), 0600))
+	tokens, err := LoadTokens(path)
+	s.Require().NoError(err)
+	s.Equal(map[string]string{"alice-token": "alice"}, tokens)
+
+	s.Require().NoError(ioutil.WriteFile(path, []byte(`{"anonymous-token":""}`), 0600))
+	_, err = LoadTokens(path)
+	s.Error(err)
+}
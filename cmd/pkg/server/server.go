@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+)
+
+//Server exposes a FundHandler over HTTP
+type Server struct {
+	handler *account.FundHandler
+	mux     *http.ServeMux
+}
+
+//loadResponse is the response to a load, with the version of the policy it was checked against, its
+//outcome, the reason code it was declined for, the soft limits it was flagged by and any corrections to
+//earlier responses it caused
+type loadResponse struct {
+	account.FundResponse
+	PolicyVersion string                 `json:"policy_version"`
+	Outcome       account.Outcome        `json:"outcome"`
+	Reason        account.Reason         `json:"reason,omitempty"`
+	Flags         []account.Flag         `json:"flags,omitempty"`
+	Corrections   []account.FundResponse `json:"corrections,omitempty"`
+}
+
+//reservationResponse is the response to a reservation, with when an accepted load is released unless it is captured
+type reservationResponse struct {
+	loadResponse
+	HoldExpiresAt *time.Time `json:"hold_expires_at,omitempty"`
+}
+
+//holdRequest names a held load to capture or release
+type holdRequest struct {
+	ID         string `json:"id"`
+	CustomerID string `json:"customer_id"`
+}
+
+//holdResponse is the response to capturing or releasing a held load
+type holdResponse struct {
+	ID         string `json:"id"`
+	CustomerID string `json:"customer_id"`
+	Status     string `json:"status"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+//New will create a Server that loads and evaluates funds with the handler
+func New(handler *account.FundHandler) *Server {
+	s := &Server{handler: handler, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/loads", s.post(s.load))
+	s.mux.HandleFunc("/evaluate", s.post(s.evaluate))
+	s.mux.HandleFunc("/usage", s.usage)
+	s.mux.HandleFunc("/reservations", s.post(s.reserve))
+	s.mux.HandleFunc("/reservations/capture", s.post(s.settle("captured", s.handler.Capture)))
+	s.mux.HandleFunc("/reservations/release", s.post(s.settle("released", s.handler.Release)))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+//post rejects any request that is not a POST before passing its body to next
+func (s *Server) post(next func(http.ResponseWriter, string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		next(w, string(body))
+	}
+}
+
+func (s *Server) load(w http.ResponseWriter, req string) {
+	fund, err := s.handler.Parse(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	detail, correctionDetails := s.handler.DecideDetail(fund)
+	if (detail.FundResponse == account.FundResponse{}) {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: "loadID: " + fund.ID + " exists"})
+		return
+	}
+	writeJSON(w, http.StatusOK, newLoadResponse(detail, correctionDetails))
+}
+
+//reserve holds capacity for a fund request, for the duration in its "ttl" field or account.DefaultHoldTTL
+func (s *Server) reserve(w http.ResponseWriter, req string) {
+	fund, err := s.handler.Parse(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	var body struct {
+		TTL string `json:"ttl"`
+	}
+	var ttl time.Duration
+	if err = json.Unmarshal([]byte(req), &body); err == nil && body.TTL != "" {
+		ttl, err = time.ParseDuration(body.TTL)
+	}
+	if err == nil && ttl < 0 {
+		err = errors.New("ttl must not be negative")
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	detail, correctionDetails := s.handler.ReserveDetail(fund, ttl)
+	if (detail.FundResponse == account.FundResponse{}) {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: "loadID: " + fund.ID + " exists"})
+		return
+	}
+	writeJSON(w, http.StatusOK, reservationResponse{
+		loadResponse:  newLoadResponse(detail, correctionDetails),
+		HoldExpiresAt: detail.HoldExpiresAt,
+	})
+}
+
+//settle captures or releases the held load named in the request with settle, reporting it with status
+func (s *Server) settle(status string, settle func(customerID, loadID string) error) func(http.ResponseWriter, string) {
+	return func(w http.ResponseWriter, req string) {
+		var body holdRequest
+		if err := json.Unmarshal([]byte(req), &body); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		if body.ID == "" || body.CustomerID == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "id and customer_id are required"})
+			return
+		}
+		err := settle(body.CustomerID, body.ID)
+		var notFound account.NotFoundError
+		switch {
+		case errors.As(err, &notFound):
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, holdResponse{ID: body.ID, CustomerID: body.CustomerID, Status: status})
+		}
+	}
+}
+
+func newLoadResponse(detail account.FundDetail, correctionDetails []account.FundDetail) loadResponse {
+	var corrections []account.FundResponse
+	for _, correction := range correctionDetails {
+		corrections = append(corrections, correction.FundResponse)
+	}
+	return loadResponse{
+		FundResponse:  detail.FundResponse,
+		PolicyVersion: detail.PolicyVersion,
+		Outcome:       detail.Outcome,
+		Reason:        detail.Reason,
+		Flags:         detail.Flags,
+		Corrections:   corrections,
+	}
+}
+
+func (s *Server) evaluate(w http.ResponseWriter, req string) {
+	evaluation, err := s.handler.Evaluate(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, evaluation)
+}
+
+//usage reports a customer's consumption of each limit, as of the time in the "at" query parameter or now
+func (s *Server) usage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+	customerID := r.URL.Query().Get("customer_id")
+	if customerID == "" {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "customer_id is required"})
+		return
+	}
+	at := s.handler.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, s.handler.Usage(customerID, at))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	validator "gopkg.in/go-playground/validator.v9"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ServerTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerTestSuite))
+}
+
+func (s *ServerTestSuite) SetupTest() {
+	c := cache.New(cache.NoExpiration, 10*time.Minute)
+	handler := account.NewHandler(account.CustomerAccount{}, validator.New(), c)
+	s.server = httptest.NewServer(New(&handler))
+}
+
+func (s *ServerTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ServerTestSuite) post(path, body string, out interface{}) int {
+	resp, err := http.Post(s.server.URL+path, "application/json", strings.NewReader(body))
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+	return resp.StatusCode
+}
+
+func (s *ServerTestSuite) TestLoad() {
+	var resp account.FundResponse
+	status := s.post("/loads", `{"id":"1","customer_id":"18","load_amount":"$4000.00","time":"2000-02-04T12:27:00Z"}`, &resp)
+	s.Equal(http.StatusOK, status)
+	s.Equal(account.FundResponse{ID: "1", CustomerID: "18", Accepted: true}, resp)
+
+	status = s.post("/loads", `{"id":"2","customer_id":"18","load_amount":"$1000.01","time":"2000-02-04T13:27:00Z"}`, &resp)
+	s.Equal(http.StatusOK, status)
+	s.Equal(account.FundResponse{ID: "2", CustomerID: "18", Accepted: false}, resp)
+
+	var declined loadResponse
+	s.post("/loads", `{"id":"3","customer_id":"18","load_amount":"$1000.01","time":"2000-02-04T13:28:00Z"}`, &declined)
+	s.Equal(account.ReasonWindowLimit, declined.Reason)
+
+	var errResp errorResponse
+	status = s.post("/loads", `{"id":"1","customer_id":"18","load_amount":"$1.00","time":"2000-02-04T14:27:00Z"}`, &errResp)
+	s.Equal(http.StatusConflict, status)
+	s.Equal("loadID: 1 exists", errResp.Error)
+
+	status = s.post("/loads", `{"id":"4","customer_id":"18","load_amount":"$$1.00","time":"2000-02-04T14:27:00Z"}`, &errResp)
+	s.Equal(http.StatusBadRequest, status)
+}
+
+func (s *ServerTestSuite) TestEvaluateDoesNotLoad() {
+	var evaluation account.Evaluation
+	req := `{"id":"1","customer_id":"18","load_amount":"$4000.00","time":"2000-02-04T12:27:00Z"}`
+	status := s.post("/evaluate", req, &evaluation)
+	s.Equal(http.StatusOK, status)
+	s.True(evaluation.Accepted)
+	s.Len(evaluation.Limits, 2)
+
+	var resp account.FundResponse
+	status = s.post("/loads", req, &resp)
+	s.Equal(http.StatusOK, status)
+	s.True(resp.Accepted)
+}
+
+func (s *ServerTestSuite) TestUsage() {
+	var resp account.FundResponse
+	s.post("/loads", `{"id":"1","customer_id":"18","load_amount":"$4000.00","time":"2000-02-04T12:27:00Z"}`, &resp)
+
+	httpResp, err := http.Get(s.server.URL + "/usage?customer_id=18&at=2000-02-05T00:00:00Z")
+	s.Require().NoError(err)
+	defer httpResp.Body.Close()
+	var usage account.Usage
+	s.Require().NoError(json.NewDecoder(httpResp.Body).Decode(&usage))
+	s.Equal(http.StatusOK, httpResp.StatusCode)
+	s.Require().Len(usage.Limits, 2)
+	s.Equal(0, usage.Limits[0].UsedLoads)
+	s.Equal(4000.00, usage.Limits[1].UsedAmount)
+	s.Equal(time.Date(2000, 2, 7, 0, 0, 0, 0, time.UTC), usage.Limits[1].WindowEnd)
+
+	httpResp, err = http.Get(s.server.URL + "/usage?customer_id=18&at=yesterday")
+	s.Require().NoError(err)
+	httpResp.Body.Close()
+	s.Equal(http.StatusBadRequest, httpResp.StatusCode)
+}
+
+func (s *ServerTestSuite) TestReservations() {
+	var reserved reservationResponse
+	status := s.post("/reservations", `{"id":"1","customer_id":"18","load_amount":"$4000.00","time":"2000-02-04T12:27:00Z","ttl":"10m"}`, &reserved)
+	s.Equal(http.StatusOK, status)
+	s.True(reserved.Accepted)
+	s.Require().NotNil(reserved.HoldExpiresAt)
+
+	var resp loadResponse
+	s.post("/loads", `{"id":"2","customer_id":"18","load_amount":"$2000.00","time":"2000-02-04T13:27:00Z"}`, &resp)
+	s.Equal(account.ReasonWindowLimit, resp.Reason, "the reservation holds the capacity")
+
+	var settled holdResponse
+	status = s.post("/reservations/release", `{"id":"1","customer_id":"18"}`, &settled)
+	s.Equal(http.StatusOK, status)
+	s.Equal(holdResponse{ID: "1", CustomerID: "18", Status: "released"}, settled)
+	s.post("/loads", `{"id":"3","customer_id":"18","load_amount":"$2000.00","time":"2000-02-04T13:28:00Z"}`, &resp)
+	s.True(resp.Accepted)
+
+	s.post("/reservations", `{"id":"4","customer_id":"19","load_amount":"$100.00","time":"2000-02-04T12:27:00Z"}`, &reserved)
+	status = s.post("/reservations/capture", `{"id":"4","customer_id":"19"}`, &settled)
+	s.Equal(http.StatusOK, status)
+	s.Equal("captured", settled.Status)
+
+	var errResp errorResponse
+	status = s.post("/reservations/capture", `{"id":"1","customer_id":"18"}`, &errResp)
+	s.Equal(http.StatusNotFound, status)
+	s.Equal("hold on load 1 does not exist", errResp.Error)
+	status = s.post("/reservations/capture", `{"id":"1"}`, &errResp)
+	s.Equal(http.StatusBadRequest, status)
+	status = s.post("/reservations", `{"id":"5","customer_id":"18","load_amount":"$1.00","time":"2000-02-04T14:27:00Z","ttl":"soon"}`, &errResp)
+	s.Equal(http.StatusBadRequest, status)
+}
+
+func (s *ServerTestSuite) TestMethodNotAllowed() {
+	resp, err := http.Get(s.server.URL + "/loads")
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+}
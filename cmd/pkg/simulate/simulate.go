@@ -0,0 +1,208 @@
+package simulate
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+//Decision is a load whose outcome differs between the current and candidate policies
+type Decision struct {
+	ID         string  `json:"id"`
+	CustomerID string  `json:"customer_id"`
+	Amount     float64 `json:"amount"`
+	Current    bool    `json:"current_accepted"`
+	Candidate  bool    `json:"candidate_accepted"`
+}
+
+//Outcome summarizes the decisions made under one policy
+type Outcome struct {
+	Policy         string  `json:"policy"`
+	Accepted       int     `json:"accepted"`
+	Declined       int     `json:"declined"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+	AcceptedAmount float64 `json:"accepted_amount"`
+}
+
+//CustomerImpact is how many of a customer's loads, and how much volume, changed decision
+type CustomerImpact struct {
+	CustomerID     string  `json:"customer_id"`
+	ChangedLoads   int     `json:"changed_loads"`
+	AmountAffected float64 `json:"amount_affected"`
+}
+
+//Report compares the decisions made for the same loads under two policies
+type Report struct {
+	Loads               int              `json:"loads"`
+	Current             Outcome          `json:"current"`
+	Candidate           Outcome          `json:"candidate"`
+	AcceptanceRateDelta float64          `json:"acceptance_rate_delta"`
+	NewlyAccepted       int              `json:"newly_accepted"`
+	NewlyDeclined       int              `json:"newly_declined"`
+	AmountAffected      float64          `json:"amount_affected"`
+	Differences         []Decision       `json:"differences"`
+	MostAffected        []CustomerImpact `json:"most_affected"`
+}
+
+type result struct {
+	fund     account.Fund
+	response account.FundResponse
+}
+
+//Run replays the fund requests under both policies, each starting from empty account state,
+//and reports where they disagree. Only the top customers by changed loads are kept in MostAffected.
+func Run(requests []account.FundRequest, current, candidate *account.Policy, top int) Report {
+	currentResults := replay(requests, current)
+	candidateResults := replay(requests, candidate)
+	report := Report{
+		Current:     summarize(current, currentResults),
+		Candidate:   summarize(candidate, candidateResults),
+		Differences: []Decision{},
+	}
+	impacts := make(map[string]*CustomerImpact)
+	for i, before := range currentResults {
+		after := candidateResults[i]
+		//requests that were ignored as invalid or duplicate are not decisions
+		if (before.response == account.FundResponse{}) || (after.response == account.FundResponse{}) {
+			continue
+		}
+		report.Loads++
+		if before.response.Accepted == after.response.Accepted {
+			continue
+		}
+		fund := before.fund
+		report.Differences = append(report.Differences, Decision{
+			ID:         fund.ID,
+			CustomerID: fund.CustomerID,
+			Amount:     fund.LoadAmount,
+			Current:    before.response.Accepted,
+			Candidate:  after.response.Accepted,
+		})
+		if after.response.Accepted {
+			report.NewlyAccepted++
+		} else {
+			report.NewlyDeclined++
+		}
+		report.AmountAffected += fund.LoadAmount
+		impact, found := impacts[fund.CustomerID]
+		if !found {
+			impact = &CustomerImpact{CustomerID: fund.CustomerID}
+			impacts[fund.CustomerID] = impact
+		}
+		impact.ChangedLoads++
+		impact.AmountAffected += fund.LoadAmount
+	}
+	report.AmountAffected = roundCents(report.AmountAffected)
+	report.AcceptanceRateDelta = roundRate(report.Candidate.AcceptanceRate - report.Current.AcceptanceRate)
+	report.MostAffected = mostAffected(impacts, top)
+	return report
+}
+
+//WriteTable writes the report as human readable tables
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "POLICY\tVERSION\tACCEPTED\tDECLINED\tACCEPTANCE RATE\tACCEPTED AMOUNT\n")
+	for _, row := range []struct {
+		name    string
+		outcome Outcome
+	}{{"current", r.Current}, {"candidate", r.Candidate}} {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.2f%%\t%.2f\n", row.name, row.outcome.Policy, row.outcome.Accepted,
+			row.outcome.Declined, row.outcome.AcceptanceRate*100, row.outcome.AcceptedAmount)
+	}
+	fmt.Fprintf(tw, "\nloads\t%d\n", r.Loads)
+	fmt.Fprintf(tw, "acceptance rate delta\t%+.2f%%\n", r.AcceptanceRateDelta*100)
+	fmt.Fprintf(tw, "newly accepted\t%d\n", r.NewlyAccepted)
+	fmt.Fprintf(tw, "newly declined\t%d\n", r.NewlyDeclined)
+	fmt.Fprintf(tw, "amount affected\t%.2f\n", r.AmountAffected)
+	if len(r.Differences) > 0 {
+		fmt.Fprintf(tw, "\nLOAD ID\tCUSTOMER ID\tAMOUNT\tCURRENT\tCANDIDATE\n")
+		for _, d := range r.Differences {
+			fmt.Fprintf(tw, "%s\t%s\t%.2f\t%s\t%s\n", d.ID, d.CustomerID, d.Amount, decision(d.Current), decision(d.Candidate))
+		}
+	}
+	if len(r.MostAffected) > 0 {
+		fmt.Fprintf(tw, "\nCUSTOMER ID\tCHANGED LOADS\tAMOUNT AFFECTED\n")
+		for _, c := range r.MostAffected {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f\n", c.CustomerID, c.ChangedLoads, c.AmountAffected)
+		}
+	}
+	return tw.Flush()
+}
+
+func replay(requests []account.FundRequest, policy *account.Policy) []result {
+	c := cache.New(cache.NoExpiration, 0)
+	handler := account.NewHandler(account.CustomerAccount{Policy: policy}, validator.New(), c)
+	results := make([]result, len(requests))
+	for i, request := range requests {
+		fund, err := handler.ParseRequest(request)
+		if err != nil {
+			continue
+		}
+		results[i] = result{fund: fund, response: handler.Load(fund)}
+	}
+	return results
+}
+
+func summarize(policy *account.Policy, results []result) Outcome {
+	outcome := Outcome{Policy: policy.Version}
+	for _, r := range results {
+		if (r.response == account.FundResponse{}) {
+			continue
+		}
+		if r.response.Accepted {
+			outcome.Accepted++
+			outcome.AcceptedAmount += r.fund.LoadAmount
+		} else {
+			outcome.Declined++
+		}
+	}
+	if decided := outcome.Accepted + outcome.Declined; decided > 0 {
+		outcome.AcceptanceRate = roundRate(float64(outcome.Accepted) / float64(decided))
+	}
+	outcome.AcceptedAmount = roundCents(outcome.AcceptedAmount)
+	return outcome
+}
+
+func mostAffected(impacts map[string]*CustomerImpact, top int) []CustomerImpact {
+	customers := []CustomerImpact{}
+	for _, impact := range impacts {
+		impact.AmountAffected = roundCents(impact.AmountAffected)
+		customers = append(customers, *impact)
+	}
+	sort.Slice(customers, func(i, j int) bool {
+		if customers[i].ChangedLoads != customers[j].ChangedLoads {
+			return customers[i].ChangedLoads > customers[j].ChangedLoads
+		}
+		if customers[i].AmountAffected != customers[j].AmountAffected {
+			return customers[i].AmountAffected > customers[j].AmountAffected
+		}
+		return customers[i].CustomerID < customers[j].CustomerID
+	})
+	if top >= 0 && len(customers) > top {
+		customers = customers[:top]
+	}
+	return customers
+}
+
+func decision(accepted bool) string {
+	if accepted {
+		return "accepted"
+	}
+	return "declined"
+}
+
+//roundRate keeps a rate, a fraction of the loads, to a hundredth of a percent
+func roundRate(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
+
+//roundCents keeps an amount to the cent
+func roundCents(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,91 @@
+package simulate
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/stretchr/testify/suite"
+)
+
+type SimulateTestSuite struct {
+	suite.Suite
+}
+
+func TestSimulate(t *testing.T) {
+	suite.Run(t, new(SimulateTestSuite))
+}
+
+var inputs = []account.FundRequest{
+	{ID: "1", CustomerID: "18", LoadAmount: "$3000.00", Time: "2000-02-04T10:00:00Z"},
+	{ID: "2", CustomerID: "18", LoadAmount: "$1500.00", Time: "2000-02-04T11:00:00Z"},
+	{ID: "3", CustomerID: "19", LoadAmount: "$4500.00", Time: "2000-02-04T12:00:00Z"},
+	{ID: "4", CustomerID: "19", LoadAmount: "$100.00", Time: "2000-02-05T12:00:00Z"},
+	{ID: "4", CustomerID: "19", LoadAmount: "$100.00", Time: "2000-02-05T12:00:00Z"},
+	{ID: "5", LoadAmount: "$100.00", Time: "2000-02-05T13:00:00Z"},
+}
+
+func (s *SimulateTestSuite) candidate() *account.Policy {
+	return &account.Policy{
+		Version: "candidate",
+		Limits: []account.Limit{
+			{Name: "daily", Period: account.Period{Unit: account.Day}, MaxAmount: 4000.00},
+		},
+	}
+}
+
+func (s *SimulateTestSuite) TestRun() {
+	report := Run(inputs, account.DefaultPolicy(), s.candidate(), 10)
+	expected := Report{
+		Loads:               4,
+		Current:             Outcome{Policy: "default", Accepted: 4, AcceptanceRate: 1, AcceptedAmount: 9100.00},
+		Candidate:           Outcome{Policy: "candidate", Accepted: 2, Declined: 2, AcceptanceRate: 0.5, AcceptedAmount: 3100.00},
+		AcceptanceRateDelta: -0.5,
+		NewlyDeclined:       2,
+		AmountAffected:      6000.00,
+		Differences: []Decision{
+			{ID: "2", CustomerID: "18", Amount: 1500.00, Current: true, Candidate: false},
+			{ID: "3", CustomerID: "19", Amount: 4500.00, Current: true, Candidate: false},
+		},
+		MostAffected: []CustomerImpact{
+			{CustomerID: "19", ChangedLoads: 1, AmountAffected: 4500.00},
+			{CustomerID: "18", ChangedLoads: 1, AmountAffected: 1500.00},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		s.T().Errorf("report: %+v, expected report: %+v", report, expected)
+	}
+}
+
+func (s *SimulateTestSuite) TestRunLimitsMostAffected() {
+	report := Run(inputs, account.DefaultPolicy(), s.candidate(), 1)
+	if len(report.MostAffected) != 1 || report.MostAffected[0].CustomerID != "19" {
+		s.T().Errorf("most affected: %+v, expected only customer 19", report.MostAffected)
+	}
+}
+
+func (s *SimulateTestSuite) TestSamePolicyHasNoDifferences() {
+	report := Run(inputs, account.DefaultPolicy(), account.DefaultPolicy(), 10)
+	if len(report.Differences) != 0 || report.AcceptanceRateDelta != 0 || len(report.MostAffected) != 0 {
+		s.T().Errorf("report: %+v, expected no differences", report)
+	}
+}
+
+func (s *SimulateTestSuite) TestWriteTable() {
+	var buf bytes.Buffer
+	report := Run(inputs, account.DefaultPolicy(), s.candidate(), 10)
+	s.Require().NoError(report.WriteTable(&buf))
+	table := buf.String()
+	for _, expected := range []string{"candidate  candidate", "acceptance rate delta  -50.00%", "3        19           4500.00  accepted  declined"} {
+		if !strings.Contains(table, expected) {
+			s.T().Errorf("table does not contain %q:\n%s", expected, table)
+		}
+	}
+}
+
+func (s *SimulateTestSuite) TestRound() {
+	s.Equal(0.1235, roundRate(0.123456))
+	s.Equal(12.35, roundCents(12.3456))
+}
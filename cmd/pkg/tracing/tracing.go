@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	//None leaves tracing off, which is the default
+	None = ""
+	//Stdout prints every span as json with the stdout exporter. It writes to standard error, as standard output
+	//may be carrying the decisions.
+	Stdout = "stdout"
+	//OTLP sends spans to a collector over gRPC, configured by the standard OTEL_EXPORTER_OTLP_* environment variables
+	OTLP = "otlp"
+)
+
+//Setup installs a global tracer provider that sends spans to the named exporter, tagged with the service name.
+//The returned function flushes any spans not yet exported and must be called before exiting.
+func Setup(exporter, service string) (func() error, error) {
+	return setup(exporter, service, os.Stderr)
+}
+
+func setup(exporter, service string, out io.Writer) (func() error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case None:
+		return func() error { return nil }, nil
+	case Stdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	case OTLP:
+		spanExporter, err = otlptracegrpc.New(context.Background())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected stdout or otlp", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		//block rather than drop spans when a batch run decides loads faster than they are exported
+		sdktrace.WithBatcher(spanExporter, sdktrace.WithBlocking()),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	otel.SetTracerProvider(provider)
+	return func() error {
+		return provider.Shutdown(context.Background())
+	}, nil
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+type TracingTestSuite struct {
+	suite.Suite
+	previous trace.TracerProvider
+}
+
+func TestTracing(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func (s *TracingTestSuite) SetupTest() {
+	s.previous = otel.GetTracerProvider()
+}
+
+func (s *TracingTestSuite) TearDownTest() {
+	otel.SetTracerProvider(s.previous)
+}
+
+func (s *TracingTestSuite) TestStdout() {
+	var buf bytes.Buffer
+	shutdown, err := setup(Stdout, "velocity-limits", &buf)
+	s.Require().NoError(err)
+	handler := account.NewHandler(account.CustomerAccount{}, validator.New(), cache.New(cache.NoExpiration, 0))
+	handler.Run(`{"id":"1","customer_id":"18","load_amount":"$1.00","time":"2000-02-04T10:00:00Z"}`)
+	s.Require().NoError(shutdown())
+	decoder := json.NewDecoder(strings.NewReader(buf.String()))
+	var names []string
+	for decoder.More() {
+		var span struct{ Name string }
+		s.Require().NoError(decoder.Decode(&span))
+		names = append(names, span.Name)
+	}
+	s.Contains(names, "FundHandler.Run")
+	s.Contains(names, "check.limit")
+}
+
+func (s *TracingTestSuite) TestNone() {
+	provider := otel.GetTracerProvider()
+	shutdown, err := Setup(None, "velocity-limits")
+	s.Require().NoError(err)
+	s.NoError(shutdown())
+	s.Equal(provider, otel.GetTracerProvider())
+}
+
+func (s *TracingTestSuite) TestUnknownExporter() {
+	_, err := Setup("zipkin", "velocity-limits")
+	s.EqualError(err, `unknown trace exporter "zipkin", expected stdout or otlp`)
+}
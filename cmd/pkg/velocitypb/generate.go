@@ -0,0 +1,4 @@
+//Package velocitypb is the code generated from proto/velocity/v1/velocity.proto, regenerate it with go generate
+package velocitypb
+
+//go:generate protoc -I ../../../proto --go_out=../../.. --go_opt=module=github.com/rnidev/velocity-limits --go-grpc_out=../../.. --go-grpc_opt=module=github.com/rnidev/velocity-limits velocity/v1/velocity.proto
@@ -0,0 +1,887 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: velocity/v1/velocity.proto
+
+package velocitypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// FundRequest is a load as received, before it is validated.
+type FundRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CustomerId string                 `protobuf:"bytes,2,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	// load_amount is in dollars, like "$123.45".
+	LoadAmount string `protobuf:"bytes,3,opt,name=load_amount,json=loadAmount,proto3" json:"load_amount,omitempty"`
+	// time is an RFC 3339 timestamp.
+	Time string `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	// funding_source_id, device_id and ip optionally say what the load was made with, for limits keyed on them.
+	FundingSourceId string `protobuf:"bytes,5,opt,name=funding_source_id,json=fundingSourceId,proto3" json:"funding_source_id,omitempty"`
+	DeviceId        string `protobuf:"bytes,6,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Ip              string `protobuf:"bytes,7,opt,name=ip,proto3" json:"ip,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *FundRequest) Reset() {
+	*x = FundRequest{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FundRequest) ProtoMessage() {}
+
+func (x *FundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FundRequest.ProtoReflect.Descriptor instead.
+func (*FundRequest) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FundRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *FundRequest) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *FundRequest) GetLoadAmount() string {
+	if x != nil {
+		return x.LoadAmount
+	}
+	return ""
+}
+
+func (x *FundRequest) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *FundRequest) GetFundingSourceId() string {
+	if x != nil {
+		return x.FundingSourceId
+	}
+	return ""
+}
+
+func (x *FundRequest) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *FundRequest) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+// FundResponse is the decision for a load.
+type FundResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CustomerId string                 `protobuf:"bytes,2,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	Accepted   bool                   `protobuf:"varint,3,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	// correction marks a response that replaces the one previously given for the load.
+	Correction bool `protobuf:"varint,4,opt,name=correction,proto3" json:"correction,omitempty"`
+	// reason is the code for why the load was declined, like "window_limit", unset when it was accepted.
+	Reason string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	// outcome is "accepted", "accepted_flagged" or "declined", and flags are the soft limits an accepted load exceeded.
+	Outcome       string  `protobuf:"bytes,6,opt,name=outcome,proto3" json:"outcome,omitempty"`
+	Flags         []*Flag `protobuf:"bytes,7,rep,name=flags,proto3" json:"flags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FundResponse) Reset() {
+	*x = FundResponse{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FundResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FundResponse) ProtoMessage() {}
+
+func (x *FundResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FundResponse.ProtoReflect.Descriptor instead.
+func (*FundResponse) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FundResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *FundResponse) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *FundResponse) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *FundResponse) GetCorrection() bool {
+	if x != nil {
+		return x.Correction
+	}
+	return false
+}
+
+func (x *FundResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *FundResponse) GetOutcome() string {
+	if x != nil {
+		return x.Outcome
+	}
+	return ""
+}
+
+func (x *FundResponse) GetFlags() []*Flag {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+// Flag is a soft limit a load exceeded, which does not stop it being accepted but marks it for review.
+type Flag struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Limit         string                 `protobuf:"bytes,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Flag) Reset() {
+	*x = Flag{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Flag) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Flag) ProtoMessage() {}
+
+func (x *Flag) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Flag.ProtoReflect.Descriptor instead.
+func (*Flag) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Flag) GetLimit() string {
+	if x != nil {
+		return x.Limit
+	}
+	return ""
+}
+
+func (x *Flag) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Flag) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// LoadFundResponse is the decision for a load, and any earlier decisions that changed because of it.
+type LoadFundResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Response    *FundResponse          `protobuf:"bytes,1,opt,name=response,proto3" json:"response,omitempty"`
+	Corrections []*FundResponse        `protobuf:"bytes,2,rep,name=corrections,proto3" json:"corrections,omitempty"`
+	// ignored is set in a batch when the request was invalid or a duplicate, and error says why.
+	Ignored       bool   `protobuf:"varint,3,opt,name=ignored,proto3" json:"ignored,omitempty"`
+	Error         string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoadFundResponse) Reset() {
+	*x = LoadFundResponse{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoadFundResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoadFundResponse) ProtoMessage() {}
+
+func (x *LoadFundResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoadFundResponse.ProtoReflect.Descriptor instead.
+func (*LoadFundResponse) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LoadFundResponse) GetResponse() *FundResponse {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *LoadFundResponse) GetCorrections() []*FundResponse {
+	if x != nil {
+		return x.Corrections
+	}
+	return nil
+}
+
+func (x *LoadFundResponse) GetIgnored() bool {
+	if x != nil {
+		return x.Ignored
+	}
+	return false
+}
+
+func (x *LoadFundResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+// LimitUsage is how much of a limit a customer has consumed in the window containing a point in time.
+// Remaining values are unset for whatever the limit does not cap.
+type LimitUsage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Limit           string                 `protobuf:"bytes,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	WindowStart     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=window_start,json=windowStart,proto3" json:"window_start,omitempty"`
+	WindowEnd       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=window_end,json=windowEnd,proto3" json:"window_end,omitempty"`
+	UsedAmount      float64                `protobuf:"fixed64,4,opt,name=used_amount,json=usedAmount,proto3" json:"used_amount,omitempty"`
+	UsedLoads       int32                  `protobuf:"varint,5,opt,name=used_loads,json=usedLoads,proto3" json:"used_loads,omitempty"`
+	RemainingAmount *float64               `protobuf:"fixed64,6,opt,name=remaining_amount,json=remainingAmount,proto3,oneof" json:"remaining_amount,omitempty"`
+	RemainingLoads  *int32                 `protobuf:"varint,7,opt,name=remaining_loads,json=remainingLoads,proto3,oneof" json:"remaining_loads,omitempty"`
+	// held_amount and held_loads are the part of the used amount and loads held by reservations not yet captured.
+	HeldAmount    float64 `protobuf:"fixed64,8,opt,name=held_amount,json=heldAmount,proto3" json:"held_amount,omitempty"`
+	HeldLoads     int32   `protobuf:"varint,9,opt,name=held_loads,json=heldLoads,proto3" json:"held_loads,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LimitUsage) Reset() {
+	*x = LimitUsage{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LimitUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LimitUsage) ProtoMessage() {}
+
+func (x *LimitUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LimitUsage.ProtoReflect.Descriptor instead.
+func (*LimitUsage) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LimitUsage) GetLimit() string {
+	if x != nil {
+		return x.Limit
+	}
+	return ""
+}
+
+func (x *LimitUsage) GetWindowStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowStart
+	}
+	return nil
+}
+
+func (x *LimitUsage) GetWindowEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowEnd
+	}
+	return nil
+}
+
+func (x *LimitUsage) GetUsedAmount() float64 {
+	if x != nil {
+		return x.UsedAmount
+	}
+	return 0
+}
+
+func (x *LimitUsage) GetUsedLoads() int32 {
+	if x != nil {
+		return x.UsedLoads
+	}
+	return 0
+}
+
+func (x *LimitUsage) GetRemainingAmount() float64 {
+	if x != nil && x.RemainingAmount != nil {
+		return *x.RemainingAmount
+	}
+	return 0
+}
+
+func (x *LimitUsage) GetRemainingLoads() int32 {
+	if x != nil && x.RemainingLoads != nil {
+		return *x.RemainingLoads
+	}
+	return 0
+}
+
+func (x *LimitUsage) GetHeldAmount() float64 {
+	if x != nil {
+		return x.HeldAmount
+	}
+	return 0
+}
+
+func (x *LimitUsage) GetHeldLoads() int32 {
+	if x != nil {
+		return x.HeldLoads
+	}
+	return 0
+}
+
+// Headroom is the usage of a limit before an evaluated load, and whether the load fits in it.
+type Headroom struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Usage         *LimitUsage            `protobuf:"bytes,1,opt,name=usage,proto3" json:"usage,omitempty"`
+	Passed        bool                   `protobuf:"varint,2,opt,name=passed,proto3" json:"passed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Headroom) Reset() {
+	*x = Headroom{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Headroom) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Headroom) ProtoMessage() {}
+
+func (x *Headroom) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Headroom.ProtoReflect.Descriptor instead.
+func (*Headroom) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Headroom) GetUsage() *LimitUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+func (x *Headroom) GetPassed() bool {
+	if x != nil {
+		return x.Passed
+	}
+	return false
+}
+
+// Evaluation is the outcome of a dry run of a load.
+type Evaluation struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CustomerId string                 `protobuf:"bytes,2,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	Accepted   bool                   `protobuf:"varint,3,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Duplicate  bool                   `protobuf:"varint,4,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	// reason says why the load would be declined, and reason_code is its code, like "window_limit".
+	Reason     string      `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	Limits     []*Headroom `protobuf:"bytes,6,rep,name=limits,proto3" json:"limits,omitempty"`
+	ReasonCode string      `protobuf:"bytes,7,opt,name=reason_code,json=reasonCode,proto3" json:"reason_code,omitempty"`
+	// flags are the soft limits the load would exceed.
+	Flags         []*Flag `protobuf:"bytes,8,rep,name=flags,proto3" json:"flags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Evaluation) Reset() {
+	*x = Evaluation{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Evaluation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Evaluation) ProtoMessage() {}
+
+func (x *Evaluation) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Evaluation.ProtoReflect.Descriptor instead.
+func (*Evaluation) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Evaluation) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Evaluation) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *Evaluation) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *Evaluation) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+func (x *Evaluation) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Evaluation) GetLimits() []*Headroom {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+func (x *Evaluation) GetReasonCode() string {
+	if x != nil {
+		return x.ReasonCode
+	}
+	return ""
+}
+
+func (x *Evaluation) GetFlags() []*Flag {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+// UsageRequest asks for a customer's usage as of a point in time, the server's current time when unset.
+type UsageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CustomerId    string                 `protobuf:"bytes,1,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=at,proto3" json:"at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UsageRequest) Reset() {
+	*x = UsageRequest{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UsageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UsageRequest) ProtoMessage() {}
+
+func (x *UsageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UsageRequest.ProtoReflect.Descriptor instead.
+func (*UsageRequest) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UsageRequest) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *UsageRequest) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+// Usage is a customer's consumption of every limit at a point in time.
+type Usage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	CustomerId    string                 `protobuf:"bytes,1,opt,name=customer_id,json=customerId,proto3" json:"customer_id,omitempty"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=at,proto3" json:"at,omitempty"`
+	Limits        []*LimitUsage          `protobuf:"bytes,3,rep,name=limits,proto3" json:"limits,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Usage) Reset() {
+	*x = Usage{}
+	mi := &file_velocity_v1_velocity_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Usage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Usage) ProtoMessage() {}
+
+func (x *Usage) ProtoReflect() protoreflect.Message {
+	mi := &file_velocity_v1_velocity_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Usage.ProtoReflect.Descriptor instead.
+func (*Usage) Descriptor() ([]byte, []int) {
+	return file_velocity_v1_velocity_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Usage) GetCustomerId() string {
+	if x != nil {
+		return x.CustomerId
+	}
+	return ""
+}
+
+func (x *Usage) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *Usage) GetLimits() []*LimitUsage {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+var File_velocity_v1_velocity_proto protoreflect.FileDescriptor
+
+const file_velocity_v1_velocity_proto_rawDesc = "" +
+	"\n" +
+	"\x1avelocity/v1/velocity.proto\x12\vvelocity.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xcc\x01\n" +
+	"\vFundRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1f\n" +
+	"\vcustomer_id\x18\x02 \x01(\tR\n" +
+	"customerId\x12\x1f\n" +
+	"\vload_amount\x18\x03 \x01(\tR\n" +
+	"loadAmount\x12\x12\n" +
+	"\x04time\x18\x04 \x01(\tR\x04time\x12*\n" +
+	"\x11funding_source_id\x18\x05 \x01(\tR\x0ffundingSourceId\x12\x1b\n" +
+	"\tdevice_id\x18\x06 \x01(\tR\bdeviceId\x12\x0e\n" +
+	"\x02ip\x18\a \x01(\tR\x02ip\"\xd6\x01\n" +
+	"\fFundResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1f\n" +
+	"\vcustomer_id\x18\x02 \x01(\tR\n" +
+	"customerId\x12\x1a\n" +
+	"\baccepted\x18\x03 \x01(\bR\baccepted\x12\x1e\n" +
+	"\n" +
+	"correction\x18\x04 \x01(\bR\n" +
+	"correction\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x12\x18\n" +
+	"\aoutcome\x18\x06 \x01(\tR\aoutcome\x12'\n" +
+	"\x05flags\x18\a \x03(\v2\x11.velocity.v1.FlagR\x05flags\"N\n" +
+	"\x04Flag\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\tR\x05limit\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\"\xb6\x01\n" +
+	"\x10LoadFundResponse\x125\n" +
+	"\bresponse\x18\x01 \x01(\v2\x19.velocity.v1.FundResponseR\bresponse\x12;\n" +
+	"\vcorrections\x18\x02 \x03(\v2\x19.velocity.v1.FundResponseR\vcorrections\x12\x18\n" +
+	"\aignored\x18\x03 \x01(\bR\aignored\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\xa3\x03\n" +
+	"\n" +
+	"LimitUsage\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\tR\x05limit\x12=\n" +
+	"\fwindow_start\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\vwindowStart\x129\n" +
+	"\n" +
+	"window_end\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\twindowEnd\x12\x1f\n" +
+	"\vused_amount\x18\x04 \x01(\x01R\n" +
+	"usedAmount\x12\x1d\n" +
+	"\n" +
+	"used_loads\x18\x05 \x01(\x05R\tusedLoads\x12.\n" +
+	"\x10remaining_amount\x18\x06 \x01(\x01H\x00R\x0fremainingAmount\x88\x01\x01\x12,\n" +
+	"\x0fremaining_loads\x18\a \x01(\x05H\x01R\x0eremainingLoads\x88\x01\x01\x12\x1f\n" +
+	"\vheld_amount\x18\b \x01(\x01R\n" +
+	"heldAmount\x12\x1d\n" +
+	"\n" +
+	"held_loads\x18\t \x01(\x05R\theldLoadsB\x13\n" +
+	"\x11_remaining_amountB\x12\n" +
+	"\x10_remaining_loads\"Q\n" +
+	"\bHeadroom\x12-\n" +
+	"\x05usage\x18\x01 \x01(\v2\x17.velocity.v1.LimitUsageR\x05usage\x12\x16\n" +
+	"\x06passed\x18\x02 \x01(\bR\x06passed\"\x88\x02\n" +
+	"\n" +
+	"Evaluation\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1f\n" +
+	"\vcustomer_id\x18\x02 \x01(\tR\n" +
+	"customerId\x12\x1a\n" +
+	"\baccepted\x18\x03 \x01(\bR\baccepted\x12\x1c\n" +
+	"\tduplicate\x18\x04 \x01(\bR\tduplicate\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\x12-\n" +
+	"\x06limits\x18\x06 \x03(\v2\x15.velocity.v1.HeadroomR\x06limits\x12\x1f\n" +
+	"\vreason_code\x18\a \x01(\tR\n" +
+	"reasonCode\x12'\n" +
+	"\x05flags\x18\b \x03(\v2\x11.velocity.v1.FlagR\x05flags\"[\n" +
+	"\fUsageRequest\x12\x1f\n" +
+	"\vcustomer_id\x18\x01 \x01(\tR\n" +
+	"customerId\x12*\n" +
+	"\x02at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\"\x85\x01\n" +
+	"\x05Usage\x12\x1f\n" +
+	"\vcustomer_id\x18\x01 \x01(\tR\n" +
+	"customerId\x12*\n" +
+	"\x02at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12/\n" +
+	"\x06limits\x18\x03 \x03(\v2\x17.velocity.v1.LimitUsageR\x06limits2\x99\x02\n" +
+	"\x0eVelocityLimits\x12C\n" +
+	"\bLoadFund\x12\x18.velocity.v1.FundRequest\x1a\x1d.velocity.v1.LoadFundResponse\x12=\n" +
+	"\bEvaluate\x12\x18.velocity.v1.FundRequest\x1a\x17.velocity.v1.Evaluation\x129\n" +
+	"\bGetUsage\x12\x19.velocity.v1.UsageRequest\x1a\x12.velocity.v1.Usage\x12H\n" +
+	"\tBatchLoad\x12\x18.velocity.v1.FundRequest\x1a\x1d.velocity.v1.LoadFundResponse(\x010\x01BAZ?github.com/rnidev/velocity-limits/cmd/pkg/velocitypb;velocitypbb\x06proto3"
+
+var (
+	file_velocity_v1_velocity_proto_rawDescOnce sync.Once
+	file_velocity_v1_velocity_proto_rawDescData []byte
+)
+
+func file_velocity_v1_velocity_proto_rawDescGZIP() []byte {
+	file_velocity_v1_velocity_proto_rawDescOnce.Do(func() {
+		file_velocity_v1_velocity_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_velocity_v1_velocity_proto_rawDesc), len(file_velocity_v1_velocity_proto_rawDesc)))
+	})
+	return file_velocity_v1_velocity_proto_rawDescData
+}
+
+var file_velocity_v1_velocity_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_velocity_v1_velocity_proto_goTypes = []any{
+	(*FundRequest)(nil),           // 0: velocity.v1.FundRequest
+	(*FundResponse)(nil),          // 1: velocity.v1.FundResponse
+	(*Flag)(nil),                  // 2: velocity.v1.Flag
+	(*LoadFundResponse)(nil),      // 3: velocity.v1.LoadFundResponse
+	(*LimitUsage)(nil),            // 4: velocity.v1.LimitUsage
+	(*Headroom)(nil),              // 5: velocity.v1.Headroom
+	(*Evaluation)(nil),            // 6: velocity.v1.Evaluation
+	(*UsageRequest)(nil),          // 7: velocity.v1.UsageRequest
+	(*Usage)(nil),                 // 8: velocity.v1.Usage
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_velocity_v1_velocity_proto_depIdxs = []int32{
+	2,  // 0: velocity.v1.FundResponse.flags:type_name -> velocity.v1.Flag
+	1,  // 1: velocity.v1.LoadFundResponse.response:type_name -> velocity.v1.FundResponse
+	1,  // 2: velocity.v1.LoadFundResponse.corrections:type_name -> velocity.v1.FundResponse
+	9,  // 3: velocity.v1.LimitUsage.window_start:type_name -> google.protobuf.Timestamp
+	9,  // 4: velocity.v1.LimitUsage.window_end:type_name -> google.protobuf.Timestamp
+	4,  // 5: velocity.v1.Headroom.usage:type_name -> velocity.v1.LimitUsage
+	5,  // 6: velocity.v1.Evaluation.limits:type_name -> velocity.v1.Headroom
+	2,  // 7: velocity.v1.Evaluation.flags:type_name -> velocity.v1.Flag
+	9,  // 8: velocity.v1.UsageRequest.at:type_name -> google.protobuf.Timestamp
+	9,  // 9: velocity.v1.Usage.at:type_name -> google.protobuf.Timestamp
+	4,  // 10: velocity.v1.Usage.limits:type_name -> velocity.v1.LimitUsage
+	0,  // 11: velocity.v1.VelocityLimits.LoadFund:input_type -> velocity.v1.FundRequest
+	0,  // 12: velocity.v1.VelocityLimits.Evaluate:input_type -> velocity.v1.FundRequest
+	7,  // 13: velocity.v1.VelocityLimits.GetUsage:input_type -> velocity.v1.UsageRequest
+	0,  // 14: velocity.v1.VelocityLimits.BatchLoad:input_type -> velocity.v1.FundRequest
+	3,  // 15: velocity.v1.VelocityLimits.LoadFund:output_type -> velocity.v1.LoadFundResponse
+	6,  // 16: velocity.v1.VelocityLimits.Evaluate:output_type -> velocity.v1.Evaluation
+	8,  // 17: velocity.v1.VelocityLimits.GetUsage:output_type -> velocity.v1.Usage
+	3,  // 18: velocity.v1.VelocityLimits.BatchLoad:output_type -> velocity.v1.LoadFundResponse
+	15, // [15:19] is the sub-list for method output_type
+	11, // [11:15] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_velocity_v1_velocity_proto_init() }
+func file_velocity_v1_velocity_proto_init() {
+	if File_velocity_v1_velocity_proto != nil {
+		return
+	}
+	file_velocity_v1_velocity_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_velocity_v1_velocity_proto_rawDesc), len(file_velocity_v1_velocity_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_velocity_v1_velocity_proto_goTypes,
+		DependencyIndexes: file_velocity_v1_velocity_proto_depIdxs,
+		MessageInfos:      file_velocity_v1_velocity_proto_msgTypes,
+	}.Build()
+	File_velocity_v1_velocity_proto = out.File
+	file_velocity_v1_velocity_proto_goTypes = nil
+	file_velocity_v1_velocity_proto_depIdxs = nil
+}
@@ -0,0 +1,246 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: velocity/v1/velocity.proto
+
+package velocitypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	VelocityLimits_LoadFund_FullMethodName  = "/velocity.v1.VelocityLimits/LoadFund"
+	VelocityLimits_Evaluate_FullMethodName  = "/velocity.v1.VelocityLimits/Evaluate"
+	VelocityLimits_GetUsage_FullMethodName  = "/velocity.v1.VelocityLimits/GetUsage"
+	VelocityLimits_BatchLoad_FullMethodName = "/velocity.v1.VelocityLimits/BatchLoad"
+)
+
+// VelocityLimitsClient is the client API for VelocityLimits service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// VelocityLimits loads funds into customer accounts within their velocity limits.
+type VelocityLimitsClient interface {
+	// LoadFund decides a load. A request that is invalid fails with INVALID_ARGUMENT,
+	// and one whose id was already loaded for the customer fails with ALREADY_EXISTS.
+	LoadFund(ctx context.Context, in *FundRequest, opts ...grpc.CallOption) (*LoadFundResponse, error)
+	// Evaluate reports whether a load would be accepted, and the headroom on each limit, without loading it.
+	Evaluate(ctx context.Context, in *FundRequest, opts ...grpc.CallOption) (*Evaluation, error)
+	// GetUsage reports a customer's usage of each limit.
+	GetUsage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*Usage, error)
+	// BatchLoad decides a stream of loads in order, sending one response for each request.
+	// Invalid and duplicate requests are answered with ignored set rather than failing the stream.
+	BatchLoad(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[FundRequest, LoadFundResponse], error)
+}
+
+type velocityLimitsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVelocityLimitsClient(cc grpc.ClientConnInterface) VelocityLimitsClient {
+	return &velocityLimitsClient{cc}
+}
+
+func (c *velocityLimitsClient) LoadFund(ctx context.Context, in *FundRequest, opts ...grpc.CallOption) (*LoadFundResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LoadFundResponse)
+	err := c.cc.Invoke(ctx, VelocityLimits_LoadFund_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *velocityLimitsClient) Evaluate(ctx context.Context, in *FundRequest, opts ...grpc.CallOption) (*Evaluation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Evaluation)
+	err := c.cc.Invoke(ctx, VelocityLimits_Evaluate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *velocityLimitsClient) GetUsage(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*Usage, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Usage)
+	err := c.cc.Invoke(ctx, VelocityLimits_GetUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *velocityLimitsClient) BatchLoad(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[FundRequest, LoadFundResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &VelocityLimits_ServiceDesc.Streams[0], VelocityLimits_BatchLoad_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FundRequest, LoadFundResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VelocityLimits_BatchLoadClient = grpc.BidiStreamingClient[FundRequest, LoadFundResponse]
+
+// VelocityLimitsServer is the server API for VelocityLimits service.
+// All implementations must embed UnimplementedVelocityLimitsServer
+// for forward compatibility.
+//
+// VelocityLimits loads funds into customer accounts within their velocity limits.
+type VelocityLimitsServer interface {
+	// LoadFund decides a load. A request that is invalid fails with INVALID_ARGUMENT,
+	// and one whose id was already loaded for the customer fails with ALREADY_EXISTS.
+	LoadFund(context.Context, *FundRequest) (*LoadFundResponse, error)
+	// Evaluate reports whether a load would be accepted, and the headroom on each limit, without loading it.
+	Evaluate(context.Context, *FundRequest) (*Evaluation, error)
+	// GetUsage reports a customer's usage of each limit.
+	GetUsage(context.Context, *UsageRequest) (*Usage, error)
+	// BatchLoad decides a stream of loads in order, sending one response for each request.
+	// Invalid and duplicate requests are answered with ignored set rather than failing the stream.
+	BatchLoad(grpc.BidiStreamingServer[FundRequest, LoadFundResponse]) error
+	mustEmbedUnimplementedVelocityLimitsServer()
+}
+
+// UnimplementedVelocityLimitsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedVelocityLimitsServer struct{}
+
+func (UnimplementedVelocityLimitsServer) LoadFund(context.Context, *FundRequest) (*LoadFundResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method LoadFund not implemented")
+}
+func (UnimplementedVelocityLimitsServer) Evaluate(context.Context, *FundRequest) (*Evaluation, error) {
+	return nil, status.Error(codes.Unimplemented, "method Evaluate not implemented")
+}
+func (UnimplementedVelocityLimitsServer) GetUsage(context.Context, *UsageRequest) (*Usage, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetUsage not implemented")
+}
+func (UnimplementedVelocityLimitsServer) BatchLoad(grpc.BidiStreamingServer[FundRequest, LoadFundResponse]) error {
+	return status.Error(codes.Unimplemented, "method BatchLoad not implemented")
+}
+func (UnimplementedVelocityLimitsServer) mustEmbedUnimplementedVelocityLimitsServer() {}
+func (UnimplementedVelocityLimitsServer) testEmbeddedByValue()                        {}
+
+// UnsafeVelocityLimitsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VelocityLimitsServer will
+// result in compilation errors.
+type UnsafeVelocityLimitsServer interface {
+	mustEmbedUnimplementedVelocityLimitsServer()
+}
+
+func RegisterVelocityLimitsServer(s grpc.ServiceRegistrar, srv VelocityLimitsServer) {
+	// If the following call panics, it indicates UnimplementedVelocityLimitsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&VelocityLimits_ServiceDesc, srv)
+}
+
+func _VelocityLimits_LoadFund_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FundRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VelocityLimitsServer).LoadFund(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VelocityLimits_LoadFund_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VelocityLimitsServer).LoadFund(ctx, req.(*FundRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VelocityLimits_Evaluate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FundRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VelocityLimitsServer).Evaluate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VelocityLimits_Evaluate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VelocityLimitsServer).Evaluate(ctx, req.(*FundRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VelocityLimits_GetUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VelocityLimitsServer).GetUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VelocityLimits_GetUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VelocityLimitsServer).GetUsage(ctx, req.(*UsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VelocityLimits_BatchLoad_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(VelocityLimitsServer).BatchLoad(&grpc.GenericServerStream[FundRequest, LoadFundResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type VelocityLimits_BatchLoadServer = grpc.BidiStreamingServer[FundRequest, LoadFundResponse]
+
+// VelocityLimits_ServiceDesc is the grpc.ServiceDesc for VelocityLimits service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VelocityLimits_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "velocity.v1.VelocityLimits",
+	HandlerType: (*VelocityLimitsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "LoadFund",
+			Handler:    _VelocityLimits_LoadFund_Handler,
+		},
+		{
+			MethodName: "Evaluate",
+			Handler:    _VelocityLimits_Evaluate_Handler,
+		},
+		{
+			MethodName: "GetUsage",
+			Handler:    _VelocityLimits_GetUsage_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchLoad",
+			Handler:       _VelocityLimits_BatchLoad_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "velocity/v1/velocity.proto",
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+)
+
+//runAccount changes the status or blocklist entry of a customer through the server's admin API, so the change is audited
+func runAccount(args []string) {
+	flags := flag.NewFlagSet("account", flag.ExitOnError)
+	serverURL := flags.String("server", "http://localhost:8080", "url of the server whose admin API makes the change")
+	token := flags.String("token", os.Getenv("VELOCITY_ADMIN_TOKEN"), "admin bearer token, defaults to $VELOCITY_ADMIN_TOKEN")
+	reason := flags.String("reason", "", "why the change is made, recorded in the audit trail")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: processFunds account [flags] activate|freeze|close|block|unblock <customer id>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	result, err := changeAccount(http.DefaultClient, *serverURL, *token, *reason, flags.Arg(0), flags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(result)
+}
+
+//accountRequest returns the admin API request that makes the change to a customer
+func accountRequest(serverURL, reason, action, customerID string) (*http.Request, error) {
+	body := map[string]string{"reason": reason}
+	method, path := http.MethodPut, "/admin/customers/"+url.PathEscape(customerID)+"/status"
+	switch action {
+	case "activate":
+		body["status"] = string(account.StatusActive)
+	case "freeze":
+		body["status"] = string(account.StatusFrozen)
+	case "close":
+		body["status"] = string(account.StatusClosed)
+	case "block":
+		path = "/admin/blocklist/" + url.PathEscape(customerID)
+	case "unblock":
+		method, path = http.MethodDelete, "/admin/blocklist/"+url.PathEscape(customerID)
+	default:
+		return nil, fmt.Errorf("unknown account action %q, expected activate, freeze, close, block or unblock", action)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, strings.TrimSuffix(serverURL, "/")+path, bytes.NewReader(data))
+}
+
+//changeAccount makes the change and returns the customer's standing after it, as json
+func changeAccount(client *http.Client, serverURL, token, reason, action, customerID string) (string, error) {
+	req, err := accountRequest(serverURL, reason, action, customerID)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+			return "", fmt.Errorf("%s %s: %s", action, customerID, errResp.Error)
+		}
+		return "", fmt.Errorf("%s %s: %s", action, customerID, resp.Status)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/server"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccountCommandTestSuite struct {
+	suite.Suite
+	store  *account.ConfigStore
+	server *httptest.Server
+}
+
+func TestAccountCommand(t *testing.T) {
+	suite.Run(t, new(AccountCommandTestSuite))
+}
+
+func (s *AccountCommandTestSuite) SetupTest() {
+	s.store = account.NewConfigStore(account.DefaultPolicy())
+	handler := newHandler(cache.New(cache.NoExpiration, 10*time.Minute), account.CustomerAccount{Policies: s.store})
+	srv := server.New(&handler)
+	srv.EnableAdmin(s.store, map[string]string{"alice-token": "alice"})
+	s.server = httptest.NewServer(srv)
+}
+
+func (s *AccountCommandTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *AccountCommandTestSuite) TestChangeAccount() {
+	result, err := changeAccount(http.DefaultClient, s.server.URL+"/", "alice-token", "chargeback", "freeze", "18")
+	s.Require().NoError(err)
+	s.Equal(`{"customer_id":"18","status":"frozen","blocked":false}`, result)
+	result, err = changeAccount(http.DefaultClient, s.server.URL, "alice-token", "fraud ring", "block", "18")
+	s.Require().NoError(err)
+	s.Equal(`{"customer_id":"18","status":"frozen","blocked":true}`, result)
+	_, err = changeAccount(http.DefaultClient, s.server.URL, "alice-token", "cleared", "unblock", "18")
+	s.Require().NoError(err)
+	_, err = changeAccount(http.DefaultClient, s.server.URL, "alice-token", "cleared", "activate", "18")
+	s.Require().NoError(err)
+
+	changes := s.store.Changes()
+	s.Require().Len(changes, 4)
+	s.Equal("alice", changes[1].Actor)
+	s.Equal("fraud ring", changes[1].Reason)
+	s.Equal("block", changes[1].Action)
+	s.Equal(account.StatusActive, s.store.Current().Status("18"))
+}
+
+func (s *AccountCommandTestSuite) TestErrors() {
+	_, err := changeAccount(http.DefaultClient, s.server.URL, "alice-token", "", "close", "18")
+	s.EqualError(err, "close 18: reason is required")
+	_, err = changeAccount(http.DefaultClient, s.server.URL, "mallory-token", "why not", "close", "18")
+	s.EqualError(err, "close 18: unauthorized")
+	_, err = changeAccount(http.DefaultClient, s.server.URL, "alice-token", "twice", "unblock", "18")
+	s.EqualError(err, "unblock 18: blocklist entry for customer 18 does not exist")
+	_, err = changeAccount(http.DefaultClient, s.server.URL, "alice-token", "typo", "thaw", "18")
+	s.EqualError(err, `unknown account action "thaw", expected activate, freeze, close, block or unblock`)
+	s.Empty(s.store.Changes())
+}
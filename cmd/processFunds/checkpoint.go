@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/atomicfile"
+	"github.com/rnidev/velocity-limits/cmd/pkg/codec"
+)
+
+//checkpoint is how far a run got: the requests processed, the output and flagged loads written for them and
+//the account state after them. Input size and policy version are kept to refuse resuming a different run.
+type checkpoint struct {
+	Requests      int              `json:"requests"`
+	OutputOffset  int64            `json:"output_offset"`
+	ReviewOffset  int64            `json:"review_offset,omitempty"`
+	InputSize     int64            `json:"input_size"`
+	PolicyVersion string           `json:"policy_version"`
+	Snapshot      account.Snapshot `json:"snapshot"`
+}
+
+//batch processes an input file into an output file, optionally checkpointing so a crashed run can be resumed
+type batch struct {
+	requests     []account.FundRequest
+	outputPath   string
+	outputFormat codec.Format
+	extended     bool
+	//reviewPath, when set, is where the loads flagged by a soft limit are written
+	reviewPath string
+	//checkpointPath is where checkpoints are written, and checkpointEvery how many requests apart. Zero disables checkpoints.
+	checkpointPath  string
+	checkpointEvery int
+	//resume carries on from the checkpoint, if there is one, instead of starting over
+	resume        bool
+	inputSize     int64
+	policyVersion string
+}
+
+//run processes the requests, removing the checkpoint once every request is processed
+func (b batch) run(c *cache.Cache, handler *account.FundHandler) error {
+	start := checkpoint{InputSize: b.inputSize, PolicyVersion: b.policyVersion}
+	if b.resume {
+		var err error
+		if start, err = b.load(); err != nil {
+			return err
+		}
+	}
+	//drop any output written after the checkpoint, it is written again from the checkpoint's state
+	output, err := b.open(b.outputPath, start.OutputOffset, 0755)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	var review *os.File
+	if b.reviewPath != "" {
+		//so are the loads flagged after it
+		if review, err = b.open(b.reviewPath, start.ReviewOffset, 0644); err != nil {
+			return err
+		}
+		defer review.Close()
+		queue := account.NewReviewQueue()
+		queue.LogTo(review)
+		handler.SetReviewQueue(queue)
+	}
+	account.RestoreAccounts(c, start.Snapshot.Accounts)
+	format := b.outputFormat
+	//the header row of csv output was written before the checkpoint
+	if csv, ok := format.(codec.CSV); ok && start.OutputOffset > 0 {
+		csv.NoHeader = true
+		format = csv
+	}
+	encoder := format.NewEncoder(output)
+	if err = b.process(start.Requests, c, handler, encoder, output, review); err != nil {
+		return err
+	}
+	if err = output.Close(); err != nil {
+		return err
+	}
+	if review != nil {
+		if err = review.Close(); err != nil {
+			return err
+		}
+	}
+	if b.checkpointEvery > 0 || b.resume {
+		if err = os.Remove(b.checkpointPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//open opens the file at path to write from offset, truncating what is after it. A run that is not resumed
+//starts the file over.
+func (b batch) open(path string, offset int64, perm os.FileMode) (*os.File, error) {
+	flags := os.O_RDWR | os.O_CREATE
+	if !b.resume {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Truncate(offset); err == nil {
+		_, err = f.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+//process processes the requests from start, writing a checkpoint after every checkpointEvery requests
+func (b batch) process(start int, c *cache.Cache, handler *account.FundHandler, encoder codec.Encoder, output, review *os.File) error {
+	if b.checkpointEvery <= 0 {
+		return process(b.requests[start:], handler, encoder, b.extended)
+	}
+	for start < len(b.requests) {
+		end := start + b.checkpointEvery
+		if end > len(b.requests) {
+			end = len(b.requests)
+		}
+		if err := process(b.requests[start:end], handler, encoder, b.extended); err != nil {
+			return err
+		}
+		if err := b.save(end, c, output, review); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+//offset syncs the file and returns how much of it is written, zero for no file
+func offset(f *os.File) (int64, error) {
+	if f == nil {
+		return 0, nil
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return f.Seek(0, io.SeekCurrent)
+}
+
+//save syncs the output and review files and then writes the checkpoint through a rename, so a crash leaves
+//a whole checkpoint that never points past either on disk
+func (b batch) save(requests int, c *cache.Cache, output, review *os.File) error {
+	outputOffset, err := offset(output)
+	if err != nil {
+		return err
+	}
+	reviewOffset, err := offset(review)
+	if err != nil {
+		return err
+	}
+	snapshot, err := account.NewSnapshot(c, time.Now())
+	if err != nil {
+		return err
+	}
+	jsonByte, err := json.Marshal(checkpoint{
+		Requests:      requests,
+		OutputOffset:  outputOffset,
+		ReviewOffset:  reviewOffset,
+		InputSize:     b.inputSize,
+		PolicyVersion: b.policyVersion,
+		Snapshot:      snapshot,
+	})
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteBytes(b.checkpointPath, jsonByte)
+}
+
+//load reads the checkpoint to resume from, or an empty one to start over if there is none
+func (b batch) load() (checkpoint, error) {
+	start := checkpoint{InputSize: b.inputSize, PolicyVersion: b.policyVersion}
+	data, err := ioutil.ReadFile(b.checkpointPath)
+	if os.IsNotExist(err) {
+		return start, nil
+	}
+	if err != nil {
+		return start, err
+	}
+	if err = json.Unmarshal(data, &start); err != nil {
+		return start, fmt.Errorf("checkpoint %s: %s", b.checkpointPath, err)
+	}
+	if start.InputSize != b.inputSize || start.PolicyVersion != b.policyVersion {
+		return start, fmt.Errorf("checkpoint %s is for a different input or policy", b.checkpointPath)
+	}
+	if err = start.Snapshot.Verify(); err != nil {
+		return start, fmt.Errorf("checkpoint %s: %s", b.checkpointPath, err)
+	}
+	if start.Requests > len(b.requests) {
+		return start, fmt.Errorf("checkpoint %s is past the end of the input", b.checkpointPath)
+	}
+	return start, nil
+}
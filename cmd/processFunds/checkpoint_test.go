@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/codec"
+	"github.com/stretchr/testify/suite"
+)
+
+type CheckpointTestSuite struct {
+	suite.Suite
+	dir     string
+	service account.CustomerAccount
+}
+
+func TestCheckpoint(t *testing.T) {
+	suite.Run(t, new(CheckpointTestSuite))
+}
+
+func (s *CheckpointTestSuite) SetupTest() {
+	var err error
+	s.dir, err = ioutil.TempDir("", "checkpoint")
+	s.Require().NoError(err)
+	s.service = account.CustomerAccount{}
+}
+
+func (s *CheckpointTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+//batch returns a batch over the input file that writes to the temp dir in the same format
+func (s *CheckpointTestSuite) batch(inputPath string, every int) batch {
+	format, err := codec.Lookup(codec.ForPath(inputPath))
+	s.Require().NoError(err)
+	requests, err := readRequests(inputPath, format)
+	s.Require().NoError(err)
+	outputPath := filepath.Join(s.dir, "output"+filepath.Ext(inputPath))
+	return batch{
+		requests:        requests,
+		outputPath:      outputPath,
+		outputFormat:    format,
+		checkpointPath:  outputPath + ".checkpoint",
+		checkpointEvery: every,
+		inputSize:       int64(len(requests)),
+		policyVersion:   account.DefaultPolicy().Version,
+	}
+}
+
+func newCache() *cache.Cache {
+	return cache.New(cache.NoExpiration, 10*time.Minute)
+}
+
+//crash processes the first requests of the batch with checkpoints, then a few more without one,
+//and stops as if the process died before the next checkpoint
+func (s *CheckpointTestSuite) crash(b batch, checkpointed, more int) {
+	c := newCache()
+	handler := newHandler(c, s.service)
+	output, err := os.Create(b.outputPath)
+	s.Require().NoError(err)
+	defer output.Close()
+	var review *os.File
+	if b.reviewPath != "" {
+		review, err = os.Create(b.reviewPath)
+		s.Require().NoError(err)
+		defer review.Close()
+		queue := account.NewReviewQueue()
+		queue.LogTo(review)
+		handler.SetReviewQueue(queue)
+	}
+	encoder := b.outputFormat.NewEncoder(output)
+	partial := b
+	partial.requests = b.requests[:checkpointed]
+	s.Require().NoError(partial.process(0, c, &handler, encoder, output, review))
+	s.Require().NoError(process(b.requests[checkpointed:checkpointed+more], &handler, encoder, false))
+}
+
+func (s *CheckpointTestSuite) resume(b batch) string {
+	b.resume = true
+	c := newCache()
+	handler := newHandler(c, s.service)
+	s.Require().NoError(b.run(c, &handler))
+	output, err := ioutil.ReadFile(b.outputPath)
+	s.Require().NoError(err)
+	return string(output)
+}
+
+func (s *CheckpointTestSuite) TestResumeMatchesUninterruptedRun() {
+	b := s.batch("../../input.txt", 100)
+	s.crash(b, 730, 40)
+	expected, err := ioutil.ReadFile("../../output.txt")
+	s.Require().NoError(err)
+	s.Equal(string(expected), s.resume(b))
+	_, err = os.Stat(b.checkpointPath)
+	s.True(os.IsNotExist(err), "checkpoint should be removed once the run completes")
+}
+
+//flagged returns the customer and load IDs of the loads in a review log, in order
+func (s *CheckpointTestSuite) flagged(path string) []string {
+	data, err := ioutil.ReadFile(path)
+	s.Require().NoError(err)
+	var ids []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var detail account.FundDetail
+		s.Require().NoError(json.Unmarshal([]byte(line), &detail))
+		ids = append(ids, detail.CustomerID+"/"+detail.ID)
+	}
+	return ids
+}
+
+func (s *CheckpointTestSuite) TestResumeReview() {
+	policy := account.DefaultPolicy()
+	policy.Limits = append(policy.Limits, account.Limit{Name: "daily_review", Period: account.Period{Unit: account.Day}, MaxAmount: 2000, Soft: true})
+	s.service = account.CustomerAccount{Policy: policy}
+	b := s.batch("../../input.txt", 100)
+	b.reviewPath = filepath.Join(s.dir, "review.log")
+	uninterrupted := b
+	uninterrupted.reviewPath = filepath.Join(s.dir, "uninterrupted.log")
+	uninterrupted.checkpointEvery = 0
+	c := newCache()
+	handler := newHandler(c, s.service)
+	s.Require().NoError(uninterrupted.run(c, &handler))
+	expected := s.flagged(uninterrupted.reviewPath)
+	s.NotEmpty(expected)
+
+	s.crash(b, 730, 40)
+	s.resume(b)
+	s.Equal(expected, s.flagged(b.reviewPath), "loads flagged after the checkpoint are written once")
+}
+
+func (s *CheckpointTestSuite) TestResumeCSV() {
+	b := s.batch("testdata/csv/input.csv", 2)
+	s.crash(b, 2, 1)
+	expected, err := ioutil.ReadFile("testdata/csv/output.csv")
+	s.Require().NoError(err)
+	s.Equal(string(expected), s.resume(b))
+}
+
+func (s *CheckpointTestSuite) TestResumeWithoutCheckpointStartsOver() {
+	b := s.batch("../../input.txt", 0)
+	s.Require().NoError(ioutil.WriteFile(b.outputPath, []byte("stale output\n"), 0644))
+	expected, err := ioutil.ReadFile("../../output.txt")
+	s.Require().NoError(err)
+	s.Equal(string(expected), s.resume(b))
+}
+
+func (s *CheckpointTestSuite) TestResumeRefusesDifferentInput() {
+	b := s.batch("../../input.txt", 100)
+	s.crash(b, 200, 0)
+	b.inputSize++
+	b.resume = true
+	c := newCache()
+	handler := newHandler(c, s.service)
+	s.Error(b.run(c, &handler))
+}
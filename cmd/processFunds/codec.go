@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"unicode/utf8"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/codec"
+)
+
+//codecFlags choose the format of the input and output files. Formats default to the one registered for each file's extension.
+type codecFlags struct {
+	inputFormat  *string
+	outputFormat *string
+	delimiter    *string
+	columns      *string
+	noHeader     *bool
+}
+
+//newCodecFlags defines the codec flags on flags, leaving out -output-format for commands that write no file
+func newCodecFlags(flags *flag.FlagSet, withOutput bool) codecFlags {
+	f := codecFlags{
+		inputFormat: flags.String("input-format", "", "input format: ndjson, csv or tsv, defaults to the one for the input file extension"),
+		delimiter:   flags.String("csv-delimiter", "", "field delimiter of csv files, a single character or \\t, defaults to the format's own"),
+		columns:     flags.String("csv-columns", "", "csv header names of request fields that are named differently, as field=column pairs like id=load_id,time=timestamp"),
+		noHeader:    flags.Bool("csv-no-header", false, "read and write csv without a header row, with input columns id, customer_id, load_amount and time"),
+	}
+	if withOutput {
+		f.outputFormat = flags.String("output-format", "", "output format: ndjson, csv or tsv, defaults to the one for the output file extension")
+	}
+	return f
+}
+
+func (f codecFlags) input(path string) (codec.Format, error) {
+	return f.format(*f.inputFormat, path)
+}
+
+func (f codecFlags) output(path string) (codec.Format, error) {
+	return f.format(*f.outputFormat, path)
+}
+
+func (f codecFlags) format(name, path string) (codec.Format, error) {
+	if name == "" {
+		name = codec.ForPath(path)
+	}
+	format, err := codec.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	csv, ok := format.(codec.CSV)
+	if !ok {
+		return format, nil
+	}
+	switch {
+	case *f.delimiter == `\t`:
+		csv.Comma = '\t'
+	case utf8.RuneCountInString(*f.delimiter) == 1:
+		csv.Comma, _ = utf8.DecodeRuneInString(*f.delimiter)
+	case *f.delimiter != "":
+		return nil, fmt.Errorf("csv delimiter %q is not a single character", *f.delimiter)
+	}
+	if csv.Columns, err = codec.ParseColumns(*f.columns); err != nil {
+		return nil, err
+	}
+	csv.NoHeader = *f.noHeader
+	return csv, nil
+}
+
+//readRequests decodes every request in the file, logging and skipping those that cannot be decoded
+func readRequests(path string, format codec.Format) ([]account.FundRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return codec.ReadAll(format.NewDecoder(file), func(err error) {
+		log.Printf("%s: %s", path, err)
+	})
+}
@@ -1,95 +1,212 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"time"
 
 	cache "github.com/patrickmn/go-cache"
 	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/codec"
+	"github.com/rnidev/velocity-limits/cmd/pkg/tracing"
 	validator "gopkg.in/go-playground/validator.v9"
 )
 
 func main() {
-	inputs, err := readInputFile("../../input.txt")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "simulate":
+			runSimulate(os.Args[2:])
+			return
+		case "produce":
+			runProduce(os.Args[2:])
+			return
+		case "consume":
+			runConsume(os.Args[2:])
+			return
+		case "account":
+			runAccount(os.Args[2:])
+			return
+		}
+	}
+	inputPath := flag.String("input", "../../input.txt", "file of fund requests, one json object per line or a csv file")
+	outputPath := flag.String("output", "../../output.txt", "file to write responses to")
+	evaluate := flag.String("evaluate", "", "json fund request to evaluate after replaying the input, without writing any output")
+	usage := flag.String("usage", "", "customer id to report limit usage for after replaying the input, without writing any output")
+	at := flag.String("at", "", "RFC3339 time to report -usage as of, defaults to now")
+	policyPath := flag.String("policy", "", "json policy file of limits, defaults to the built in limits")
+	outOfOrder := flag.String("out-of-order", string(account.AcceptLate), "how to treat a load older than the customer's newest load: accept, reject or reevaluate")
+	lateness := flag.Duration("lateness", 0, "how far behind the customer's newest load a load may be before -out-of-order reject declines it")
+	extended := flag.Bool("extended", false, "write extended responses with the parsed amount, UTC time, day and week totals, policy version, outcome, decline reason, review flags and decision latency")
+	reviewPath := flag.String("review", "", "file to write the loads accepted but flagged by a soft limit to, as json lines")
+	checkpointPath := flag.String("checkpoint", "", "file to write checkpoints to, defaults to the output path with .checkpoint appended")
+	checkpointEvery := flag.Int("checkpoint-every", 0, "write a checkpoint after every this many requests, zero disables checkpoints")
+	resume := flag.Bool("resume", false, "carry on from the last checkpoint instead of starting over, if there is one")
+	importPath := flag.String("import", "", "snapshot file of account state to start from, in either format")
+	configPath := flag.String("config", "", "admin config file saved by the server's -config, whose tiers, overrides and customer standing the loads are decided with")
+	exportPath := flag.String("export", "", "file to write a snapshot of account state to once the input is processed")
+	snapshotFormat := flag.String("snapshot-format", string(account.SnapshotJSON), "format of the -export snapshot: json or binary")
+	traceExporter := flag.String("trace", tracing.None, "exporter to send a trace of every decision to: stdout, which prints to standard error, or otlp. Empty disables tracing.")
+	codecs := newCodecFlags(flag.CommandLine, true)
+	flag.Parse()
+	if err := account.OutOfOrder(*outOfOrder).Validate(); err != nil {
+		log.Fatal(err)
+	}
+	shutdown, err := tracing.Setup(*traceExporter, "processFunds")
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
-	output, err := os.OpenFile("../../output.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	defer func() {
+		if err := shutdown(); err != nil {
+			log.Print(err)
+		}
+	}()
+	inputFormat, err := codecs.input(*inputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outputFormat, err := codecs.output(*outputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	requests, err := readRequests(*inputPath, inputFormat)
 	if err != nil {
 		panic(err)
 	}
-	c := cache.New(5*time.Minute, 10*time.Minute)
-	for _, input := range inputs {
-		var s account.Service
-		s = account.CustomerAccount{}
-		v := validator.New()
-		handler := account.NewHandler(s, v, c)
-		response := handler.Run(input)
-		//ignore empty response
-		if (response == account.FundResponse{}) {
-			continue
+	policy := account.DefaultPolicy()
+	if *policyPath != "" {
+		if policy, err = account.LoadPolicy(*policyPath); err != nil {
+			panic(err)
 		}
-		jsonByte, err := json.Marshal(&response)
+	}
+	c := cache.New(5*time.Minute, 10*time.Minute)
+	var imported *account.Config
+	if *importPath != "" {
+		snapshot, err := account.LoadSnapshot(*importPath)
 		if err != nil {
-			log.Print(err)
-			continue
+			log.Fatal(err)
+		}
+		if err = snapshot.Restore(c); err != nil {
+			log.Fatal(err)
+		}
+		imported = snapshot.Config
+	}
+	service := account.CustomerAccount{
+		Policy:     policy,
+		OutOfOrder: account.OutOfOrder(*outOfOrder),
+		Lateness:   *lateness,
+	}
+	policyVersion := policy.Version
+	store, err := configStore(policy, *configPath, imported)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if store != nil {
+		service.Policies = store
+		policyVersion = fmt.Sprintf("config@%d", store.Current().Version)
+	}
+	if *evaluate != "" || *usage != "" {
+		handler := newHandler(c, service)
+		for _, request := range requests {
+			handler.ProcessRequest(request)
 		}
-		_, err = fmt.Fprintln(output, string(jsonByte))
+		var result interface{}
+		if *evaluate != "" {
+			if result, err = handler.Evaluate(*evaluate); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			asOf := handler.Now()
+			if *at != "" {
+				if asOf, err = time.Parse(time.RFC3339, *at); err != nil {
+					log.Fatal(err)
+				}
+			}
+			result = handler.Usage(*usage, asOf)
+		}
+		jsonByte, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
-			log.Print(err)
-			continue
+			log.Fatal(err)
 		}
+		fmt.Println(string(jsonByte))
+		return
 	}
-	err = output.Close()
+	input, err := os.Stat(*inputPath)
 	if err != nil {
 		panic(err)
 	}
+	if *checkpointPath == "" {
+		*checkpointPath = *outputPath + ".checkpoint"
+	}
+	b := batch{
+		requests:        requests,
+		outputPath:      *outputPath,
+		outputFormat:    outputFormat,
+		extended:        *extended,
+		checkpointPath:  *checkpointPath,
+		checkpointEvery: *checkpointEvery,
+		resume:          *resume,
+		reviewPath:      *reviewPath,
+		inputSize:       input.Size(),
+		policyVersion:   policyVersion,
+	}
+	handler := newHandler(c, service)
+	if err = b.run(c, &handler); err != nil {
+		panic(err)
+	}
+	if *exportPath != "" {
+		snapshot, err := account.NewSnapshot(c, handler.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = snapshot.Save(*exportPath, account.SnapshotFormat(*snapshotFormat)); err != nil {
+			log.Fatal(err)
+		}
+	}
 	c.Flush()
 }
 
-func readInputFile(filePath string) ([]string, error) {
-	var inputs []string
-	file, err := os.Open(filePath)
-	if err != nil {
-		return inputs, err
-	}
-	defer file.Close()
-	reader := bufio.NewReader(file)
-	for {
-		var (
-			buffer   bytes.Buffer
-			line     []byte
-			isPrefix bool
-		)
-		for {
-			line, isPrefix, err = reader.ReadLine()
-			buffer.Write(line)
-			if !isPrefix {
-				break
+//process runs each request through the handler and encodes its responses, flushing the encoder at the end.
+//Corrections to earlier responses follow the response for the load that caused them.
+//Extended responses are written as account.FundDetail instead of account.FundResponse.
+func process(requests []account.FundRequest, handler *account.FundHandler, encoder codec.Encoder, extended bool) error {
+	for _, request := range requests {
+		//ignored requests have no responses
+		for _, detail := range handler.ProcessRequestDetail(request) {
+			var record interface{} = &detail.FundResponse
+			if extended {
+				record = &detail
 			}
-			if err != nil {
-				if err != io.EOF {
-					return inputs, err
-				}
-				break
+			if err := encoder.Encode(record); err != nil {
+				log.Print(err)
 			}
 		}
-		lineString := buffer.String()
-		if err == io.EOF {
-			break
-		}
-		if len(lineString) > 0 {
-			inputs = append(inputs, lineString)
+	}
+	return encoder.Flush()
+}
+
+//configStore returns a ConfigStore restored from the config file, or from the config section of the imported
+//snapshot when there is no file, so loads are decided with the same tiers, overrides and customer standing as
+//the server. It is nil when there is neither.
+func configStore(policy *account.Policy, configPath string, imported *account.Config) (*account.ConfigStore, error) {
+	config := imported
+	if configPath != "" {
+		var err error
+		if config, err = account.LoadConfig(configPath); err != nil {
+			return nil, err
 		}
 	}
-	if err != io.EOF {
-		return inputs, err
+	if config == nil {
+		return nil, nil
 	}
-	return inputs, nil
+	store := account.NewConfigStore(policy)
+	return store, store.Restore(config)
+}
+
+func newHandler(c *cache.Cache, s account.Service) account.FundHandler {
+	v := validator.New()
+	return account.NewHandler(s, v, c)
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/rnidev/velocity-limits/cmd/pkg/codec"
+	"github.com/stretchr/testify/suite"
+)
+
+var update = flag.Bool("update", false, "regenerate the expected output of every golden fixture")
+
+//maxReportedDiffs caps how many mismatched lines a failing fixture reports
+const maxReportedDiffs = 10
+
+//fixture is a request file, the responses expected for it, and optionally the policy and admin config to
+//run it under and the extended responses expected for it. All files are in the format registered for their extension.
+type fixture struct {
+	name     string
+	input    string
+	output   string
+	policy   string
+	config   string
+	extended string
+}
+
+type GoldenTestSuite struct {
+	suite.Suite
+}
+
+func TestGolden(t *testing.T) {
+	suite.Run(t, new(GoldenTestSuite))
+}
+
+//fixtures returns the sample shipped at the repository root, and every directory in testdata
+func (s *GoldenTestSuite) fixtures() []fixture {
+	fixtures := []fixture{{name: "sample", input: "../../input.txt", output: "../../output.txt"}}
+	dirs, err := filepath.Glob(filepath.Join("testdata", "*"))
+	s.Require().NoError(err)
+	for _, dir := range dirs {
+		inputs, err := filepath.Glob(filepath.Join(dir, "input.*"))
+		s.Require().NoError(err)
+		s.Require().Len(inputs, 1, "%s should have one input file", dir)
+		f := fixture{
+			name:   filepath.Base(dir),
+			input:  inputs[0],
+			output: filepath.Join(dir, "output"+filepath.Ext(inputs[0])),
+		}
+		if fileExists(filepath.Join(dir, "policy.json")) {
+			f.policy = filepath.Join(dir, "policy.json")
+		}
+		if fileExists(filepath.Join(dir, "config.json")) {
+			f.config = filepath.Join(dir, "config.json")
+		}
+		if extended := filepath.Join(dir, "extended"+filepath.Ext(f.input)); fileExists(extended) {
+			f.extended = extended
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures
+}
+
+func (s *GoldenTestSuite) TestFixtures() {
+	for _, f := range s.fixtures() {
+		s.Run(f.name, func() {
+			s.check(f, f.output, false)
+			if f.extended != "" {
+				s.check(f, f.extended, true)
+			}
+		})
+	}
+}
+
+//check processes the fixture's input and compares the responses with the expected file
+func (s *GoldenTestSuite) check(f fixture, expectedPath string, extended bool) {
+	format, err := codec.Lookup(codec.ForPath(f.input))
+	s.Require().NoError(err)
+	requests, err := readRequests(f.input, format)
+	s.Require().NoError(err)
+	policy := account.DefaultPolicy()
+	if f.policy != "" {
+		policy, err = account.LoadPolicy(f.policy)
+		s.Require().NoError(err)
+	}
+	service := account.CustomerAccount{Policy: policy}
+	store, err := configStore(policy, f.config, nil)
+	s.Require().NoError(err)
+	if store != nil {
+		service.Policies = store
+	}
+	handler := newHandler(cache.New(cache.NoExpiration, 10*time.Minute), service)
+	//a stopped clock keeps decision latency out of the expected output
+	handler.SetClock(clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+	var actual bytes.Buffer
+	s.Require().NoError(process(requests, &handler, format.NewEncoder(&actual), extended))
+	if *update {
+		s.Require().NoError(ioutil.WriteFile(expectedPath, actual.Bytes(), 0644))
+		return
+	}
+	expected, err := ioutil.ReadFile(expectedPath)
+	s.Require().NoError(err)
+	s.diff(expectedPath, string(expected), actual.String())
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+//diff reports the lines where actual differs from expected, by line number
+func (s *GoldenTestSuite) diff(path, expected, actual string) {
+	expectedLines := strings.Split(strings.TrimSuffix(expected, "\n"), "\n")
+	actualLines := strings.Split(strings.TrimSuffix(actual, "\n"), "\n")
+	lines := len(expectedLines)
+	if len(actualLines) > lines {
+		lines = len(actualLines)
+	}
+	diffs := 0
+	for i := 0; i < lines; i++ {
+		var want, got string
+		if i < len(expectedLines) {
+			want = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			got = actualLines[i]
+		}
+		if want == got {
+			continue
+		}
+		diffs++
+		if diffs <= maxReportedDiffs {
+			s.T().Errorf("%s:%d\n\texpected: %s\n\tactual:   %s", path, i+1, want, got)
+		}
+	}
+	if diffs > maxReportedDiffs {
+		s.T().Errorf("%s: %d more lines differ", path, diffs-maxReportedDiffs)
+	}
+	if diffs > 0 {
+		s.T().Log("run go test -update to regenerate the expected output if the change is intended")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/rnidev/velocity-limits/cmd/pkg/queue"
+)
+
+//runProduce publishes the requests in an input file to a topic of a file backed broker
+func runProduce(args []string) {
+	flags := flag.NewFlagSet("produce", flag.ExitOnError)
+	brokerDir := flags.String("broker-dir", "broker", "directory of the file backed broker")
+	topic := flags.String("topic", "loads", "topic to publish the requests to")
+	inputPath := flags.String("input", "../../input.txt", "file of fund requests, one json object per line or a csv file")
+	codecs := newCodecFlags(flags, false)
+	flags.Parse(args)
+	broker, err := queue.NewFileBroker(*brokerDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	inputFormat, err := codecs.input(*inputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	requests, err := readRequests(*inputPath, inputFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	messages := make([]queue.Message, 0, len(requests))
+	for _, request := range requests {
+		value, err := json.Marshal(&request)
+		if err != nil {
+			log.Fatal(err)
+		}
+		messages = append(messages, queue.Message{Key: request.CustomerID, Value: value})
+	}
+	if err = broker.Publish(*topic, messages...); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("published %d requests to %s", len(messages), *topic)
+}
+
+//runConsume decides the requests on a topic of a file backed broker, publishing responses to another topic
+func runConsume(args []string) {
+	flags := flag.NewFlagSet("consume", flag.ExitOnError)
+	brokerDir := flags.String("broker-dir", "broker", "directory of the file backed broker")
+	group := flags.String("group", "processFunds", "consumer group to commit offsets for")
+	inputTopic := flags.String("input-topic", "loads", "topic to read requests from")
+	outputTopic := flags.String("output-topic", "responses", "topic to publish responses to")
+	batchSize := flags.Int("batch-size", 100, "most requests to decide between offset commits")
+	statePath := flags.String("state", "", "file to save account state to with each offset commit and restore it from on start, defaults to <group>.<input-topic>.state in -broker-dir")
+	follow := flags.Bool("follow", false, "keep polling for new requests until interrupted, instead of stopping once caught up")
+	pollInterval := flags.Duration("poll-interval", time.Second, "how long to wait for new requests when caught up with -follow")
+	policyPath := flags.String("policy", "", "json policy file of limits, defaults to the built in limits")
+	configPath := flags.String("config", "", "admin config file saved by the server's -config, whose tiers, overrides and customer standing the loads are decided with")
+	watchPolicy := flags.Duration("watch-policy", 0, "how often to check the -policy file for changes and reload it with -follow, zero never reloads it")
+	outOfOrder := flags.String("out-of-order", string(account.AcceptLate), "how to treat a load older than the customer's newest load: accept, reject or reevaluate")
+	lateness := flags.Duration("lateness", 0, "how far behind the customer's newest load a load may be before -out-of-order reject declines it")
+	flags.Parse(args)
+	if err := account.OutOfOrder(*outOfOrder).Validate(); err != nil {
+		log.Fatal(err)
+	}
+	service := account.CustomerAccount{
+		OutOfOrder: account.OutOfOrder(*outOfOrder),
+		Lateness:   *lateness,
+	}
+	var policyFile *account.PolicyFile
+	policy := account.DefaultPolicy()
+	if *policyPath != "" {
+		var err error
+		if policyFile, err = account.NewPolicyFile(*policyPath); err != nil {
+			log.Fatal(err)
+		}
+		service.Policies = policyFile
+		policy = policyFile.Current()
+	}
+	store, err := configStore(policy, *configPath, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if store != nil {
+		service.Policies = store
+	}
+	broker, err := queue.NewFileBroker(*brokerDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(*brokerDir, *group+"."+*inputTopic+".state")
+	}
+	c := cache.New(cache.NoExpiration, 10*time.Minute)
+	handler := newHandler(c, service)
+	consumer := &queue.Consumer{
+		Broker:       broker,
+		Group:        *group,
+		InputTopic:   *inputTopic,
+		OutputTopic:  *outputTopic,
+		Handler:      &handler,
+		BatchSize:    *batchSize,
+		PollInterval: *pollInterval,
+		StatePath:    *statePath,
+		Cache:        c,
+	}
+	if *follow {
+		stop := make(chan struct{})
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		go func() {
+			<-interrupt
+			close(stop)
+		}()
+		if policyFile != nil && *watchPolicy > 0 {
+			go policyFile.Watch(clock.New(), *watchPolicy, stop, func(policy *account.Policy, err error) {
+				if err != nil {
+					log.Printf("keeping policy %s: %s", policy.Version, err)
+					return
+				}
+				//with a config the file holds the default tier's policy, as it does for the server
+				if store != nil {
+					if _, err = store.ReloadPolicy("policy-file", "reloaded "+*policyPath+" version "+policy.Version, account.DefaultTier, *policy); err != nil {
+						log.Printf("keeping policy: %s", err)
+						return
+					}
+				}
+				log.Printf("reloaded policy %s from %s", policy.Version, *policyPath)
+			})
+		}
+		consumer.Run(stop)
+		return
+	}
+	for {
+		read, err := consumer.Poll()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if read == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/simulate"
+)
+
+//runSimulate replays an input file under the current and a candidate policy and reports the differences
+func runSimulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	inputPath := flags.String("input", "../../input.txt", "file of fund requests, one json object per line or a csv file")
+	currentPath := flags.String("current", "", "json policy file in effect today, defaults to the built in limits")
+	candidatePath := flags.String("candidate", "", "json policy file to compare against the current one")
+	format := flags.String("format", "table", "report format, table or json")
+	top := flags.Int("top", 10, "number of most affected customers to report")
+	codecs := newCodecFlags(flags, false)
+	flags.Parse(args)
+	if *candidatePath == "" {
+		log.Fatal("simulate: -candidate is required")
+	}
+	if *format != "table" && *format != "json" {
+		log.Fatalf("simulate: unknown format %q", *format)
+	}
+	current := account.DefaultPolicy()
+	if *currentPath != "" {
+		var err error
+		if current, err = account.LoadPolicy(*currentPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	candidate, err := account.LoadPolicy(*candidatePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	inputFormat, err := codecs.input(*inputPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	requests, err := readRequests(*inputPath, inputFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	report := simulate.Run(requests, current, candidate, *top)
+	if *format == "json" {
+		jsonByte, err := json.MarshalIndent(&report, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(jsonByte))
+		return
+	}
+	if err = report.WriteTable(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
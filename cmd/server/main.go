@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	cache "github.com/patrickmn/go-cache"
+	"github.com/rnidev/velocity-limits/cmd/pkg/account"
+	"github.com/rnidev/velocity-limits/cmd/pkg/clock"
+	"github.com/rnidev/velocity-limits/cmd/pkg/rpc"
+	"github.com/rnidev/velocity-limits/cmd/pkg/server"
+	"github.com/rnidev/velocity-limits/cmd/pkg/tracing"
+	"google.golang.org/grpc"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	grpcAddr := flag.String("grpc-addr", "", "address to serve the gRPC service on, which shares accounts with the HTTP server. Empty disables it.")
+	retention := flag.Duration("retention", 0, "how long to keep an account after its last load, zero keeps accounts forever")
+	policyPath := flag.String("policy", "", "json policy file of limits, defaults to the built in limits")
+	outOfOrder := flag.String("out-of-order", string(account.AcceptLate), "how to treat a load older than the customer's newest load: accept, reject or reevaluate")
+	importPath := flag.String("import", "", "snapshot file of account state, and of the admin config if it has one, to start from, in either format")
+	lateness := flag.Duration("lateness", 0, "how far behind the customer's newest load a load may be before -out-of-order reject declines it")
+	watchPolicy := flag.Duration("watch-policy", 0, "how often to check the -policy file for changes and reload it, zero never reloads it")
+	adminTokens := flag.String("admin-tokens", "", "json file of bearer tokens for the admin API and the actor each one authenticates. Empty disables the admin API.")
+	auditPath := flag.String("audit-log", "", "file to append every admin change to as a line of json")
+	reviewPath := flag.String("review-log", "", "file to append every load flagged by a soft limit to as a line of json, as well as listing them in the admin API")
+	configPath := flag.String("config", "", "file to save the admin config to after every change, including the standing of customers, and to restore it from on start")
+	traceExporter := flag.String("trace", tracing.None, "exporter to send a trace of every decision to: stdout, which prints to standard error, or otlp. Empty disables tracing.")
+	flag.Parse()
+	if err := account.OutOfOrder(*outOfOrder).Validate(); err != nil {
+		log.Fatal(err)
+	}
+	//the server only stops on a fatal error, so spans still batched then are lost
+	if _, err := tracing.Setup(*traceExporter, "velocity-limits"); err != nil {
+		log.Fatal(err)
+	}
+	policy := account.DefaultPolicy()
+	var policyFile *account.PolicyFile
+	if *policyPath != "" {
+		var err error
+		if policyFile, err = account.NewPolicyFile(*policyPath); err != nil {
+			log.Fatal(err)
+		}
+		policy = policyFile.Current()
+	}
+	c := cache.New(cache.NoExpiration, 10*time.Minute)
+	clk := clock.New()
+	//the policy file holds the default tier's policy, which the admin API can change along with the rest
+	store := account.NewConfigStore(policy)
+	store.SetClock(clk)
+	if *importPath != "" {
+		snapshot, err := account.LoadSnapshot(*importPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = snapshot.Restore(c); err != nil {
+			log.Fatal(err)
+		}
+		//a snapshot taken through the admin API carries the config, which replaces the policy file's
+		if snapshot.Config != nil {
+			if err = store.Restore(snapshot.Config); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if *configPath != "" {
+		//the config file is saved on every change, so it is newer than any snapshot
+		config, err := account.LoadConfig(*configPath)
+		switch {
+		case err == nil:
+			err = store.Restore(config)
+		case os.IsNotExist(err):
+			err = nil
+		}
+		if err == nil {
+			err = store.SaveTo(*configPath)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *auditPath != "" {
+		audit, err := os.OpenFile(*auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store.AuditTo(audit)
+	}
+	if policyFile != nil && *watchPolicy > 0 {
+		go policyFile.Watch(clk, *watchPolicy, nil, func(policy *account.Policy, err error) {
+			if err != nil {
+				log.Printf("keeping policy %s: %s", policy.Version, err)
+				return
+			}
+			reloaded, err := store.ReloadPolicy("policy-file", "reloaded "+*policyPath+" version "+policy.Version, account.DefaultTier, *policy)
+			if err != nil {
+				log.Printf("keeping policy: %s", err)
+				return
+			}
+			log.Printf("reloaded policy %s from %s as %s", policy.Version, *policyPath, reloaded.Version)
+		})
+	}
+	service := account.CustomerAccount{
+		Policies:   store,
+		Clock:      clk,
+		Retention:  *retention,
+		OutOfOrder: account.OutOfOrder(*outOfOrder),
+		Lateness:   *lateness,
+	}
+	var s account.Service
+	s = service
+	v := validator.New()
+	handler := account.NewHandler(s, v, c)
+	handler.SetClock(clk)
+	if *retention > 0 {
+		go handler.RunSweeper(time.Minute, nil)
+	}
+	review := account.NewReviewQueue()
+	if *reviewPath != "" {
+		reviewLog, err := os.OpenFile(*reviewPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		review.LogTo(reviewLog)
+	}
+	handler.SetReviewQueue(review)
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		g := grpc.NewServer()
+		rpc.New(&handler).Register(g)
+		log.Printf("serving gRPC on %s", *grpcAddr)
+		go func() {
+			log.Fatal(g.Serve(listener))
+		}()
+	}
+	srv := server.New(&handler)
+	if *adminTokens != "" {
+		tokens, err := server.LoadTokens(*adminTokens)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv.EnableAdmin(store, tokens)
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}